@@ -6,8 +6,17 @@ import "flag"
 // main is the entrypoint for the DeskSlice server.
 func main() {
 	debug := flag.Bool("debug", false, "Enable verbose debug logging")
+	snapshot := flag.String("snapshot", "", "Save a still of the running server's active crop to this file (.png or .jpg, - for stdout) and exit")
+	region := flag.String("snapshot-region", "", "Snapshot region: x,y,w,h in pixels or a calibrated region name")
+	quality := flag.Int("snapshot-quality", 0, "JPEG snapshot quality (1-100, default MJPEG_QUALITY)")
 	flag.Parse()
 
+	if *snapshot != "" {
+		if err := saveSnapshot(*snapshot, *region, *quality); err != nil {
+			logFatal(err)
+		}
+		return
+	}
 	if err := run(*debug); err != nil {
 		logFatal(err)
 	}
@@ -38,7 +38,7 @@ func run(debug bool) error {
 	sess := session.New(cfg.UIPassword)
 	runner := ffmpeg.NewRunner()
 
-	publisher, err := webrtc.NewPublisher()
+	publisher, err := webrtc.NewPublisher(iceConfig(cfg), cfg.VideoCodecs)
 	if err != nil {
 		return err
 	}
@@ -91,6 +91,25 @@ func run(debug bool) error {
 	return server.Shutdown(shutdownCtx)
 }
 
+// iceConfig maps the ICE settings from the environment onto the publisher config.
+func iceConfig(cfg config.Config) webrtc.ICEConfig {
+	ice := webrtc.ICEConfig{
+		UDPPortMin: uint16(cfg.ICEUDPPortMin),
+		UDPPortMax: uint16(cfg.ICEUDPPortMax),
+		UDPMuxPort: cfg.ICEUDPMuxPort,
+		NAT1To1IPs: cfg.ICENAT1To1IPs,
+		TCPPort:    cfg.ICETCPPort,
+	}
+	for _, s := range cfg.ICEServers {
+		ice.Servers = append(ice.Servers, webrtc.ICEServer{
+			URLs:       []string{s.URL},
+			Username:   s.Username,
+			Credential: s.Credential,
+		})
+	}
+	return ice
+}
+
 // logFatal prints and exits for startup failures.
 func logFatal(err error) {
 	log.Printf("fatal: %v", err)
@@ -0,0 +1,89 @@
+// Package main starts the DeskSlice server.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/frudas24/deskslice/internal/config"
+)
+
+// saveSnapshot fetches /api/snapshot from the server configured in the environment and
+// writes it to path. The format follows the file extension; "-" writes PNG to stdout.
+func saveSnapshot(path, region string, quality int) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	q := url.Values{}
+	if ext := strings.ToLower(filepath.Ext(path)); ext == ".jpg" || ext == ".jpeg" {
+		q.Set("format", "jpeg")
+	}
+	if region != "" {
+		q.Set("region", region)
+	}
+	if quality > 0 {
+		q.Set("quality", strconv.Itoa(quality))
+	}
+
+	client := &http.Client{Timeout: 15 * time.Second}
+	base := localBaseURL(cfg.ListenAddr)
+	resp, err := client.Get(base + "/api/snapshot?" + q.Encode())
+	if err == nil && resp.StatusCode == http.StatusUnauthorized && cfg.UIPassword != "" {
+		_ = resp.Body.Close()
+		body, _ := json.Marshal(map[string]string{"password": cfg.UIPassword})
+		login, err := client.Post(base+"/login", "application/json", bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		_ = login.Body.Close()
+		if login.StatusCode != http.StatusOK {
+			return fmt.Errorf("snapshot: login failed: %s", login.Status)
+		}
+		resp, err = client.Get(base + "/api/snapshot?" + q.Encode())
+	}
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("snapshot: %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	if path == "-" {
+		_, err = io.Copy(os.Stdout, resp.Body)
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// localBaseURL returns the loopback URL of a listen address such as "0.0.0.0:8787".
+func localBaseURL(addr string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "http://" + addr
+	}
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "127.0.0.1"
+	}
+	return "http://" + net.JoinHostPort(host, port)
+}
@@ -0,0 +1,219 @@
+// Package activity detects motion in the calibrated chat/scroll regions of preview frames
+// and reports when the agent starts working or goes idle. It compares coarse luma samples
+// of consecutive frames; no OCR is involved.
+package activity
+
+import (
+	"image"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Detector states.
+const (
+	// StateUnknown means no frames were analysed recently (for example WebRTC-only mode).
+	StateUnknown = "unknown"
+	// StateActive means the watched regions changed within the idle window.
+	StateActive = "active"
+	// StateIdle means the watched regions stayed still for the idle window.
+	StateIdle = "idle"
+)
+
+// gridSize bounds the samples taken along each axis of a region.
+const gridSize = 64
+
+// Config holds the detection thresholds.
+type Config struct {
+	// SampleInterval is the minimum time between analysed frames.
+	SampleInterval time.Duration
+	// PixelDelta is the luma difference (0-255) at which a sample counts as changed.
+	PixelDelta int
+	// ChangedPermille is how many samples per thousand must change to count as motion.
+	ChangedPermille int
+	// IdleAfter is how long the regions must stay still before going idle.
+	IdleAfter time.Duration
+}
+
+// Region is a named rectangle in frame coordinates.
+type Region struct {
+	Name string
+	Rect image.Rectangle
+}
+
+// RegionFunc returns the regions to watch in a w x h frame; false skips the frame (for
+// example in composite mode, where calibrated regions do not map onto the frame).
+type RegionFunc func(w, h int) ([]Region, bool)
+
+// Status is the detector state reported to clients.
+type Status struct {
+	State      string    `json:"state"`
+	Since      time.Time `json:"since,omitzero"`
+	LastMotion time.Time `json:"lastMotion,omitzero"`
+	// Regions lists the regions that moved in the last motion sample.
+	Regions []string `json:"regions,omitempty"`
+}
+
+// regionSamples is the previous luma samples of one region.
+type regionSamples struct {
+	rect image.Rectangle
+	luma []byte
+}
+
+// Detector turns frames into active/idle transitions.
+type Detector struct {
+	mu         sync.Mutex
+	cfg        Config
+	regions    RegionFunc
+	notify     func(Status)
+	prev       map[string]regionSamples
+	started    time.Time
+	lastSample time.Time
+	lastMotion time.Time
+	state      string
+	since      time.Time
+	moved      []string
+}
+
+// New returns a detector; notify is called, outside the detector lock, on every transition
+// between active and idle.
+func New(cfg Config, regions RegionFunc, notify func(Status)) *Detector {
+	return &Detector{
+		cfg:     cfg,
+		regions: regions,
+		notify:  notify,
+		prev:    make(map[string]regionSamples),
+		state:   StateUnknown,
+	}
+}
+
+// Observe analyses a packed RGB24 frame if the sample interval elapsed.
+func (d *Detector) Observe(rgb []byte, w, h int) {
+	if d == nil {
+		return
+	}
+	now := time.Now()
+	d.mu.Lock()
+	if now.Sub(d.lastSample) < d.cfg.SampleInterval {
+		d.mu.Unlock()
+		return
+	}
+	if d.lastSample.IsZero() || now.Sub(d.lastSample) > d.staleAfter() {
+		// Frames stopped for a while; start over instead of diffing against an old picture.
+		clear(d.prev)
+		d.started = now
+	}
+	d.lastSample = now
+	d.mu.Unlock()
+
+	regions, ok := d.regions(w, h)
+	if !ok {
+		return
+	}
+	var moved []string
+	d.mu.Lock()
+	for _, r := range regions {
+		rect := r.Rect.Intersect(image.Rect(0, 0, w, h))
+		if rect.Empty() {
+			continue
+		}
+		luma := sampleLuma(rgb, w, rect)
+		prev, ok := d.prev[r.Name]
+		d.prev[r.Name] = regionSamples{rect: rect, luma: luma}
+		// A moved or resized region is a new baseline, not motion.
+		if ok && prev.rect == rect && changedPermille(prev.luma, luma, d.cfg.PixelDelta) >= d.cfg.ChangedPermille {
+			moved = append(moved, r.Name)
+		}
+	}
+	status, changed := d.advanceLocked(now, moved)
+	d.mu.Unlock()
+	if changed && d.notify != nil {
+		d.notify(status)
+	}
+}
+
+// advanceLocked applies one sample to the state machine and reports whether it changed
+// between active and idle.
+func (d *Detector) advanceLocked(now time.Time, moved []string) (Status, bool) {
+	if len(moved) > 0 {
+		d.lastMotion = now
+		d.moved = moved
+		if d.state != StateActive {
+			d.state, d.since = StateActive, now
+			return d.statusLocked(), true
+		}
+		return Status{}, false
+	}
+	quietSince := d.lastMotion
+	if quietSince.Before(d.started) {
+		quietSince = d.started
+	}
+	if d.state != StateIdle && now.Sub(quietSince) >= d.cfg.IdleAfter {
+		// Leaving the unknown state is silent: only a finished burst of activity is news.
+		notify := d.state == StateActive
+		d.state, d.since = StateIdle, now
+		return d.statusLocked(), notify
+	}
+	return Status{}, false
+}
+
+// Status returns the current state; it is unknown while no frames arrive.
+func (d *Detector) Status() Status {
+	if d == nil {
+		return Status{State: StateUnknown}
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.lastSample.IsZero() || time.Since(d.lastSample) > d.staleAfter() {
+		return Status{State: StateUnknown, LastMotion: d.lastMotion}
+	}
+	return d.statusLocked()
+}
+
+// statusLocked snapshots the state.
+func (d *Detector) statusLocked() Status {
+	return Status{State: d.state, Since: d.since, LastMotion: d.lastMotion, Regions: slices.Clone(d.moved)}
+}
+
+// staleAfter is how long without frames before the state is unknown again.
+func (d *Detector) staleAfter() time.Duration {
+	return max(5*time.Second, 4*d.cfg.SampleInterval)
+}
+
+// sampleLuma takes up to gridSize x gridSize luma samples of a rectangle of a packed RGB24 frame.
+func sampleLuma(rgb []byte, width int, r image.Rectangle) []byte {
+	stepX := max(1, r.Dx()/gridSize)
+	stepY := max(1, r.Dy()/gridSize)
+	out := make([]byte, 0, (r.Dx()/stepX+1)*(r.Dy()/stepY+1))
+	for y := r.Min.Y; y < r.Max.Y; y += stepY {
+		for x := r.Min.X; x < r.Max.X; x += stepX {
+			i := (y*width + x) * 3
+			if i+2 >= len(rgb) {
+				out = append(out, 0)
+				continue
+			}
+			out = append(out, luma(rgb[i], rgb[i+1], rgb[i+2]))
+		}
+	}
+	return out
+}
+
+// luma approximates BT.601 luma with integer weights.
+func luma(r, g, b byte) byte {
+	return byte((299*int(r) + 587*int(g) + 114*int(b)) / 1000)
+}
+
+// changedPermille returns how many samples per thousand differ by at least delta.
+func changedPermille(a, b []byte, delta int) int {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	changed := 0
+	for i := range a {
+		d := int(a[i]) - int(b[i])
+		if d >= delta || -d >= delta {
+			changed++
+		}
+	}
+	return changed * 1000 / len(a)
+}
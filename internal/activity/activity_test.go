@@ -0,0 +1,83 @@
+package activity
+
+import (
+	"image"
+	"testing"
+	"time"
+)
+
+// frame returns a w x h RGB24 frame with a white block of size n at the origin.
+func frame(w, h, n int) []byte {
+	rgb := make([]byte, w*h*3)
+	for y := 0; y < n && y < h; y++ {
+		for x := 0; x < n && x < w; x++ {
+			i := (y*w + x) * 3
+			rgb[i], rgb[i+1], rgb[i+2] = 255, 255, 255
+		}
+	}
+	return rgb
+}
+
+// newTestDetector returns a detector watching one region that records its notifications.
+func newTestDetector(rect *image.Rectangle, idle time.Duration) (*Detector, *[]Status) {
+	var got []Status
+	d := New(Config{PixelDelta: 24, ChangedPermille: 5, IdleAfter: idle}, func(w, h int) ([]Region, bool) {
+		return []Region{{Name: "chat", Rect: *rect}}, true
+	}, func(s Status) { got = append(got, s) })
+	return d, &got
+}
+
+// TestDetector_ActiveThenIdle verifies motion reports active and a quiet period reports idle,
+// while the silent start-up state is not announced.
+func TestDetector_ActiveThenIdle(t *testing.T) {
+	rect := image.Rect(0, 0, 64, 64)
+	d, got := newTestDetector(&rect, 30*time.Millisecond)
+	if s := d.Status(); s.State != StateUnknown {
+		t.Fatalf("initial state = %q, want unknown", s.State)
+	}
+
+	still := frame(64, 64, 0)
+	d.Observe(still, 64, 64)
+	time.Sleep(40 * time.Millisecond)
+	d.Observe(still, 64, 64)
+	if s := d.Status(); s.State != StateIdle || len(*got) != 0 {
+		t.Fatalf("expected a silent idle start, got %+v notified %+v", s, *got)
+	}
+
+	d.Observe(frame(64, 64, 16), 64, 64)
+	if len(*got) != 1 || (*got)[0].State != StateActive || (*got)[0].Regions[0] != "chat" {
+		t.Fatalf("expected an active notification, got %+v", *got)
+	}
+	d.Observe(frame(64, 64, 16), 64, 64)
+	if len(*got) != 1 {
+		t.Fatalf("unchanged frame notified again: %+v", *got)
+	}
+	time.Sleep(40 * time.Millisecond)
+	d.Observe(frame(64, 64, 16), 64, 64)
+	if len(*got) != 2 || (*got)[1].State != StateIdle {
+		t.Fatalf("expected an idle notification, got %+v", *got)
+	}
+}
+
+// TestDetector_RegionChangeResetsBaseline verifies a recalibrated region is not mistaken for motion.
+func TestDetector_RegionChangeResetsBaseline(t *testing.T) {
+	rect := image.Rect(0, 0, 32, 32)
+	d, got := newTestDetector(&rect, time.Hour)
+	d.Observe(frame(64, 64, 0), 64, 64)
+	rect = image.Rect(0, 0, 64, 64)
+	d.Observe(frame(64, 64, 16), 64, 64)
+	if len(*got) != 0 {
+		t.Fatalf("region change reported as motion: %+v", *got)
+	}
+}
+
+// TestChangedPermille verifies small differences stay below the pixel threshold.
+func TestChangedPermille(t *testing.T) {
+	a := []byte{0, 0, 0, 0}
+	if got := changedPermille(a, []byte{10, 0, 0, 0}, 24); got != 0 {
+		t.Fatalf("noise counted as change: %d", got)
+	}
+	if got := changedPermille(a, []byte{200, 0, 30, 0}, 24); got != 500 {
+		t.Fatalf("changed = %d, want 500", got)
+	}
+}
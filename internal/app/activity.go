@@ -0,0 +1,65 @@
+// Package app wires HTTP, signaling, and pipeline state together.
+package app
+
+import (
+	"image"
+	"log"
+	"time"
+
+	"github.com/frudas24/deskslice/internal/activity"
+	"github.com/frudas24/deskslice/internal/calib"
+	"github.com/frudas24/deskslice/internal/push"
+	"github.com/frudas24/deskslice/internal/session"
+)
+
+// newActivityDetector builds the detector configured by ACTIVITY_* settings.
+func (a *App) newActivityDetector() *activity.Detector {
+	cfg := activity.Config{
+		SampleInterval:  time.Duration(a.cfg.ActivitySampleMs) * time.Millisecond,
+		PixelDelta:      a.cfg.ActivityDelta,
+		ChangedPermille: a.cfg.ActivityPermille,
+		IdleAfter:       time.Duration(a.cfg.ActivityIdleSec) * time.Second,
+	}
+	return activity.New(cfg, a.activityRegions, a.onActivity)
+}
+
+// Activity returns the current activity state; it is unknown when detection is disabled.
+func (a *App) Activity() activity.Status {
+	return a.activity.Status()
+}
+
+// activityRegions maps the calibrated chat/scroll regions onto a preview frame. Frames whose
+// size does not match the active crop (a crop change in flight) and composite layouts are skipped.
+func (a *App) activityRegions(w, h int) ([]activity.Region, bool) {
+	if a.session.Mode() == session.ModeComposite {
+		return nil, false
+	}
+	crop, ok := a.ActiveCrop()
+	if !ok || crop.W != w || crop.H != h {
+		return nil, false
+	}
+	c := a.session.GetCalib()
+	var out []activity.Region
+	for _, r := range c.EffectiveRegions() {
+		if r.Behavior != calib.BehaviorScroll && r.Behavior != calib.BehaviorType {
+			continue
+		}
+		rel := calib.Normalize(r.Rect)
+		x, y := c.PluginAbs.X+rel.X-crop.X, c.PluginAbs.Y+rel.Y-crop.Y
+		out = append(out, activity.Region{Name: r.Name, Rect: image.Rect(x, y, x+rel.W, y+rel.H)})
+	}
+	return out, len(out) > 0
+}
+
+// onActivity forwards a transition to the control client and, for the chosen
+// PUSH_EVENTS, to push subscribers.
+func (a *App) onActivity(status activity.Status) {
+	if err := a.control.SendActivity(status.State, status.Regions); err != nil {
+		log.Printf("activity: notify failed: %v", err)
+	}
+	if status.State == activity.StateIdle {
+		a.notify(pushEventIdle, push.Message{Title: "Agent idle", Body: "The panel stopped changing.", Tag: "activity"})
+	} else {
+		a.notify(pushEventActive, push.Message{Title: "Agent working", Body: "The panel started changing.", Tag: "activity"})
+	}
+}
@@ -0,0 +1,35 @@
+package app
+
+import (
+	"image"
+	"testing"
+
+	"github.com/frudas24/deskslice/internal/calib"
+	"github.com/frudas24/deskslice/internal/monitor"
+	"github.com/frudas24/deskslice/internal/session"
+)
+
+// TestActivityRegions_OddCalibration verifies regions are matched against the even-aligned
+// crop the preview actually captures when the calibration has odd offsets and sizes.
+func TestActivityRegions_OddCalibration(t *testing.T) {
+	sess := session.New("")
+	sess.SetMonitor(1)
+	sess.SetMode(session.ModeChat)
+	sess.SetCalib(calib.Calib{
+		PluginAbs: calib.Rect{X: 101, Y: 201, W: 301, H: 401},
+		ChatRel:   calib.Rect{X: 10, Y: 350, W: 281, H: 41},
+	})
+	app := newTestAppForConfig(sess, 120, 60)
+	app.monitors = []monitor.Monitor{{Index: 1, W: 1920, H: 1080, Primary: true}}
+
+	if _, ok := app.activityRegions(281, 41); ok {
+		t.Fatalf("raw crop size should not match a preview frame")
+	}
+	regions, ok := app.activityRegions(280, 40)
+	if !ok || len(regions) != 1 {
+		t.Fatalf("expected the chat region, got %+v ok=%t", regions, ok)
+	}
+	if want := image.Rect(1, 1, 282, 42); regions[0].Rect != want {
+		t.Fatalf("region rect = %v, want %v", regions[0].Rect, want)
+	}
+}
@@ -6,17 +6,28 @@ import (
 	"fmt"
 	"log"
 	"math"
+	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/frudas24/deskslice/internal/activity"
 	"github.com/frudas24/deskslice/internal/calib"
 	"github.com/frudas24/deskslice/internal/config"
 	"github.com/frudas24/deskslice/internal/control"
+	"github.com/frudas24/deskslice/internal/events"
 	"github.com/frudas24/deskslice/internal/ffmpeg"
+	"github.com/frudas24/deskslice/internal/latency"
 	"github.com/frudas24/deskslice/internal/mjpeg"
 	"github.com/frudas24/deskslice/internal/monitor"
+	"github.com/frudas24/deskslice/internal/push"
 	"github.com/frudas24/deskslice/internal/session"
 	"github.com/frudas24/deskslice/internal/signaling"
+	"github.com/frudas24/deskslice/internal/terminal"
+	"github.com/frudas24/deskslice/internal/tiles"
+	"github.com/frudas24/deskslice/internal/timeline"
+	"github.com/frudas24/deskslice/internal/turn"
+	"github.com/frudas24/deskslice/internal/webhook"
 	"github.com/frudas24/deskslice/internal/webrtc"
 	"github.com/frudas24/deskslice/internal/wininput"
 )
@@ -30,10 +41,23 @@ type App struct {
 	runner        *ffmpeg.Runner
 	preview       *ffmpeg.Preview
 	previewStream *mjpeg.Stream
+	tiles         *tiles.Server
+	timeline      *timeline.Timeline
+	activity      *activity.Detector
+	notifier      *push.Sender
+	events        *events.Bus
+	changes       *events.Bus
+	webhooks      *webhook.Dispatcher
 	publisher     *webrtc.Publisher
 	signaling     *signaling.Server
+	whep          *signaling.WHEPServer
 	control       *control.Server
+	terminal      *terminal.Server
+	latency       *latency.Recorder
+	relay         *turn.Server
 	monitors      []monitor.Monitor
+	// whepRTP records that the RTP output runs only because WHEP players are connected.
+	whepRTP bool
 }
 
 type mjpegDefaults struct {
@@ -61,6 +85,8 @@ func New(cfg config.Config, sess *session.Session, runner *ffmpeg.Runner, publis
 		session:   sess,
 		runner:    runner,
 		publisher: publisher,
+		events:    events.NewBus(),
+		changes:   events.NewBus(),
 		defaultMJPEG: mjpegDefaults{
 			intervalMs: cfg.MJPEGIntervalMs,
 			quality:    cfg.MJPEGQuality,
@@ -70,18 +96,73 @@ func New(cfg config.Config, sess *session.Session, runner *ffmpeg.Runner, publis
 		interval := time.Duration(cfg.MJPEGIntervalMs) * time.Millisecond
 		app.previewStream = mjpeg.NewStream(interval)
 		app.preview = ffmpeg.NewPreview(app.previewStream, cfg.MJPEGQuality)
+		if cfg.MJPEGTiles {
+			app.tiles = tiles.NewServer(sess.IsAuthenticated)
+			app.preview.SetTiles(app.tiles)
+		}
 	} else {
 		sess.SetVideoMode(session.VideoWebRTC)
 	}
+	if cfg.TimelineSec > 0 {
+		tl, err := timeline.New(filepath.Join(cfg.DataDir, "timeline"), cfg.TimelineSize, time.Duration(cfg.TimelineSec)*time.Second, cfg.TimelineChange)
+		if err != nil {
+			return nil, err
+		}
+		app.timeline = tl
+		if app.preview != nil {
+			app.preview.SetTimeline(tl)
+		}
+	}
+	if cfg.PushEnabled {
+		sender, err := push.NewSender(cfg.DataDir, cfg.PushSubject)
+		if err != nil {
+			return nil, err
+		}
+		app.notifier = sender
+	}
+	if len(cfg.WebhookURLs) > 0 {
+		app.webhooks = webhook.New(webhook.Config{
+			URLs:    cfg.WebhookURLs,
+			Secret:  cfg.WebhookSecret,
+			Events:  cfg.WebhookEvents,
+			Retries: cfg.WebhookRetries,
+		})
+	}
+	if cfg.Activity && app.preview != nil {
+		app.activity = app.newActivityDetector()
+		app.preview.SetActivity(app.activity)
+	}
 
 	app.signaling = signaling.NewServer(publisher, policy, sess.IsAuthenticated)
+	app.signaling.SetICEServers(app.iceServers)
+	app.signaling.SetReplaceHandler(app.onViewerReplaced)
+	app.whep = signaling.NewWHEPServer(publisher, sess.IsAuthenticated, sess.CheckPassword)
+	publisher.SetViewerHandler(app.onWHEPViewers)
 	app.control = control.NewServer(sess, injector, app.ListMonitors, func(reason string) {
 		if err := app.RestartPipeline(reason); err != nil {
-			log.Printf("pipeline restart (%s) failed: %v", reason, err)
+			app.pipelineFailed(reason, err)
 		}
 	}, func(c calib.Calib) error {
-		return calib.Save(cfg.CalibPath, c)
+		if err := calib.Save(cfg.CalibPath, c); err != nil {
+			return err
+		}
+		app.events.Publish(events.CalibSaved, map[string]any{"path": cfg.CalibPath})
+		return nil
 	})
+	app.control.SetEventBus(app.events)
+	sess.SetChangeHandler(app.stateChanged)
+	runner.SetExitHandler(func(err error) { app.ffmpegCrashed("webrtc", err) })
+	if app.preview != nil {
+		app.preview.SetCrashHandler(func(err error) { app.ffmpegCrashed("preview", err) })
+	}
+	app.terminal = terminal.NewServer(cfg.TerminalCommand, sess.IsAuthenticated)
+	app.control.SetTerminal(app.terminal)
+	publisher.SetControlHandler(app.control.HandleData)
+	publisher.SetCodecHandler(app.onCodecChange)
+	publisher.SetRecoveryHandler(app.onKeyframeTimeout)
+	app.latency = latency.NewRecorder(latency.DefaultWindow)
+	app.control.SetLatencyProbe(publisher, app.latency)
+	app.control.SetLayerSelector(publisher)
 
 	return app, nil
 }
@@ -95,6 +176,27 @@ func (a *App) Start() error {
 	a.monitors = monitors
 	logMonitors(monitors)
 
+	if a.cfg.TURNPort > 0 {
+		relay, err := turn.Start(turn.Config{Port: a.cfg.TURNPort, PublicIP: a.cfg.TURNPublicIP})
+		if err != nil {
+			return err
+		}
+		a.relay = relay
+		log.Printf("turn relay: listening on :%d (public ip %s)", a.cfg.TURNPort, a.cfg.TURNPublicIP)
+	}
+
+	if err := a.startSession(); err != nil {
+		// The caller only stops an app that started, so release the relay and whatever
+		// else already runs here; a retried Start then binds the relay port again.
+		_ = a.Stop()
+		a.relay = nil
+		return err
+	}
+	return nil
+}
+
+// startSession loads the calibration, starts the samplers and launches the presetup pipeline.
+func (a *App) startSession() error {
 	c, err := calib.Load(a.cfg.CalibPath)
 	if err != nil {
 		return err
@@ -108,6 +210,12 @@ func (a *App) Start() error {
 	a.session.SetMonitor(monitorIndex)
 	a.session.SetMode(session.ModePresetup)
 
+	if a.timeline != nil {
+		a.timeline.Start(a.Snapshot)
+	}
+	if a.webhooks != nil {
+		a.webhooks.Start(a.events)
+	}
 	return a.RestartPipeline("startup")
 }
 
@@ -118,13 +226,39 @@ func (a *App) Stop() error {
 
 	a.publisher.StopForwarding()
 	a.publisher.ClosePeer()
+	// Players closing now must not restart the pipeline.
+	a.whepRTP = false
+	a.publisher.CloseViewers()
 	a.publisher.CloseRTP()
+	a.publisher.CloseICE()
 	if a.preview != nil {
 		_ = a.preview.Stop()
 	}
+	_ = a.terminal.Stop()
+	a.timeline.Close()
+	a.webhooks.Close()
+	_ = a.relay.Close()
 	return a.runner.Stop()
 }
 
+// iceServers returns the configured STUN/TURN servers plus fresh credentials for the
+// embedded relay. The relay is set once in Start, before any viewer can connect.
+func (a *App) iceServers() []signaling.ICEServer {
+	var out []signaling.ICEServer
+	for _, s := range a.cfg.ICEServers {
+		out = append(out, signaling.ICEServer{URLs: []string{s.URL}, Username: s.Username, Credential: s.Credential})
+	}
+	if a.relay != nil {
+		creds, err := a.relay.Credentials()
+		if err != nil {
+			log.Printf("turn relay: credentials failed: %v", err)
+			return out
+		}
+		out = append(out, signaling.ICEServer{URLs: creds.URLs, Username: creds.Username, Credential: creds.Credential})
+	}
+	return out
+}
+
 // logMonitors prints the available monitors for debugging.
 func logMonitors(list []monitor.Monitor) {
 	for _, m := range list {
@@ -136,19 +270,38 @@ func logMonitors(list []monitor.Monitor) {
 func (a *App) RestartPipeline(reason string) error {
 	a.mu.Lock()
 	defer a.mu.Unlock()
+	// Runs before the unlock, so streams reading the state wait for the new crop.
+	defer a.stateChanged()
 
-	videoMode := a.session.VideoMode()
-
-	a.publisher.StopForwarding()
-	if err := a.runner.Stop(); err != nil {
-		return err
+	if a.cfg.LiveCrop && liveCropReason(reason) && a.applyLiveCrop() {
+		return nil
 	}
-	if a.preview != nil {
-		_ = a.preview.Stop()
+
+	videoMode := a.session.VideoMode()
+	shared := a.sharedPipeline()
+	whep := videoMode != session.VideoWebRTC && a.publisher.ViewerCount() > 0
+	a.whepRTP = whep
+
+	// The shared pipeline keeps running across video mode switches; it restarts itself when
+	// its capture settings change.
+	if !shared || videoMode == session.VideoTerminal {
+		a.publisher.StopForwarding()
+		if err := a.runner.Stop(); err != nil {
+			return err
+		}
+		if a.preview != nil {
+			_ = a.preview.Stop()
+		}
 	}
-	if videoMode == session.VideoMJPEG {
+	if videoMode == session.VideoMJPEG || videoMode == session.VideoTerminal {
 		a.publisher.ClosePeer()
 	}
+	if videoMode == session.VideoTerminal {
+		// The command keeps running across mode switches so the CLI session is not lost.
+		if err := a.terminal.Start(); err != nil || !whep {
+			return err
+		}
+	}
 
 	mode := a.session.Mode()
 	monitors := a.monitors
@@ -160,30 +313,36 @@ func (a *App) RestartPipeline(reason string) error {
 		return fmt.Errorf("monitor %d not found", a.session.Monitor())
 	}
 
-	opts := ffmpeg.Options{
-		FFmpegPath:    a.cfg.FFmpegPath,
-		FPS:           a.cfg.FPS,
-		BitrateKbps:   a.cfg.BitrateKbps,
-		CaptureDriver: a.cfg.CaptureDriver,
-	}
+	opts := a.ffmpegOptions()
 
 	var (
 		port int
 		err  error
 	)
+	if shared {
+		return a.startShared(mode, m, opts)
+	}
 	if videoMode == session.VideoMJPEG {
 		a.restartPreview(mode, m, opts)
-		return nil
+		if !whep {
+			return nil
+		}
 	}
 
 	port, err = a.publisher.RTPPort()
 	if err != nil {
 		return err
 	}
+	if a.cfg.Simulcast {
+		if opts.LowPort, err = a.publisher.LowRTPPort(); err != nil {
+			return err
+		}
+	}
 
-	if mode == session.ModeRun {
-		c := a.session.GetCalib()
-		_, err = a.runner.StartRunOnPort(m, c.PluginAbs, opts, port)
+	if mode == session.ModeComposite {
+		_, err = a.runner.StartCompositeOnPort(m, a.session.GetCalib().Layout, opts, port)
+	} else if crop, ok := a.cropRect(mode, m); ok {
+		_, err = a.runner.StartRunOnPort(m, crop, opts, port)
 	} else {
 		_, err = a.runner.StartPresetupOnPort(m, opts, port)
 	}
@@ -203,27 +362,218 @@ func (a *App) RestartPipeline(reason string) error {
 	return nil
 }
 
+// liveCropReason reports whether a pipeline change only moves the crop (zoom, mode and
+// calibration rectangle edits) and may be applied to the running capture.
+func liveCropReason(reason string) bool {
+	return reason == "zoom" || reason == "mode" || strings.HasSuffix(reason, "_rect")
+}
+
+// applyLiveCrop moves the running capture to the current crop without restarting ffmpeg.
+// It reports false when a restart is needed: composite layouts, terminal mode, another
+// monitor or, for the RTP encoder, a new crop size.
+func (a *App) applyLiveCrop() bool {
+	mode := a.session.Mode()
+	videoMode := a.session.VideoMode()
+	if mode == session.ModeComposite || videoMode == session.VideoTerminal {
+		return false
+	}
+	if a.whepRTP && !a.sharedPipeline() {
+		// Both the preview and the RTP runner capture; a restart moves them together.
+		return false
+	}
+	m, ok := monitor.GetMonitorByIndex(a.monitors, a.session.Monitor())
+	if !ok {
+		return false
+	}
+	crop, ok := a.cropRect(mode, m)
+	if !ok {
+		crop = calib.Rect{W: m.W, H: m.H}
+	}
+	if a.sharedPipeline() || videoMode == session.VideoMJPEG {
+		return a.preview != nil && a.preview.SetCrop(m, crop)
+	}
+	return a.runner.SetCrop(m, crop)
+}
+
+// onCodecChange restarts the encoder when a peer negotiates a different video codec.
+// It runs asynchronously so the SDP answer is not delayed by the ffmpeg restart.
+func (a *App) onCodecChange(codec string) {
+	if !a.rtpWanted() {
+		return
+	}
+	go func() {
+		if err := a.RestartPipeline("codec " + codec); err != nil {
+			a.pipelineFailed("codec "+codec, err)
+		}
+	}()
+}
+
+// pipelineFailed logs a failed pipeline restart, publishes it and pushes it to subscribers.
+func (a *App) pipelineFailed(reason string, err error) {
+	log.Printf("pipeline restart (%s) failed: %v", reason, err)
+	a.events.Publish(events.PipelineFailed, map[string]any{"reason": reason, "error": err.Error()})
+	a.notify(pushEventPipeline, push.Message{Title: "Pipeline failed", Body: reason + ": " + err.Error(), Tag: pushEventPipeline, Urgent: true})
+}
+
+// ffmpegCrashed publishes that the RTP or preview ffmpeg process exited on its own.
+func (a *App) ffmpegCrashed(pipeline string, err error) {
+	log.Printf("ffmpeg: %s process exited: %v", pipeline, err)
+	a.events.Publish(events.FFmpegCrashed, map[string]any{"pipeline": pipeline, "error": err.Error()})
+}
+
+// onKeyframeTimeout restarts the encoder when a viewer's PLI/FIR was not answered by a keyframe.
+func (a *App) onKeyframeTimeout() {
+	if !a.rtpWanted() {
+		return
+	}
+	go func() {
+		if a.sharedPipeline() {
+			// Unchanged settings would keep the shared process; force a fresh encoder.
+			_ = a.preview.Stop()
+		}
+		if err := a.RestartPipeline("keyframe request"); err != nil {
+			a.pipelineFailed("keyframe request", err)
+		}
+	}()
+}
+
+// rtpWanted reports whether the RTP output feeds anyone: the UI viewer in WebRTC mode or
+// WHEP players.
+func (a *App) rtpWanted() bool {
+	return a.session.VideoMode() == session.VideoWebRTC || a.publisher.ViewerCount() > 0
+}
+
+// onWHEPViewers starts the RTP output for the first WHEP player and stops it after the
+// last one left, without changing the session's video mode. WebRTC mode runs it anyway.
+func (a *App) onWHEPViewers(count int) {
+	videoMode := a.session.VideoMode()
+	if videoMode == session.VideoWebRTC || (a.sharedPipeline() && videoMode != session.VideoTerminal) {
+		// The RTP output is running already.
+		return
+	}
+	a.mu.Lock()
+	running := a.whepRTP
+	a.mu.Unlock()
+	if running == (count > 0) {
+		return
+	}
+	go func() {
+		if err := a.RestartPipeline("whep"); err != nil {
+			a.pipelineFailed("whep", err)
+		}
+	}()
+}
+
 // PreviewStream returns the MJPEG preview stream, if enabled.
 func (a *App) PreviewStream() *mjpeg.Stream {
 	return a.previewStream
 }
 
+// ffmpegOptions returns the capture/encode options from the current configuration.
+func (a *App) ffmpegOptions() ffmpeg.Options {
+	return ffmpeg.Options{
+		FFmpegPath:    a.cfg.FFmpegPath,
+		FPS:           a.cfg.FPS,
+		BitrateKbps:   a.cfg.BitrateKbps,
+		CaptureDriver: a.cfg.CaptureDriver,
+		Codec:         a.publisher.Codec(),
+		LiveCrop:      a.cfg.LiveCrop,
+		FFmpegJPEG:    a.cfg.MJPEGEncoder == "ffmpeg",
+		JPEGQuality:   a.cfg.MJPEGQuality,
+	}
+}
+
+// sharedPipeline reports whether one ffmpeg process feeds both RTP and the MJPEG preview.
+func (a *App) sharedPipeline() bool {
+	return a.cfg.SharedPipeline && a.preview != nil
+}
+
+// Tiles returns the tile-delta preview handler, if enabled.
+func (a *App) Tiles() *tiles.Server {
+	return a.tiles
+}
+
 // restartPreview starts or restarts the MJPEG preview pipeline.
 func (a *App) restartPreview(mode string, m monitor.Monitor, opts ffmpeg.Options) {
 	if a.preview == nil {
 		return
 	}
 	opts.FPS = previewFPS(a.cfg.MJPEGIntervalMs, opts.FPS)
-	var err error
-	if mode == session.ModeRun {
-		c := a.session.GetCalib()
-		err = a.preview.StartRun(m, c.PluginAbs, opts)
-	} else {
-		err = a.preview.StartPresetup(m, opts)
+	if err := a.startPreview(mode, m, opts); err != nil {
+		log.Printf("preview: start failed: %v", err)
+		a.notify(pushEventPipeline, push.Message{Title: "Preview failed", Body: err.Error(), Tag: pushEventPipeline, Urgent: true})
 	}
+}
+
+// startShared starts the preview process with the RTP outputs attached and makes sure the
+// publisher forwards them. The preview keeps an unchanged process running, so a video
+// mode switch only changes which output the viewer consumes.
+func (a *App) startShared(mode string, m monitor.Monitor, opts ffmpeg.Options) error {
+	port, err := a.publisher.RTPPort()
 	if err != nil {
-		log.Printf("preview: start failed: %v", err)
+		return err
 	}
+	if a.cfg.Simulcast {
+		if opts.LowPort, err = a.publisher.LowRTPPort(); err != nil {
+			return err
+		}
+	}
+	opts.RTPPort = port
+	opts.PreviewFPS = min(previewFPS(a.cfg.MJPEGIntervalMs, opts.FPS), opts.FPS)
+	if err := a.startPreview(mode, m, opts); err != nil {
+		return err
+	}
+	if err := a.publisher.AttachRTP(port); err != nil {
+		return err
+	}
+	return a.publisher.StartForwarding()
+}
+
+// startPreview starts the preview for the mode's crop, composite layout or full monitor.
+func (a *App) startPreview(mode string, m monitor.Monitor, opts ffmpeg.Options) error {
+	if mode == session.ModeComposite {
+		return a.preview.StartComposite(m, a.session.GetCalib().Layout, opts)
+	}
+	if crop, ok := a.cropRect(mode, m); ok {
+		return a.preview.StartRun(m, crop, opts)
+	}
+	return a.preview.StartPresetup(m, opts)
+}
+
+// cropRect returns the monitor-relative capture rectangle for the mode and active zoom.
+// It reports false when the whole monitor should be captured without cropping.
+func (a *App) cropRect(mode string, m monitor.Monitor) (calib.Rect, bool) {
+	zoom := a.session.Zoom()
+	if crop, ok := session.CropRect(mode, a.session.GetCalib()); ok {
+		return calib.ApplyZoom(crop, zoom), true
+	}
+	if zoom.IsFull() {
+		return calib.Rect{}, false
+	}
+	return calib.ApplyZoom(calib.Rect{W: m.W, H: m.H}, zoom), true
+}
+
+// ActiveCrop returns the monitor-relative rectangle currently streamed (mode crop plus zoom),
+// aligned to even pixels like the capture pipeline aligns it, so it matches frame sizes and
+// offsets. In composite mode only the size of the stacked frame is meaningful.
+func (a *App) ActiveCrop() (calib.Rect, bool) {
+	monitors, err := a.ListMonitors()
+	if err != nil {
+		return calib.Rect{}, false
+	}
+	m, ok := monitor.GetMonitorByIndex(monitors, a.session.Monitor())
+	if !ok {
+		return calib.Rect{}, false
+	}
+	mode := a.session.Mode()
+	if mode == session.ModeComposite {
+		layout := calib.StackLayout(a.session.GetCalib().Layout, m.W, m.H)
+		return calib.Rect{W: layout.W, H: layout.H}, layout.W > 0 && layout.H > 0
+	}
+	if crop, ok := a.cropRect(mode, m); ok {
+		return ffmpeg.NormalizeCropRect(crop, m), true
+	}
+	return calib.Rect{W: m.W, H: m.H}, true
 }
 
 // UpdateMJPEGPreview updates MJPEG interval/quality and restarts the preview pipeline when active.
@@ -247,7 +597,8 @@ func (a *App) UpdateMJPEGPreview(intervalMs int, quality int) error {
 		a.preview.SetQuality(quality)
 	}
 
-	if a.session.VideoMode() != session.VideoMJPEG {
+	videoMode := a.session.VideoMode()
+	if videoMode == session.VideoTerminal || (videoMode == session.VideoWebRTC && !a.sharedPipeline()) {
 		return nil
 	}
 	mode := a.session.Mode()
@@ -255,11 +606,9 @@ func (a *App) UpdateMJPEGPreview(intervalMs int, quality int) error {
 	if !ok {
 		return fmt.Errorf("monitor %d not found", a.session.Monitor())
 	}
-	opts := ffmpeg.Options{
-		FFmpegPath:    a.cfg.FFmpegPath,
-		FPS:           a.cfg.FPS,
-		BitrateKbps:   a.cfg.BitrateKbps,
-		CaptureDriver: a.cfg.CaptureDriver,
+	opts := a.ffmpegOptions()
+	if a.sharedPipeline() {
+		return a.startShared(mode, m, opts)
 	}
 	a.restartPreview(mode, m, opts)
 	return nil
@@ -302,6 +651,16 @@ func (a *App) Signaling() *signaling.Server {
 	return a.signaling
 }
 
+// Terminal returns the terminal websocket handler.
+func (a *App) Terminal() *terminal.Server {
+	return a.terminal
+}
+
+// WHEP returns the WHEP egress handler.
+func (a *App) WHEP() *signaling.WHEPServer {
+	return a.whep
+}
+
 // Control returns the control websocket handler.
 func (a *App) Control() *control.Server {
 	return a.control
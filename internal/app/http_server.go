@@ -8,8 +8,13 @@ import (
 	"os"
 	"path/filepath"
 
+	"github.com/frudas24/deskslice/internal/activity"
 	"github.com/frudas24/deskslice/internal/calib"
+	"github.com/frudas24/deskslice/internal/latency"
+	"github.com/frudas24/deskslice/internal/mjpeg"
+	"github.com/frudas24/deskslice/internal/signaling"
 	"github.com/frudas24/deskslice/internal/web"
+	"github.com/frudas24/deskslice/internal/webrtc"
 )
 
 // RegisterRoutes wires API and static handlers onto the mux.
@@ -22,13 +27,35 @@ func (a *App) RegisterRoutes(mux *http.ServeMux, staticDir string) {
 	mux.HandleFunc("/logout", a.handleLogout)
 	mux.HandleFunc("/api/monitors", a.handleMonitors)
 	mux.HandleFunc("/api/state", a.handleState)
+	mux.HandleFunc(stateStreamPath, a.handleStateStream)
 	mux.HandleFunc("/api/config", a.handleConfig)
+	mux.HandleFunc("/api/hotspots", a.handleHotspots)
+	mux.HandleFunc("/api/snapshot", a.handleSnapshot)
 	mux.Handle("/ws/signal", a.Signaling())
+	mux.Handle(signaling.WHEPPath, a.WHEP())
+	mux.Handle(signaling.WHEPPath+"/", a.WHEP())
 	mux.Handle("/ws/control", a.Control())
+	mux.Handle("/ws/terminal", a.Terminal())
 	mux.HandleFunc("/favicon.ico", handleFavicon)
 	if stream := a.PreviewStream(); stream != nil {
 		mux.HandleFunc("/mjpeg/desktop", stream.Handler)
 	}
+	if t := a.Tiles(); t != nil {
+		mux.Handle("/ws/tiles", t)
+	}
+	if a.Timeline() != nil {
+		mux.HandleFunc(timelinePath, a.handleTimeline)
+		mux.HandleFunc(timelinePath+"/", a.handleTimelineImage)
+	}
+	if a.Webhooks() != nil {
+		mux.HandleFunc(webhooksPath, a.handleWebhooks)
+	}
+	if a.Notifier() != nil {
+		mux.HandleFunc(pushPath, a.handlePush)
+		mux.HandleFunc(pushPath+"/subscribe", a.handlePushSubscribe)
+		mux.HandleFunc(pushPath+"/unsubscribe", a.handlePushUnsubscribe)
+		mux.HandleFunc(pushPath+"/test", a.handlePushTest)
+	}
 
 	mux.Handle("/", staticFileServer(staticDir))
 }
@@ -38,20 +65,40 @@ type loginRequest struct {
 }
 
 type stateResponse struct {
-	Mode          string       `json:"mode"`
-	MonitorIndex  int          `json:"monitor"`
-	InputEnabled  bool         `json:"inputEnabled"`
-	VideoMode     string       `json:"videoMode"`
-	Scroll        scrollConfig `json:"scroll"`
-	Calib         calibStatus  `json:"calib"`
-	CalibData     *calib.Calib `json:"calibData,omitempty"`
-	Authenticated bool         `json:"authenticated"`
+	Mode          string                     `json:"mode"`
+	MonitorIndex  int                        `json:"monitor"`
+	InputEnabled  bool                       `json:"inputEnabled"`
+	VideoMode     string                     `json:"videoMode"`
+	Codec         string                     `json:"codec"`
+	Layer         string                     `json:"layer,omitempty"`
+	LayerMode     string                     `json:"layerMode,omitempty"`
+	Tiles         bool                       `json:"tiles"`
+	Timeline      bool                       `json:"timeline"`
+	Activity      activity.Status            `json:"activity"`
+	Push          bool                       `json:"push"`
+	Scroll        scrollConfig               `json:"scroll"`
+	Calib         calibStatus                `json:"calib"`
+	CalibData     *calib.Calib               `json:"calibData,omitempty"`
+	Zoom          calib.Zoom                 `json:"zoom"`
+	Crop          *calib.Rect                `json:"crop,omitempty"`
+	Latency       map[string]latency.Summary `json:"latency,omitempty"`
+	Feedback      webrtc.FeedbackStats       `json:"feedback"`
+	MJPEG         []mjpeg.SubscriberStats    `json:"mjpegSubscribers,omitempty"`
+	Authenticated bool                       `json:"authenticated"`
 }
 
 type calibStatus struct {
-	Plugin bool `json:"plugin"`
-	Chat   bool `json:"chat"`
-	Scroll bool `json:"scroll"`
+	Plugin  bool `json:"plugin"`
+	Chat    bool `json:"chat"`
+	Scroll  bool `json:"scroll"`
+	Layout  int  `json:"layout"`
+	Regions int  `json:"regions"`
+}
+
+type hotspotResponse struct {
+	Name string `json:"name"`
+	X    int    `json:"x"`
+	Y    int    `json:"y"`
 }
 
 type scrollConfig struct {
@@ -117,17 +164,50 @@ func (a *App) handleState(w http.ResponseWriter, _ *http.Request) {
 	if !a.requireAuth(w) {
 		return
 	}
+	_ = json.NewEncoder(w).Encode(a.state())
+}
+
+// state builds the /api/state document.
+func (a *App) state() stateResponse {
 	snap := a.session.Snapshot()
 	resp := stateResponse{
 		Mode:          snap.Mode,
 		MonitorIndex:  snap.MonitorIndex,
 		InputEnabled:  snap.InputEnabled,
 		VideoMode:     snap.VideoMode,
+		Codec:         a.publisher.Codec(),
+		Tiles:         a.tiles != nil,
+		Timeline:      a.timeline != nil,
+		Activity:      a.Activity(),
+		Push:          a.notifier != nil,
 		Scroll:        scrollConfig{TickMs: a.cfg.ScrollTickMs, MaxDelta: a.cfg.ScrollMaxDelta},
 		Calib:         buildCalibStatus(snap.Calib),
 		CalibData:     &snap.Calib,
+		Zoom:          snap.Zoom,
+		Latency:       a.latency.Summary(),
+		Feedback:      a.publisher.FeedbackStats(),
+		MJPEG:         a.previewStream.Stats(),
 		Authenticated: snap.Authenticated,
 	}
+	if crop, ok := a.ActiveCrop(); ok {
+		resp.Crop = &crop
+	}
+	if a.cfg.Simulcast {
+		resp.Layer, resp.LayerMode = a.publisher.Layer()
+	}
+	return resp
+}
+
+// handleHotspots returns the calibrated hotspots (points relative to the plugin rect).
+func (a *App) handleHotspots(w http.ResponseWriter, _ *http.Request) {
+	if !a.requireAuth(w) {
+		return
+	}
+	c := a.session.GetCalib()
+	resp := make([]hotspotResponse, 0, len(c.Hotspots))
+	for _, h := range c.Hotspots {
+		resp = append(resp, hotspotResponse{Name: h.Name, X: h.X, Y: h.Y})
+	}
 	_ = json.NewEncoder(w).Encode(resp)
 }
 
@@ -191,9 +271,11 @@ func buildCalibStatus(c calib.Calib) calibStatus {
 	chat := calib.Normalize(c.ChatRel)
 	scroll := calib.Normalize(c.ScrollRel)
 	return calibStatus{
-		Plugin: plugin.W > 0 && plugin.H > 0,
-		Chat:   chat.W > 0 && chat.H > 0,
-		Scroll: scroll.W > 0 && scroll.H > 0,
+		Plugin:  plugin.W > 0 && plugin.H > 0,
+		Chat:    chat.W > 0 && chat.H > 0,
+		Scroll:  scroll.W > 0 && scroll.H > 0,
+		Layout:  len(c.Layout),
+		Regions: len(c.Regions),
 	}
 }
 
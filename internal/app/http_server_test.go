@@ -3,14 +3,20 @@ package app
 import (
 	"bytes"
 	"encoding/json"
+	"image"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"testing"
 	"time"
 
+	"github.com/frudas24/deskslice/internal/calib"
 	"github.com/frudas24/deskslice/internal/config"
+	"github.com/frudas24/deskslice/internal/events"
 	"github.com/frudas24/deskslice/internal/ffmpeg"
+	"github.com/frudas24/deskslice/internal/latency"
 	"github.com/frudas24/deskslice/internal/mjpeg"
+	"github.com/frudas24/deskslice/internal/monitor"
 	"github.com/frudas24/deskslice/internal/session"
 )
 
@@ -108,6 +114,82 @@ func TestHandleConfig_ValidatesInput(t *testing.T) {
 	}
 }
 
+// TestHandleState_ReportsActiveCrop verifies /api/state reports the crop of chat-only mode.
+func TestHandleState_ReportsActiveCrop(t *testing.T) {
+	sess := session.New("pw")
+	if !sess.Authenticate("pw") {
+		t.Fatalf("expected authenticate success")
+	}
+	sess.SetMonitor(1)
+	sess.SetMode(session.ModeChat)
+	sess.SetCalib(calib.Calib{
+		PluginAbs: calib.Rect{X: 100, Y: 200, W: 300, H: 400},
+		ChatRel:   calib.Rect{X: 10, Y: 350, W: 280, H: 40},
+	})
+	app := newTestAppForConfig(sess, 120, 60)
+	app.monitors = []monitor.Monitor{{Index: 1, W: 1920, H: 1080, Primary: true}}
+
+	rec := httptest.NewRecorder()
+	app.handleState(rec, httptest.NewRequest(http.MethodGet, "/api/state", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp stateResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	want := calib.Rect{X: 110, Y: 550, W: 280, H: 40}
+	if resp.Mode != session.ModeChat || resp.Crop == nil || *resp.Crop != want {
+		t.Fatalf("unexpected state: mode=%s crop=%+v", resp.Mode, resp.Crop)
+	}
+}
+
+// TestHandleHotspots_ListsCalibratedHotspots verifies /api/hotspots lists calibrated hotspots.
+func TestHandleHotspots_ListsCalibratedHotspots(t *testing.T) {
+	sess := session.New("pw")
+	app := newTestAppForConfig(sess, 120, 60)
+	rec := httptest.NewRecorder()
+	app.handleHotspots(rec, httptest.NewRequest(http.MethodGet, "/api/hotspots", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %d", rec.Code)
+	}
+
+	if !sess.Authenticate("pw") {
+		t.Fatalf("expected authenticate success")
+	}
+	sess.SetCalib(calib.Calib{Hotspots: []calib.Hotspot{{Name: "approve", X: 10, Y: 20}}})
+	rec = httptest.NewRecorder()
+	app.handleHotspots(rec, httptest.NewRequest(http.MethodGet, "/api/hotspots", nil))
+	var resp []hotspotResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if len(resp) != 1 || resp[0] != (hotspotResponse{Name: "approve", X: 10, Y: 20}) {
+		t.Fatalf("unexpected hotspots: %+v", resp)
+	}
+}
+
+// TestHandleState_ReportsLatencyPercentiles verifies /api/state exposes aggregated latency samples.
+func TestHandleState_ReportsLatencyPercentiles(t *testing.T) {
+	sess := session.New("pw")
+	if !sess.Authenticate("pw") {
+		t.Fatalf("expected authenticate success")
+	}
+	app := newTestAppForConfig(sess, 120, 60)
+	app.latency = latency.NewRecorder(10)
+	app.latency.Observe(latency.SeriesRTT, 15)
+
+	rec := httptest.NewRecorder()
+	app.handleState(rec, httptest.NewRequest(http.MethodGet, "/api/state", nil))
+	var resp stateResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if got := resp.Latency[latency.SeriesRTT]; got.Count != 1 || got.P50 != 15 {
+		t.Fatalf("unexpected latency: %+v", resp.Latency)
+	}
+}
+
 // newTestAppForConfig returns an App suitable for handleConfig tests without starting ffmpeg.
 func newTestAppForConfig(sess *session.Session, intervalMs int, quality int) *App {
 	stream := mjpeg.NewStream(time.Duration(intervalMs) * time.Millisecond)
@@ -122,7 +204,86 @@ func newTestAppForConfig(sess *session.Session, intervalMs int, quality int) *Ap
 			quality:    quality,
 		},
 		session:       sess,
+		changes:       events.NewBus(),
 		previewStream: stream,
 		preview:       ffmpeg.NewPreview(stream, quality),
 	}
 }
+
+// TestHandleSnapshot_Unauthorized verifies /api/snapshot requires authentication.
+func TestHandleSnapshot_Unauthorized(t *testing.T) {
+	app := newTestAppForConfig(session.New("pw"), 120, 60)
+
+	rec := httptest.NewRecorder()
+	app.handleSnapshot(rec, httptest.NewRequest(http.MethodGet, "/api/snapshot", nil))
+
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %d", rec.Code)
+	}
+}
+
+// TestParseSnapshotRequest verifies format and quality parsing and defaults.
+func TestParseSnapshotRequest(t *testing.T) {
+	req, err := parseSnapshotRequest(url.Values{"format": {"jpg"}, "quality": {"70"}, "region": {"chat"}}, 60)
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	if req.format != "jpeg" || req.quality != 70 || req.region != "chat" {
+		t.Fatalf("unexpected request: %+v", req)
+	}
+	if req, _ := parseSnapshotRequest(url.Values{}, 60); req.format != "png" || req.quality != 60 {
+		t.Fatalf("unexpected defaults: %+v", req)
+	}
+	for _, q := range []url.Values{{"format": {"gif"}}, {"quality": {"0"}}} {
+		if _, err := parseSnapshotRequest(q, 60); err == nil {
+			t.Fatalf("expected error for %v", q)
+		}
+	}
+}
+
+// TestSnapshotRegion verifies pixel and named regions map into the snapshot and are clipped to it.
+func TestSnapshotRegion(t *testing.T) {
+	c := calib.Calib{
+		PluginAbs: calib.Rect{X: 100, Y: 50, W: 400, H: 300},
+		ChatRel:   calib.Rect{X: 10, Y: 200, W: 380, H: 90},
+	}
+	crop := calib.Rect{X: 100, Y: 50, W: 400, H: 300}
+	bounds := image.Rect(0, 0, 400, 300)
+
+	got, err := snapshotRegion("chat", c, crop, false, bounds)
+	if err != nil || got != image.Rect(10, 200, 390, 290) {
+		t.Fatalf("chat region = %v, %v", got, err)
+	}
+	got, err = snapshotRegion("350,250,100,100", c, crop, false, bounds)
+	if err != nil || got != image.Rect(350, 250, 400, 300) {
+		t.Fatalf("clipped pixel region = %v, %v", got, err)
+	}
+	for _, spec := range []string{"missing", "500,500,10,10", "1,2,x,4"} {
+		if _, err := snapshotRegion(spec, c, crop, false, bounds); err == nil {
+			t.Fatalf("expected error for %q", spec)
+		}
+	}
+	if _, err := snapshotRegion("chat", c, crop, true, bounds); err == nil {
+		t.Fatal("expected named regions to be rejected in composite mode")
+	}
+}
+
+// TestSnapshotRect_OddCalibration verifies named regions map through the even-aligned crop
+// the preview captures, not the raw calibrated one.
+func TestSnapshotRect_OddCalibration(t *testing.T) {
+	sess := session.New("")
+	sess.SetMonitor(1)
+	sess.SetMode(session.ModeScroll)
+	sess.SetCalib(calib.Calib{
+		PluginAbs: calib.Rect{X: 101, Y: 51, W: 401, H: 301},
+		ScrollRel: calib.Rect{X: 10, Y: 10, W: 201, H: 151},
+	})
+	app := newTestAppForConfig(sess, 120, 60)
+	app.monitors = []monitor.Monitor{{Index: 1, W: 1920, H: 1080, Primary: true}}
+
+	// The raw crop is 111,61 201x151; ffmpeg captures 110,60 200x150.
+	got, err := app.snapshotRect("scroll", image.Rect(0, 0, 200, 150))
+	if err != nil || got != image.Rect(1, 1, 200, 150) {
+		t.Fatalf("scroll region = %v, %v", got, err)
+	}
+}
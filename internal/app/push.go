@@ -0,0 +1,138 @@
+// Package app wires HTTP, signaling, and pipeline state together.
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/frudas24/deskslice/internal/push"
+)
+
+// pushPath is the Web Push API prefix.
+const pushPath = "/api/push"
+
+// pushTimeout bounds one broadcast to all subscriptions.
+const pushTimeout = 20 * time.Second
+
+// Push triggers selectable with PUSH_EVENTS.
+const (
+	pushEventPipeline = "pipeline"
+	pushEventViewer   = "viewer"
+	pushEventIdle     = "idle"
+	pushEventActive   = "active"
+)
+
+// pushInfo describes the push setup to the browser.
+type pushInfo struct {
+	PublicKey     string   `json:"publicKey"`
+	Events        []string `json:"events"`
+	Subscriptions int      `json:"subscriptions"`
+}
+
+// Notifier returns the Web Push sender, if enabled.
+func (a *App) Notifier() *push.Sender {
+	return a.notifier
+}
+
+// notify broadcasts a message in the background when the event is one of PUSH_EVENTS.
+func (a *App) notify(event string, msg push.Message) {
+	if a.notifier == nil || !slices.Contains(a.cfg.PushEvents, event) {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), pushTimeout)
+		defer cancel()
+		if _, err := a.notifier.Broadcast(ctx, msg); err != nil {
+			log.Printf("push: %v", err)
+		}
+	}()
+}
+
+// onViewerReplaced pushes that another viewer took over the WebRTC session.
+func (a *App) onViewerReplaced(remote string) {
+	a.notify(pushEventViewer, push.Message{Title: "Viewer replaced", Body: "A viewer from " + remote + " took over the stream.", Tag: pushEventViewer})
+}
+
+// handlePush returns the VAPID public key and the configured triggers.
+func (a *App) handlePush(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !a.requireAuth(w) {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(pushInfo{
+		PublicKey:     a.notifier.PublicKey(),
+		Events:        a.cfg.PushEvents,
+		Subscriptions: len(a.notifier.Subscriptions()),
+	})
+}
+
+// handlePushSubscribe stores the PushSubscription posted by the browser.
+func (a *App) handlePushSubscribe(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !a.requireAuth(w) {
+		return
+	}
+	var sub push.Subscription
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 8<<10)).Decode(&sub); err != nil {
+		http.Error(w, "invalid subscription", http.StatusBadRequest)
+		return
+	}
+	if err := a.notifier.Subscribe(sub); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handlePushUnsubscribe forgets the subscription with the posted endpoint.
+func (a *App) handlePushUnsubscribe(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !a.requireAuth(w) {
+		return
+	}
+	var req struct {
+		Endpoint string `json:"endpoint"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 8<<10)).Decode(&req); err != nil || req.Endpoint == "" {
+		http.Error(w, "endpoint is required", http.StatusBadRequest)
+		return
+	}
+	if err := a.notifier.Unsubscribe(req.Endpoint); err != nil {
+		http.Error(w, "unsubscribe failed", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handlePushTest sends a test notification to every subscription, regardless of PUSH_EVENTS.
+func (a *App) handlePushTest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !a.requireAuth(w) {
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), pushTimeout)
+	defer cancel()
+	sent, err := a.notifier.Broadcast(ctx, push.Message{Title: "DeskSlice", Body: "Notifications are working.", Tag: "test"})
+	if err != nil {
+		log.Printf("push: test: %v", err)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]int{"sent": sent})
+}
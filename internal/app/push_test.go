@@ -0,0 +1,77 @@
+package app
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/frudas24/deskslice/internal/config"
+	"github.com/frudas24/deskslice/internal/push"
+	"github.com/frudas24/deskslice/internal/session"
+)
+
+// TestPushHandlers_SubscribeAndNotify verifies a browser can subscribe and then receives
+// only the configured triggers from a local push-service stand-in.
+func TestPushHandlers_SubscribeAndNotify(t *testing.T) {
+	received := make(chan string, 4)
+	service := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.Header.Get("Topic")
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer service.Close()
+
+	sess := session.New("pw")
+	sender, err := push.NewSender(t.TempDir(), "mailto:ops@example.com")
+	if err != nil {
+		t.Fatalf("new sender: %v", err)
+	}
+	app := &App{cfg: config.Config{PushEvents: []string{pushEventPipeline}}, session: sess, notifier: sender}
+
+	priv, _ := ecdh.P256().GenerateKey(rand.Reader)
+	auth := make([]byte, 16)
+	_, _ = rand.Read(auth)
+	body, _ := json.Marshal(map[string]any{
+		"endpoint": service.URL + "/push/1",
+		"keys": map[string]string{
+			"p256dh": base64.RawURLEncoding.EncodeToString(priv.PublicKey().Bytes()),
+			"auth":   base64.RawURLEncoding.EncodeToString(auth),
+		},
+	})
+	subscribe := func() int {
+		rec := httptest.NewRecorder()
+		app.handlePushSubscribe(rec, httptest.NewRequest(http.MethodPost, pushPath+"/subscribe", bytes.NewReader(body)))
+		return rec.Code
+	}
+	if code := subscribe(); code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 before login, got %d", code)
+	}
+	sess.Authenticate("pw")
+	if code := subscribe(); code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", code)
+	}
+
+	rec := httptest.NewRecorder()
+	app.handlePush(rec, httptest.NewRequest(http.MethodGet, pushPath, nil))
+	var info pushInfo
+	if err := json.Unmarshal(rec.Body.Bytes(), &info); err != nil || info.PublicKey != sender.PublicKey() || info.Subscriptions != 1 {
+		t.Fatalf("unexpected push info %+v err=%v", info, err)
+	}
+
+	app.onViewerReplaced("10.0.0.2:5000")
+	app.pipelineFailed("codec vp9", errors.New("ffmpeg exited early"))
+	select {
+	case topic := <-received:
+		if topic != pushEventPipeline {
+			t.Fatalf("unexpected notification %q; viewer is not a configured trigger", topic)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("pipeline failure was not pushed")
+	}
+}
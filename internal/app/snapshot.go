@@ -0,0 +1,191 @@
+// Package app wires HTTP, signaling, and pipeline state together.
+package app
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/frudas24/deskslice/internal/calib"
+	"github.com/frudas24/deskslice/internal/ffmpeg"
+	"github.com/frudas24/deskslice/internal/monitor"
+	"github.com/frudas24/deskslice/internal/session"
+)
+
+// snapshotTimeout bounds how long a snapshot waits for a preview frame or a capture.
+const snapshotTimeout = 5 * time.Second
+
+// snapshotRequest holds the parsed /api/snapshot query.
+type snapshotRequest struct {
+	format  string
+	quality int
+	region  string
+}
+
+// Snapshot returns a still of the active crop: the next preview frame when the preview is
+// running, otherwise a one-shot ffmpeg capture.
+func (a *App) Snapshot(ctx context.Context) (image.Image, error) {
+	if a.preview != nil {
+		img, err := a.preview.Frame(ctx)
+		if !errors.Is(err, ffmpeg.ErrPreviewStopped) {
+			return img, err
+		}
+	}
+
+	a.mu.Lock()
+	opts := a.ffmpegOptions()
+	monitors := a.monitors
+	a.mu.Unlock()
+	m, ok := monitor.GetMonitorByIndex(monitors, a.session.Monitor())
+	if !ok {
+		return nil, fmt.Errorf("monitor %d not found", a.session.Monitor())
+	}
+	mode := a.session.Mode()
+	if mode == session.ModeComposite {
+		return ffmpeg.Capture(ctx, ffmpeg.ModeComposite, m, calib.Rect{}, a.session.GetCalib().Layout, opts)
+	}
+	if crop, ok := a.cropRect(mode, m); ok {
+		return ffmpeg.Capture(ctx, ffmpeg.ModeRun, m, crop, nil, opts)
+	}
+	return ffmpeg.Capture(ctx, ffmpeg.ModePresetup, m, calib.Rect{}, nil, opts)
+}
+
+// handleSnapshot returns the current frame of the active crop as PNG or JPEG.
+func (a *App) handleSnapshot(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !a.requireAuth(w) {
+		return
+	}
+	req, err := parseSnapshotRequest(r.URL.Query(), a.cfg.MJPEGQuality)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), snapshotTimeout)
+	defer cancel()
+	img, err := a.Snapshot(ctx)
+	if err != nil {
+		log.Printf("snapshot: %v", err)
+		http.Error(w, "snapshot unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	if req.region != "" {
+		rect, err := a.snapshotRect(req.region, img.Bounds())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		img = subImage(img, rect)
+	}
+
+	var buf bytes.Buffer
+	contentType := "image/png"
+	if req.format == "jpeg" {
+		contentType = "image/jpeg"
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: req.quality})
+	} else {
+		err = png.Encode(&buf, img)
+	}
+	if err != nil {
+		http.Error(w, "encode failed", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "no-store")
+	_, _ = w.Write(buf.Bytes())
+}
+
+// parseSnapshotRequest reads the format (png|jpeg), quality (1-100) and region parameters.
+func parseSnapshotRequest(q url.Values, defaultQuality int) (snapshotRequest, error) {
+	req := snapshotRequest{format: "png", quality: defaultQuality, region: strings.TrimSpace(q.Get("region"))}
+	switch strings.ToLower(q.Get("format")) {
+	case "", "png":
+	case "jpeg", "jpg":
+		req.format = "jpeg"
+	default:
+		return snapshotRequest{}, fmt.Errorf("format must be png or jpeg")
+	}
+	if raw := q.Get("quality"); raw != "" {
+		quality, err := strconv.Atoi(raw)
+		if err != nil || quality < 1 || quality > 100 {
+			return snapshotRequest{}, fmt.Errorf("quality must be 1-100")
+		}
+		req.quality = quality
+	}
+	return req, nil
+}
+
+// snapshotRect resolves a region parameter against the crop the snapshot was taken of. The
+// pipeline aligns that crop to even pixels, and ActiveCrop reports it the same way, so named
+// regions keep their offsets with odd calibrations too.
+func (a *App) snapshotRect(spec string, bounds image.Rectangle) (image.Rectangle, error) {
+	crop, _ := a.ActiveCrop()
+	composite := a.session.Mode() == session.ModeComposite
+	return snapshotRegion(spec, a.session.GetCalib(), crop, composite, bounds)
+}
+
+// snapshotRegion resolves a region parameter to a rectangle of the snapshot. It is either
+// "x,y,w,h" in snapshot pixels or the name of a calibrated region, which is plugin-relative
+// and mapped through the monitor-relative crop the snapshot was taken of.
+func snapshotRegion(spec string, c calib.Calib, crop calib.Rect, composite bool, bounds image.Rectangle) (image.Rectangle, error) {
+	var r calib.Rect
+	if parts := strings.Split(spec, ","); len(parts) == 4 {
+		vals := make([]int, 4)
+		for i, part := range parts {
+			v, err := strconv.Atoi(strings.TrimSpace(part))
+			if err != nil {
+				return image.Rectangle{}, fmt.Errorf("region must be x,y,w,h or a region name")
+			}
+			vals[i] = v
+		}
+		r = calib.Rect{X: vals[0], Y: vals[1], W: vals[2], H: vals[3]}
+	} else {
+		if composite {
+			return image.Rectangle{}, fmt.Errorf("named regions are not available in composite mode")
+		}
+		found := false
+		for _, region := range c.EffectiveRegions() {
+			if region.Name == spec {
+				rel := calib.Normalize(region.Rect)
+				r = calib.Rect{X: c.PluginAbs.X + rel.X - crop.X, Y: c.PluginAbs.Y + rel.Y - crop.Y, W: rel.W, H: rel.H}
+				found = true
+				break
+			}
+		}
+		if !found {
+			return image.Rectangle{}, fmt.Errorf("unknown region %q", spec)
+		}
+	}
+	out := image.Rect(r.X, r.Y, r.X+r.W, r.Y+r.H).Add(bounds.Min).Intersect(bounds)
+	if out.Empty() {
+		return image.Rectangle{}, fmt.Errorf("region is outside the snapshot")
+	}
+	return out, nil
+}
+
+// subImage returns the part of img inside r, copying only when the image cannot share pixels.
+func subImage(img image.Image, r image.Rectangle) image.Image {
+	if sub, ok := img.(interface {
+		SubImage(image.Rectangle) image.Image
+	}); ok {
+		return sub.SubImage(r)
+	}
+	out := image.NewRGBA(image.Rect(0, 0, r.Dx(), r.Dy()))
+	draw.Draw(out, out.Rect, img, r.Min, draw.Src)
+	return out
+}
@@ -0,0 +1,141 @@
+// Package app wires HTTP, signaling, and pipeline state together.
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+const (
+	// stateStreamPath streams /api/state changes as server-sent events.
+	stateStreamPath = "/api/state/stream"
+	// stateKeepalive is how often an idle stream writes a comment so proxies keep it open.
+	stateKeepalive = 25 * time.Second
+	// stateChangedEvent is the only signal published on the changes bus.
+	stateChangedEvent = "state.changed"
+)
+
+// stateStatsFields are left out of the stream: they move with every probe or frame, and
+// the client's stats line keeps polling /api/state for them.
+var stateStatsFields = []string{"latency", "feedback", "mjpegSubscribers"}
+
+// stateChanged signals streams that the session or pipeline changed. Publishing never
+// blocks, and a stream with a signal already pending ignores further ones, so bursts of
+// setters collapse into one diff.
+func (a *App) stateChanged() {
+	a.changes.Publish(stateChangedEvent, nil)
+}
+
+// handleStateStream sends the full state as a "state" event, then a "diff" event with the
+// changed top-level fields whenever a session setter runs or the pipeline restarts.
+// Removed fields are sent as null.
+func (a *App) handleStateStream(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !a.requireAuth(w) {
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	changes, unsubscribe := a.changes.Subscribe(1)
+	defer unsubscribe()
+
+	last, err := a.streamState()
+	if err != nil {
+		http.Error(w, "state unavailable", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	id := 1
+	if writeStateEvent(w, id, "state", last) != nil {
+		return
+	}
+	flusher.Flush()
+
+	keepalive := time.NewTicker(stateKeepalive)
+	defer keepalive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepalive.C:
+			if !a.session.IsAuthenticated() {
+				return
+			}
+			if _, err := io.WriteString(w, ": keepalive\n\n"); err != nil {
+				return
+			}
+		case <-changes:
+			if !a.session.IsAuthenticated() {
+				return
+			}
+			next, err := a.streamState()
+			if err != nil {
+				return
+			}
+			diff := diffState(last, next)
+			if len(diff) == 0 {
+				continue
+			}
+			last = next
+			id++
+			if writeStateEvent(w, id, "diff", diff) != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+// streamState returns the /api/state document as raw top-level fields, without the stats.
+func (a *App) streamState() (map[string]json.RawMessage, error) {
+	raw, err := json.Marshal(a.state())
+	if err != nil {
+		return nil, err
+	}
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, err
+	}
+	for _, name := range stateStatsFields {
+		delete(fields, name)
+	}
+	return fields, nil
+}
+
+// diffState returns the fields of next that differ from prev, with removed fields as null.
+func diffState(prev, next map[string]json.RawMessage) map[string]json.RawMessage {
+	diff := map[string]json.RawMessage{}
+	for name, value := range next {
+		if old, ok := prev[name]; !ok || !bytes.Equal(old, value) {
+			diff[name] = value
+		}
+	}
+	for name := range prev {
+		if _, ok := next[name]; !ok {
+			diff[name] = json.RawMessage("null")
+		}
+	}
+	return diff
+}
+
+// writeStateEvent writes one server-sent event; compact JSON always fits on the data line.
+func writeStateEvent(w io.Writer, id int, event string, fields map[string]json.RawMessage) error {
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", id, event, data)
+	return err
+}
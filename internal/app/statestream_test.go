@@ -0,0 +1,92 @@
+package app
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/frudas24/deskslice/internal/session"
+)
+
+// readStateEvent reads the next server-sent event, skipping keepalive comments.
+func readStateEvent(t *testing.T, r *bufio.Reader) (string, map[string]json.RawMessage) {
+	t.Helper()
+	var event string
+	fields := map[string]json.RawMessage{}
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("read stream: %v", err)
+		}
+		line = strings.TrimRight(line, "\n")
+		switch {
+		case line == "" && event != "":
+			return event, fields
+		case strings.HasPrefix(line, "event: "):
+			event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &fields); err != nil {
+				t.Fatalf("decode data: %v", err)
+			}
+		}
+	}
+}
+
+// TestHandleStateStream_SendsDiffs verifies the stream opens with the full state and then
+// only carries the fields a session setter changed.
+func TestHandleStateStream_SendsDiffs(t *testing.T) {
+	sess := session.New("pw")
+	if !sess.Authenticate("pw") {
+		t.Fatalf("expected authenticate success")
+	}
+	app := newTestAppForConfig(sess, 120, 60)
+	sess.SetChangeHandler(app.stateChanged)
+	srv := httptest.NewServer(http.HandlerFunc(app.handleStateStream))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("open stream: %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("unexpected content type %q", ct)
+	}
+	r := bufio.NewReader(resp.Body)
+	event, fields := readStateEvent(t, r)
+	if event != "state" || string(fields["mode"]) != `"presetup"` || fields["calib"] == nil {
+		t.Fatalf("unexpected first event %s %v", event, fields)
+	}
+	if _, ok := fields["latency"]; ok {
+		t.Fatalf("stats fields should not be streamed")
+	}
+
+	sess.SetMode(session.ModeRun)
+	event, fields = readStateEvent(t, r)
+	if event != "diff" || len(fields) != 1 || string(fields["mode"]) != `"run"` {
+		t.Fatalf("unexpected diff %s %v", event, fields)
+	}
+}
+
+// TestHandleStateStream_Unauthorized verifies the stream requires authentication.
+func TestHandleStateStream_Unauthorized(t *testing.T) {
+	app := newTestAppForConfig(session.New("pw"), 120, 60)
+	rec := httptest.NewRecorder()
+	app.handleStateStream(rec, httptest.NewRequest(http.MethodGet, stateStreamPath, nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %d", rec.Code)
+	}
+}
+
+// TestDiffState verifies changed and added fields are reported and removed ones become null.
+func TestDiffState(t *testing.T) {
+	prev := map[string]json.RawMessage{"mode": json.RawMessage(`"run"`), "crop": json.RawMessage(`{"X":1}`), "monitor": json.RawMessage(`1`)}
+	next := map[string]json.RawMessage{"mode": json.RawMessage(`"chat"`), "monitor": json.RawMessage(`1`), "zoom": json.RawMessage(`{}`)}
+	diff := diffState(prev, next)
+	if len(diff) != 3 || string(diff["mode"]) != `"chat"` || string(diff["crop"]) != "null" || string(diff["zoom"]) != "{}" {
+		t.Fatalf("unexpected diff %v", diff)
+	}
+}
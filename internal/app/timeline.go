@@ -0,0 +1,66 @@
+// Package app wires HTTP, signaling, and pipeline state together.
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/frudas24/deskslice/internal/timeline"
+)
+
+// timelinePath is the list endpoint; images are served below timelinePath + "/".
+const timelinePath = "/api/timeline"
+
+// timelineResponse lists the stored timeline samples.
+type timelineResponse struct {
+	IntervalSec int              `json:"intervalSec"`
+	Entries     []timeline.Entry `json:"entries"`
+}
+
+// Timeline returns the session timeline, if enabled.
+func (a *App) Timeline() *timeline.Timeline {
+	return a.timeline
+}
+
+// handleTimeline lists the samples, oldest first.
+func (a *App) handleTimeline(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !a.requireAuth(w) {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(timelineResponse{
+		IntervalSec: int(a.timeline.Interval().Seconds()),
+		Entries:     a.timeline.Entries(),
+	})
+}
+
+// handleTimelineImage serves /api/timeline/<id> as the full JPEG, or its thumbnail with ?thumb=1.
+func (a *App) handleTimelineImage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !a.requireAuth(w) {
+		return
+	}
+	name := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, timelinePath+"/"), ".jpg")
+	id, err := strconv.ParseInt(name, 10, 64)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	path, err := a.timeline.Path(id, r.URL.Query().Get("thumb") == "1")
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "image/jpeg")
+	w.Header().Set("Cache-Control", "private, max-age=86400, immutable")
+	http.ServeFile(w, r, path)
+}
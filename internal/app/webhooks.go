@@ -0,0 +1,46 @@
+// Package app wires HTTP, signaling, and pipeline state together.
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/frudas24/deskslice/internal/events"
+	"github.com/frudas24/deskslice/internal/webhook"
+)
+
+// webhooksPath lists recent webhook deliveries.
+const webhooksPath = "/api/webhooks"
+
+// webhooksResponse is the delivery log, newest last.
+type webhooksResponse struct {
+	Events     []string           `json:"events"`
+	Deliveries []webhook.Delivery `json:"deliveries"`
+}
+
+// Events returns the server event bus.
+func (a *App) Events() *events.Bus {
+	return a.events
+}
+
+// Webhooks returns the webhook dispatcher, if any URL is configured.
+func (a *App) Webhooks() *webhook.Dispatcher {
+	return a.webhooks
+}
+
+// handleWebhooks returns the delivered event types and the delivery log.
+func (a *App) handleWebhooks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !a.requireAuth(w) {
+		return
+	}
+	selected := a.cfg.WebhookEvents
+	if len(selected) == 0 {
+		selected = events.Types
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(webhooksResponse{Events: selected, Deliveries: a.webhooks.Deliveries()})
+}
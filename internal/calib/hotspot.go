@@ -0,0 +1,41 @@
+// Package calib handles calibration data and storage.
+package calib
+
+// Hotspot is a named point relative to PluginAbs that can be clicked with one tap.
+type Hotspot struct {
+	Name string
+	X    int
+	Y    int
+}
+
+// SetHotspot inserts or replaces the named hotspot.
+func (c *Calib) SetHotspot(h Hotspot) {
+	for i, existing := range c.Hotspots {
+		if existing.Name == h.Name {
+			c.Hotspots[i] = h
+			return
+		}
+	}
+	c.Hotspots = append(c.Hotspots, h)
+}
+
+// RemoveHotspot deletes the named hotspot and reports whether it existed.
+func (c *Calib) RemoveHotspot(name string) bool {
+	for i, existing := range c.Hotspots {
+		if existing.Name == name {
+			c.Hotspots = append(c.Hotspots[:i:i], c.Hotspots[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// FindHotspot returns the named hotspot.
+func (c Calib) FindHotspot(name string) (Hotspot, bool) {
+	for _, h := range c.Hotspots {
+		if h.Name == name {
+			return h, true
+		}
+	}
+	return Hotspot{}, false
+}
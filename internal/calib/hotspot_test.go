@@ -0,0 +1,23 @@
+package calib
+
+import "testing"
+
+// TestSetHotspot_UpsertsAndRemoves verifies hotspots are replaced by name and can be removed.
+func TestSetHotspot_UpsertsAndRemoves(t *testing.T) {
+	var c Calib
+	c.SetHotspot(Hotspot{Name: "approve", X: 10, Y: 20})
+	c.SetHotspot(Hotspot{Name: "stop", X: 30, Y: 40})
+	c.SetHotspot(Hotspot{Name: "approve", X: 11, Y: 21})
+	if len(c.Hotspots) != 2 {
+		t.Fatalf("expected 2 hotspots, got %+v", c.Hotspots)
+	}
+	if h, ok := c.FindHotspot("approve"); !ok || h.X != 11 || h.Y != 21 {
+		t.Fatalf("unexpected approve hotspot: %+v ok=%t", h, ok)
+	}
+	if !c.RemoveHotspot("approve") || c.RemoveHotspot("approve") {
+		t.Fatalf("expected single successful removal")
+	}
+	if _, ok := c.FindHotspot("approve"); ok || len(c.Hotspots) != 1 {
+		t.Fatalf("unexpected hotspots after removal: %+v", c.Hotspots)
+	}
+}
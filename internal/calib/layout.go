@@ -0,0 +1,101 @@
+// Package calib handles calibration data and storage.
+package calib
+
+// MaxLayoutRegions caps how many regions a composite layout may stack.
+const MaxLayoutRegions = 4
+
+// Tile places a monitor-relative source rectangle inside a composite frame.
+type Tile struct {
+	Src Rect
+	Dst Rect
+}
+
+// Layout describes a composite frame built from several capture regions.
+type Layout struct {
+	W     int
+	H     int
+	Tiles []Tile
+}
+
+// StackLayout stacks regions vertically (top to bottom, left-aligned), padding narrower
+// tiles to the widest one. Regions are clamped to a boundsW x boundsH capture and aligned
+// to even sizes so encoders accept them.
+func StackLayout(regions []Rect, boundsW, boundsH int) Layout {
+	var out Layout
+	for _, r := range regions {
+		r = evenRect(r, boundsW, boundsH)
+		if r.W <= 0 || r.H <= 0 {
+			continue
+		}
+		if len(out.Tiles) == MaxLayoutRegions {
+			break
+		}
+		out.Tiles = append(out.Tiles, Tile{
+			Src: r,
+			Dst: Rect{X: 0, Y: out.H, W: r.W, H: r.H},
+		})
+		out.H += r.H
+		if r.W > out.W {
+			out.W = r.W
+		}
+	}
+	return out
+}
+
+// Locate maps a pixel in the composite frame back to a monitor-relative point.
+// Points in the padding to the right of a narrow tile are clamped to that tile's edge.
+func (l Layout) Locate(px, py int) (int, int, bool) {
+	if len(l.Tiles) == 0 {
+		return 0, 0, false
+	}
+	tile := l.Tiles[len(l.Tiles)-1]
+	for _, t := range l.Tiles {
+		if py < t.Dst.Y+t.Dst.H {
+			tile = t
+			break
+		}
+	}
+	dx := clampInt(px-tile.Dst.X, 0, tile.Src.W-1)
+	dy := clampInt(py-tile.Dst.Y, 0, tile.Src.H-1)
+	return tile.Src.X + dx, tile.Src.Y + dy, true
+}
+
+// Bounds returns the bounding box of all tile sources.
+func (l Layout) Bounds() Rect {
+	if len(l.Tiles) == 0 {
+		return Rect{}
+	}
+	minX, minY := l.Tiles[0].Src.X, l.Tiles[0].Src.Y
+	maxX, maxY := minX+l.Tiles[0].Src.W, minY+l.Tiles[0].Src.H
+	for _, t := range l.Tiles[1:] {
+		minX = min(minX, t.Src.X)
+		minY = min(minY, t.Src.Y)
+		maxX = max(maxX, t.Src.X+t.Src.W)
+		maxY = max(maxY, t.Src.Y+t.Src.H)
+	}
+	return Rect{X: minX, Y: minY, W: maxX - minX, H: maxY - minY}
+}
+
+// evenRect normalizes a rectangle, clamps it inside the bounds and rounds its origin and
+// size down to even values.
+func evenRect(r Rect, boundsW, boundsH int) Rect {
+	r = Normalize(r)
+	boundsW -= boundsW % 2
+	boundsH -= boundsH % 2
+	r.W = clampInt(r.W-r.W%2, 0, boundsW)
+	r.H = clampInt(r.H-r.H%2, 0, boundsH)
+	r.X = clampInt(r.X-r.X%2, 0, boundsW-r.W)
+	r.Y = clampInt(r.Y-r.Y%2, 0, boundsH-r.H)
+	return r
+}
+
+// clampInt bounds v to [lo..hi].
+func clampInt(v, lo, hi int) int {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
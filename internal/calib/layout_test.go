@@ -0,0 +1,54 @@
+package calib
+
+import "testing"
+
+// TestStackLayout_StacksAndPads verifies regions are stacked vertically and padded to the widest tile.
+func TestStackLayout_StacksAndPads(t *testing.T) {
+	l := StackLayout([]Rect{
+		{X: 100, Y: 200, W: 400, H: 300},
+		{X: 0, Y: 800, W: 1200, H: 200},
+	}, 1920, 1080)
+	if l.W != 1200 || l.H != 500 || len(l.Tiles) != 2 {
+		t.Fatalf("unexpected layout: %+v", l)
+	}
+	if l.Tiles[1].Dst != (Rect{X: 0, Y: 300, W: 1200, H: 200}) {
+		t.Fatalf("unexpected second tile: %+v", l.Tiles[1])
+	}
+}
+
+// TestStackLayout_AlignsToEven verifies odd regions are aligned to even offsets and sizes.
+func TestStackLayout_AlignsToEven(t *testing.T) {
+	l := StackLayout([]Rect{{X: 11, Y: 21, W: 101, H: 51}}, 1920, 1080)
+	if len(l.Tiles) != 1 || l.Tiles[0].Src != (Rect{X: 10, Y: 20, W: 100, H: 50}) {
+		t.Fatalf("unexpected layout: %+v", l)
+	}
+}
+
+// TestLayoutLocate_MapsTilesBack verifies frame pixels map back to the source region of their tile.
+func TestLayoutLocate_MapsTilesBack(t *testing.T) {
+	l := StackLayout([]Rect{
+		{X: 100, Y: 200, W: 400, H: 300},
+		{X: 0, Y: 800, W: 1200, H: 200},
+	}, 1920, 1080)
+	x, y, ok := l.Locate(10, 20)
+	if !ok || x != 110 || y != 220 {
+		t.Fatalf("expected (110,220), got (%d,%d) ok=%t", x, y, ok)
+	}
+	x, y, ok = l.Locate(1000, 350)
+	if !ok || x != 1000 || y != 850 {
+		t.Fatalf("expected (1000,850), got (%d,%d) ok=%t", x, y, ok)
+	}
+	// Padding to the right of the narrow first tile clamps to its edge.
+	x, y, ok = l.Locate(1000, 10)
+	if !ok || x != 499 || y != 210 {
+		t.Fatalf("expected clamped (499,210), got (%d,%d) ok=%t", x, y, ok)
+	}
+}
+
+// TestStackLayout_ClampsToBounds verifies regions spilling off the capture are pulled back inside.
+func TestStackLayout_ClampsToBounds(t *testing.T) {
+	l := StackLayout([]Rect{{X: 1800, Y: 1000, W: 300, H: 200}}, 1920, 1080)
+	if len(l.Tiles) != 1 || l.Tiles[0].Src != (Rect{X: 1620, Y: 880, W: 300, H: 200}) {
+		t.Fatalf("unexpected layout: %+v", l)
+	}
+}
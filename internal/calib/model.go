@@ -15,6 +15,26 @@ type Calib struct {
 	PluginAbs    Rect
 	ChatRel      Rect
 	ScrollRel    Rect
+	// Layout lists monitor-relative regions stacked into one frame by the composite mode.
+	Layout []Rect `json:"Layout,omitempty"`
+	// Regions lists named plugin-relative areas with their input behavior.
+	Regions []Region `json:"Regions,omitempty"`
+	// Hotspots lists named plugin-relative points clickable with one tap.
+	Hotspots []Hotspot `json:"Hotspots,omitempty"`
+}
+
+// Clone returns a deep copy of the calibration data.
+func (c Calib) Clone() Calib {
+	if c.Layout != nil {
+		c.Layout = append([]Rect(nil), c.Layout...)
+	}
+	if c.Regions != nil {
+		c.Regions = append([]Region(nil), c.Regions...)
+	}
+	if c.Hotspots != nil {
+		c.Hotspots = append([]Hotspot(nil), c.Hotspots...)
+	}
+	return c
 }
 
 // Normalize returns a rectangle with non-negative width/height.
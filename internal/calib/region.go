@@ -0,0 +1,91 @@
+// Package calib handles calibration data and storage.
+package calib
+
+// RegionBehavior identifies how touches inside a named region are handled.
+type RegionBehavior string
+
+const (
+	// BehaviorClick forwards taps as clicks (the default outside any region).
+	BehaviorClick RegionBehavior = "click"
+	// BehaviorScroll turns drags into press-move-release scrolling.
+	BehaviorScroll RegionBehavior = "scroll"
+	// BehaviorType marks a text input: taps focus it and typed text targets it.
+	BehaviorType RegionBehavior = "type"
+	// BehaviorReadOnly ignores all input inside the region.
+	BehaviorReadOnly RegionBehavior = "readonly"
+)
+
+// Region is a named, plugin-relative rectangle with an input behavior.
+type Region struct {
+	Name     string
+	Behavior RegionBehavior
+	Rect     Rect
+}
+
+// ValidBehavior reports whether b is a known region behavior.
+func ValidBehavior(b RegionBehavior) bool {
+	switch b {
+	case BehaviorClick, BehaviorScroll, BehaviorType, BehaviorReadOnly:
+		return true
+	default:
+		return false
+	}
+}
+
+// SetRegion inserts or replaces the named region. An empty rect removes it.
+func (c *Calib) SetRegion(r Region) {
+	r.Rect = Normalize(r.Rect)
+	for i, existing := range c.Regions {
+		if existing.Name != r.Name {
+			continue
+		}
+		if r.Rect.W <= 0 || r.Rect.H <= 0 {
+			c.Regions = append(c.Regions[:i:i], c.Regions[i+1:]...)
+			return
+		}
+		c.Regions[i] = r
+		return
+	}
+	if r.Rect.W <= 0 || r.Rect.H <= 0 {
+		return
+	}
+	c.Regions = append(c.Regions, r)
+}
+
+// EffectiveRegions returns the named regions followed by the legacy scroll and chat
+// rectangles expressed as regions, in the order they should be hit-tested.
+func (c Calib) EffectiveRegions() []Region {
+	out := make([]Region, 0, len(c.Regions)+2)
+	out = append(out, c.Regions...)
+	if scroll := Normalize(c.ScrollRel); scroll.W > 0 && scroll.H > 0 {
+		out = append(out, Region{Name: "scroll", Behavior: BehaviorScroll, Rect: scroll})
+	}
+	if chat := Normalize(c.ChatRel); chat.W > 0 && chat.H > 0 {
+		out = append(out, Region{Name: "chat", Behavior: BehaviorType, Rect: chat})
+	}
+	return out
+}
+
+// RegionAt returns the first region containing the plugin-relative point.
+func RegionAt(regions []Region, relX, relY int) (Region, bool) {
+	for _, r := range regions {
+		if Contains(Normalize(r.Rect), relX, relY) {
+			return r, true
+		}
+	}
+	return Region{}, false
+}
+
+// TypeTarget returns the plugin-relative rect typed text should be sent to:
+// the legacy chat rect when calibrated, otherwise the first type region.
+func (c Calib) TypeTarget() (Rect, bool) {
+	if chat := Normalize(c.ChatRel); chat.W > 0 && chat.H > 0 {
+		return chat, true
+	}
+	for _, r := range c.Regions {
+		if r.Behavior == BehaviorType {
+			return Normalize(r.Rect), true
+		}
+	}
+	return Rect{}, false
+}
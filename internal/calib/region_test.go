@@ -0,0 +1,46 @@
+package calib
+
+import "testing"
+
+// TestSetRegion_InsertReplaceRemove verifies named regions are upserted and removed by empty rects.
+func TestSetRegion_InsertReplaceRemove(t *testing.T) {
+	var c Calib
+	c.SetRegion(Region{Name: "approve", Behavior: BehaviorClick, Rect: Rect{X: 1, Y: 2, W: 3, H: 4}})
+	c.SetRegion(Region{Name: "log", Behavior: BehaviorReadOnly, Rect: Rect{X: 5, Y: 6, W: 7, H: 8}})
+	c.SetRegion(Region{Name: "approve", Behavior: BehaviorType, Rect: Rect{X: 9, Y: 9, W: -3, H: 4}})
+	if len(c.Regions) != 2 || c.Regions[0].Behavior != BehaviorType || c.Regions[0].Rect != (Rect{X: 6, Y: 9, W: 3, H: 4}) {
+		t.Fatalf("unexpected regions after replace: %+v", c.Regions)
+	}
+	c.SetRegion(Region{Name: "approve"})
+	if len(c.Regions) != 1 || c.Regions[0].Name != "log" {
+		t.Fatalf("unexpected regions after remove: %+v", c.Regions)
+	}
+}
+
+// TestEffectiveRegions_NamedBeforeLegacy verifies named regions are hit-tested before legacy rects.
+func TestEffectiveRegions_NamedBeforeLegacy(t *testing.T) {
+	c := Calib{
+		ScrollRel: Rect{X: 0, Y: 0, W: 100, H: 100},
+		ChatRel:   Rect{X: 0, Y: 100, W: 100, H: 20},
+		Regions:   []Region{{Name: "banner", Behavior: BehaviorReadOnly, Rect: Rect{X: 0, Y: 0, W: 100, H: 10}}},
+	}
+	regions := c.EffectiveRegions()
+	if r, ok := RegionAt(regions, 50, 5); !ok || r.Name != "banner" {
+		t.Fatalf("expected banner, got %+v ok=%t", r, ok)
+	}
+	if r, ok := RegionAt(regions, 50, 50); !ok || r.Behavior != BehaviorScroll {
+		t.Fatalf("expected legacy scroll, got %+v ok=%t", r, ok)
+	}
+	if r, ok := RegionAt(regions, 50, 110); !ok || r.Behavior != BehaviorType {
+		t.Fatalf("expected legacy chat, got %+v ok=%t", r, ok)
+	}
+}
+
+// TestTypeTarget_FallsBackToTypeRegion verifies a type region is used when no chat rect exists.
+func TestTypeTarget_FallsBackToTypeRegion(t *testing.T) {
+	c := Calib{Regions: []Region{{Name: "prompt", Behavior: BehaviorType, Rect: Rect{X: 1, Y: 2, W: 3, H: 4}}}}
+	r, ok := c.TypeTarget()
+	if !ok || r != (Rect{X: 1, Y: 2, W: 3, H: 4}) {
+		t.Fatalf("unexpected type target %+v ok=%t", r, ok)
+	}
+}
@@ -1,7 +1,9 @@
 package calib
 
 import (
+	"os"
 	"path/filepath"
+	"reflect"
 	"testing"
 )
 
@@ -14,6 +16,9 @@ func TestSaveLoad_RoundTrip(t *testing.T) {
 		PluginAbs:    Rect{X: 1, Y: 2, W: 3, H: 4},
 		ChatRel:      Rect{X: 5, Y: 6, W: 7, H: 8},
 		ScrollRel:    Rect{X: 9, Y: 10, W: 11, H: 12},
+		Layout:       []Rect{{X: 13, Y: 14, W: 15, H: 16}},
+		Regions:      []Region{{Name: "terminal", Behavior: BehaviorReadOnly, Rect: Rect{X: 17, Y: 18, W: 19, H: 20}}},
+		Hotspots:     []Hotspot{{Name: "approve", X: 21, Y: 22}},
 	}
 
 	if err := Save(path, in); err != nil {
@@ -23,7 +28,7 @@ func TestSaveLoad_RoundTrip(t *testing.T) {
 	if err != nil {
 		t.Fatalf("Load failed: %v", err)
 	}
-	if out != in {
+	if !reflect.DeepEqual(out, in) {
 		t.Fatalf("expected %+v, got %+v", in, out)
 	}
 }
@@ -35,7 +40,23 @@ func TestLoad_MissingFile_ReturnsEmpty(t *testing.T) {
 	if err != nil {
 		t.Fatalf("Load returned error: %v", err)
 	}
-	if out != (Calib{}) {
+	if !reflect.DeepEqual(out, Calib{}) {
 		t.Fatalf("expected empty calib, got %+v", out)
 	}
 }
+
+// TestLoad_LegacyFileWithoutLayout verifies calibration files written before layouts/regions still load.
+func TestLoad_LegacyFileWithoutLayout(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "calib.json")
+	legacy := `{"MonitorIndex":1,"PluginAbs":{"X":1,"Y":2,"W":3,"H":4},"ChatRel":{"X":0,"Y":0,"W":0,"H":0},"ScrollRel":{"X":0,"Y":0,"W":0,"H":0}}`
+	if err := os.WriteFile(path, []byte(legacy), 0o600); err != nil {
+		t.Fatalf("write legacy file: %v", err)
+	}
+	out, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if out.PluginAbs != (Rect{X: 1, Y: 2, W: 3, H: 4}) || out.Layout != nil || out.Regions != nil || out.Hotspots != nil {
+		t.Fatalf("unexpected calib: %+v", out)
+	}
+}
@@ -0,0 +1,67 @@
+// Package calib handles calibration data and storage.
+package calib
+
+import "math"
+
+// minZoomSpan is the smallest normalized width/height accepted for a zoom viewport.
+const minZoomSpan = 0.05
+
+// Zoom describes a normalized sub-rectangle of the active crop (0..1 on both axes).
+// The zero value means "no zoom" (the whole crop is visible).
+type Zoom struct {
+	X float64
+	Y float64
+	W float64
+	H float64
+}
+
+// IsFull reports whether the zoom covers the whole crop.
+func (z Zoom) IsFull() bool {
+	z = NormalizeZoom(z)
+	return z.X == 0 && z.Y == 0 && z.W == 1 && z.H == 1
+}
+
+// NormalizeZoom clamps a zoom viewport into the unit square, enforcing a minimum span.
+func NormalizeZoom(z Zoom) Zoom {
+	if z.W <= 0 || z.H <= 0 || math.IsNaN(z.W) || math.IsNaN(z.H) || math.IsNaN(z.X) || math.IsNaN(z.Y) {
+		return Zoom{W: 1, H: 1}
+	}
+	z.W = clampFloat(z.W, minZoomSpan, 1)
+	z.H = clampFloat(z.H, minZoomSpan, 1)
+	z.X = clampFloat(z.X, 0, 1-z.W)
+	z.Y = clampFloat(z.Y, 0, 1-z.H)
+	return z
+}
+
+// ApplyZoom returns the sub-rectangle of base selected by the zoom viewport.
+func ApplyZoom(base Rect, z Zoom) Rect {
+	base = Normalize(base)
+	if z.IsFull() {
+		return base
+	}
+	z = NormalizeZoom(z)
+	out := Rect{
+		X: base.X + int(math.Round(z.X*float64(base.W))),
+		Y: base.Y + int(math.Round(z.Y*float64(base.H))),
+		W: int(math.Round(z.W * float64(base.W))),
+		H: int(math.Round(z.H * float64(base.H))),
+	}
+	if out.W < 1 {
+		out.W = 1
+	}
+	if out.H < 1 {
+		out.H = 1
+	}
+	return out
+}
+
+// clampFloat bounds v to [lo..hi].
+func clampFloat(v, lo, hi float64) float64 {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
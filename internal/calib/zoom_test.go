@@ -0,0 +1,29 @@
+package calib
+
+import "testing"
+
+// TestApplyZoom_ZeroValueIsFull verifies the zero zoom keeps the base rect.
+func TestApplyZoom_ZeroValueIsFull(t *testing.T) {
+	base := Rect{X: 100, Y: 200, W: 300, H: 400}
+	if out := ApplyZoom(base, Zoom{}); out != base {
+		t.Fatalf("expected %+v, got %+v", base, out)
+	}
+}
+
+// TestApplyZoom_SubRect verifies a zoom selects the expected sub-rectangle.
+func TestApplyZoom_SubRect(t *testing.T) {
+	base := Rect{X: 100, Y: 200, W: 300, H: 400}
+	out := ApplyZoom(base, Zoom{X: 0.5, Y: 0.25, W: 0.5, H: 0.5})
+	want := Rect{X: 250, Y: 300, W: 150, H: 200}
+	if out != want {
+		t.Fatalf("expected %+v, got %+v", want, out)
+	}
+}
+
+// TestNormalizeZoom_ClampsIntoUnitSquare verifies out-of-range viewports are clamped.
+func TestNormalizeZoom_ClampsIntoUnitSquare(t *testing.T) {
+	z := NormalizeZoom(Zoom{X: 0.9, Y: -1, W: 0.5, H: 0.001})
+	if z.X != 0.5 || z.Y != 0 || z.W != 0.5 || z.H != minZoomSpan {
+		t.Fatalf("unexpected normalized zoom: %+v", z)
+	}
+}
@@ -4,6 +4,8 @@ package config
 import (
 	"errors"
 	"fmt"
+	"net"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strconv"
@@ -11,56 +13,114 @@ import (
 )
 
 const (
-	defaultListenAddr      = "0.0.0.0:8787"
-	defaultDataDir         = "./data"
-	defaultFFmpegPath      = "ffmpeg"
-	defaultCapture         = "gdigrab"
-	defaultFPS             = 30
-	defaultBitrateKbps     = 6000
-	defaultMonitorIdx      = 1
-	defaultMJPEGEnabled    = true
-	defaultMJPEGIntervalMs = 120
-	defaultMJPEGQuality    = 60
-	defaultScrollTickMs    = 50
-	defaultScrollMaxDelta  = 240
+	defaultListenAddr       = "0.0.0.0:8787"
+	defaultDataDir          = "./data"
+	defaultFFmpegPath       = "ffmpeg"
+	defaultCapture          = "gdigrab"
+	defaultFPS              = 30
+	defaultBitrateKbps      = 6000
+	defaultMonitorIdx       = 1
+	defaultMJPEGEnabled     = true
+	defaultMJPEGIntervalMs  = 120
+	defaultMJPEGQuality     = 60
+	defaultMJPEGEncoder     = "go"
+	defaultScrollTickMs     = 50
+	defaultScrollMaxDelta   = 240
+	defaultTerminalCommand  = "codex"
+	defaultVideoCodecs      = "h264"
+	defaultPushSubject      = "mailto:deskslice@localhost"
+	defaultPushEvents       = "pipeline,viewer,idle"
+	defaultWebhookRetries   = 3
+	defaultTimelineSize     = 240
+	defaultTimelineChange   = 12
+	defaultActivitySampleMs = 500
+	defaultActivityDelta    = 24
+	defaultActivityPermille = 5
+	defaultActivityIdleSec  = 8
 )
 
 // Config holds runtime configuration values.
 type Config struct {
-	ListenAddr      string
-	PasswordMode    bool
-	UIPassword      string
-	DataDir         string
-	CalibPath       string
-	FFmpegPath      string
-	CaptureDriver   string
-	FPS             int
-	BitrateKbps     int
-	MonitorIndex    int
-	MJPEGEnabled    bool
-	MJPEGIntervalMs int
-	MJPEGQuality    int
-	ScrollTickMs    int
-	ScrollMaxDelta  int
+	ListenAddr       string
+	PasswordMode     bool
+	UIPassword       string
+	DataDir          string
+	CalibPath        string
+	FFmpegPath       string
+	CaptureDriver    string
+	FPS              int
+	BitrateKbps      int
+	MonitorIndex     int
+	MJPEGEnabled     bool
+	MJPEGIntervalMs  int
+	MJPEGQuality     int
+	MJPEGEncoder     string
+	MJPEGTiles       bool
+	ScrollTickMs     int
+	ScrollMaxDelta   int
+	TerminalCommand  string
+	VideoCodecs      []string
+	Simulcast        bool
+	SharedPipeline   bool
+	LiveCrop         bool
+	TimelineSec      int
+	TimelineSize     int
+	TimelineChange   int
+	Activity         bool
+	ActivitySampleMs int
+	ActivityDelta    int
+	ActivityPermille int
+	ActivityIdleSec  int
+	PushEnabled      bool
+	PushSubject      string
+	PushEvents       []string
+	WebhookURLs      []string
+	WebhookSecret    string
+	WebhookEvents    []string
+	WebhookRetries   int
+	ICEServers       []ICEServer
+	ICEUDPPortMin    int
+	ICEUDPPortMax    int
+	ICEUDPMuxPort    int
+	ICENAT1To1IPs    []string
+	ICETCPPort       int
+	TURNPort         int
+	TURNPublicIP     string
+}
+
+// ICEServer is a STUN or TURN server offered to the WebRTC ICE agent.
+type ICEServer struct {
+	URL        string
+	Username   string
+	Credential string
 }
 
 // Load reads configuration from ./data/.env and environment variables.
 func Load() (Config, error) {
 	cfg := Config{
-		ListenAddr:      defaultListenAddr,
-		PasswordMode:    true,
-		DataDir:         defaultDataDir,
-		CalibPath:       filepath.Join(defaultDataDir, "calib.json"),
-		FFmpegPath:      defaultFFmpegPath,
-		CaptureDriver:   defaultCapture,
-		FPS:             defaultFPS,
-		BitrateKbps:     defaultBitrateKbps,
-		MonitorIndex:    defaultMonitorIdx,
-		MJPEGEnabled:    defaultMJPEGEnabled,
-		MJPEGIntervalMs: defaultMJPEGIntervalMs,
-		MJPEGQuality:    defaultMJPEGQuality,
-		ScrollTickMs:    defaultScrollTickMs,
-		ScrollMaxDelta:  defaultScrollMaxDelta,
+		ListenAddr:       defaultListenAddr,
+		PasswordMode:     true,
+		DataDir:          defaultDataDir,
+		CalibPath:        filepath.Join(defaultDataDir, "calib.json"),
+		FFmpegPath:       defaultFFmpegPath,
+		CaptureDriver:    defaultCapture,
+		FPS:              defaultFPS,
+		BitrateKbps:      defaultBitrateKbps,
+		MonitorIndex:     defaultMonitorIdx,
+		MJPEGEnabled:     defaultMJPEGEnabled,
+		MJPEGIntervalMs:  defaultMJPEGIntervalMs,
+		MJPEGQuality:     defaultMJPEGQuality,
+		MJPEGEncoder:     defaultMJPEGEncoder,
+		ScrollTickMs:     defaultScrollTickMs,
+		ScrollMaxDelta:   defaultScrollMaxDelta,
+		TerminalCommand:  defaultTerminalCommand,
+		TimelineSize:     defaultTimelineSize,
+		TimelineChange:   defaultTimelineChange,
+		ActivitySampleMs: defaultActivitySampleMs,
+		ActivityDelta:    defaultActivityDelta,
+		ActivityPermille: defaultActivityPermille,
+		ActivityIdleSec:  defaultActivityIdleSec,
+		WebhookRetries:   defaultWebhookRetries,
 	}
 
 	if err := loadEnvFile(filepath.Join(cfg.DataDir, ".env")); err != nil {
@@ -74,6 +134,54 @@ func Load() (Config, error) {
 	cfg.CaptureDriver = normalizeCaptureDriver(envString("CAPTURE_DRIVER", cfg.CaptureDriver))
 	cfg.PasswordMode = envBoolAny(true, "PASSWORD_MODE", "password_mode")
 	cfg.UIPassword = strings.TrimSpace(os.Getenv("UI_PASSWORD"))
+	cfg.TerminalCommand = envString("TERMINAL_COMMAND", cfg.TerminalCommand)
+
+	for _, codec := range splitList(envString("VIDEO_CODECS", defaultVideoCodecs)) {
+		codec = strings.ToLower(codec)
+		switch codec {
+		case "h264", "vp8", "vp9", "av1":
+			cfg.VideoCodecs = append(cfg.VideoCodecs, codec)
+		default:
+			return Config{}, fmt.Errorf("VIDEO_CODECS: unsupported codec %q", codec)
+		}
+	}
+
+	for _, event := range splitList(envString("PUSH_EVENTS", defaultPushEvents)) {
+		event = strings.ToLower(event)
+		switch event {
+		case "pipeline", "viewer", "idle", "active":
+			cfg.PushEvents = append(cfg.PushEvents, event)
+		default:
+			return Config{}, fmt.Errorf("PUSH_EVENTS: unknown event %q", event)
+		}
+	}
+
+	for _, raw := range envList("WEBHOOK_URLS") {
+		u, err := url.Parse(raw)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return Config{}, fmt.Errorf("WEBHOOK_URLS: invalid URL %q", raw)
+		}
+		cfg.WebhookURLs = append(cfg.WebhookURLs, raw)
+	}
+	cfg.WebhookSecret = os.Getenv("WEBHOOK_SECRET")
+	for _, event := range envList("WEBHOOK_EVENTS") {
+		event = strings.ToLower(event)
+		switch event {
+		case "viewer.connected", "viewer.disconnected", "mode.changed", "monitor.changed",
+			"calib.saved", "input.toggled", "ffmpeg.crashed", "pipeline.failed":
+			cfg.WebhookEvents = append(cfg.WebhookEvents, event)
+		default:
+			return Config{}, fmt.Errorf("WEBHOOK_EVENTS: unknown event %q", event)
+		}
+	}
+	webhookRetries, err := envInt("WEBHOOK_RETRIES", cfg.WebhookRetries)
+	if err != nil {
+		return Config{}, err
+	}
+	if webhookRetries < 0 || webhookRetries > 10 {
+		return Config{}, fmt.Errorf("WEBHOOK_RETRIES must be 0-10")
+	}
+	cfg.WebhookRetries = webhookRetries
 
 	fps, err := envInt("FPS", cfg.FPS)
 	if err != nil {
@@ -94,6 +202,13 @@ func Load() (Config, error) {
 	cfg.MonitorIndex = monitorIdx
 
 	cfg.MJPEGEnabled = envBool("MJPEG_ENABLED", cfg.MJPEGEnabled)
+	cfg.Simulcast = envBool("SIMULCAST", cfg.Simulcast)
+	cfg.SharedPipeline = envBool("SHARED_PIPELINE", cfg.SharedPipeline)
+	cfg.LiveCrop = envBool("LIVE_CROP", cfg.LiveCrop)
+	cfg.MJPEGTiles = envBool("MJPEG_TILES", cfg.MJPEGTiles)
+	cfg.Activity = envBool("ACTIVITY_DETECT", cfg.Activity)
+	cfg.PushEnabled = envBool("PUSH_ENABLED", cfg.PushEnabled)
+	cfg.PushSubject = envString("PUSH_SUBJECT", defaultPushSubject)
 
 	mjpegInterval, err := envInt("MJPEG_INTERVAL_MS", cfg.MJPEGIntervalMs)
 	if err != nil {
@@ -110,6 +225,17 @@ func Load() (Config, error) {
 	}
 	cfg.MJPEGQuality = mjpegQuality
 
+	cfg.MJPEGEncoder = strings.ToLower(envString("MJPEG_ENCODER", cfg.MJPEGEncoder))
+	if cfg.MJPEGEncoder != "go" && cfg.MJPEGEncoder != "ffmpeg" {
+		return Config{}, fmt.Errorf("MJPEG_ENCODER must be go or ffmpeg")
+	}
+	if cfg.MJPEGTiles && cfg.MJPEGEncoder != "go" {
+		return Config{}, fmt.Errorf("MJPEG_TILES requires MJPEG_ENCODER=go")
+	}
+	if cfg.Activity && cfg.MJPEGEncoder != "go" {
+		return Config{}, fmt.Errorf("ACTIVITY_DETECT requires MJPEG_ENCODER=go")
+	}
+
 	scrollTick, err := envInt("SCROLL_OVERLAY_TICK_MS", cfg.ScrollTickMs)
 	if err != nil {
 		return Config{}, err
@@ -128,6 +254,73 @@ func Load() (Config, error) {
 	}
 	cfg.ScrollMaxDelta = scrollMaxDelta
 
+	timelineSec, err := envInt("TIMELINE_INTERVAL_SEC", cfg.TimelineSec)
+	if err != nil {
+		return Config{}, err
+	}
+	if timelineSec < 0 {
+		return Config{}, fmt.Errorf("TIMELINE_INTERVAL_SEC must be >= 0")
+	}
+	cfg.TimelineSec = timelineSec
+
+	timelineSize, err := envInt("TIMELINE_SIZE", cfg.TimelineSize)
+	if err != nil {
+		return Config{}, err
+	}
+	if timelineSize <= 0 {
+		return Config{}, fmt.Errorf("TIMELINE_SIZE must be > 0")
+	}
+	cfg.TimelineSize = timelineSize
+
+	timelineChange, err := envInt("TIMELINE_CHANGE_PCT", cfg.TimelineChange)
+	if err != nil {
+		return Config{}, err
+	}
+	if timelineChange < 0 || timelineChange > 100 {
+		return Config{}, fmt.Errorf("TIMELINE_CHANGE_PCT must be 0-100")
+	}
+	cfg.TimelineChange = timelineChange
+
+	activitySample, err := envInt("ACTIVITY_SAMPLE_MS", cfg.ActivitySampleMs)
+	if err != nil {
+		return Config{}, err
+	}
+	if activitySample <= 0 {
+		return Config{}, fmt.Errorf("ACTIVITY_SAMPLE_MS must be > 0")
+	}
+	cfg.ActivitySampleMs = activitySample
+
+	activityDelta, err := envInt("ACTIVITY_PIXEL_DELTA", cfg.ActivityDelta)
+	if err != nil {
+		return Config{}, err
+	}
+	if activityDelta <= 0 || activityDelta > 255 {
+		return Config{}, fmt.Errorf("ACTIVITY_PIXEL_DELTA must be 1-255")
+	}
+	cfg.ActivityDelta = activityDelta
+
+	activityPermille, err := envInt("ACTIVITY_CHANGED_PERMILLE", cfg.ActivityPermille)
+	if err != nil {
+		return Config{}, err
+	}
+	if activityPermille <= 0 || activityPermille > 1000 {
+		return Config{}, fmt.Errorf("ACTIVITY_CHANGED_PERMILLE must be 1-1000")
+	}
+	cfg.ActivityPermille = activityPermille
+
+	activityIdle, err := envInt("ACTIVITY_IDLE_SEC", cfg.ActivityIdleSec)
+	if err != nil {
+		return Config{}, err
+	}
+	if activityIdle <= 0 {
+		return Config{}, fmt.Errorf("ACTIVITY_IDLE_SEC must be > 0")
+	}
+	cfg.ActivityIdleSec = activityIdle
+
+	if err := loadICE(&cfg); err != nil {
+		return Config{}, err
+	}
+
 	if !cfg.PasswordMode {
 		// Dev mode: bypass auth gates entirely.
 		cfg.UIPassword = ""
@@ -140,6 +333,85 @@ func Load() (Config, error) {
 	return cfg, nil
 }
 
+// loadICE reads the ICE server list, UDP port range, muxed ports, NAT 1:1 IPs and
+// embedded TURN relay settings.
+func loadICE(cfg *Config) error {
+	servers, err := parseICEServers(os.Getenv("ICE_SERVERS"))
+	if err != nil {
+		return err
+	}
+	cfg.ICEServers = servers
+
+	for _, ip := range envList("ICE_NAT1TO1_IPS") {
+		if net.ParseIP(ip) == nil {
+			return fmt.Errorf("ICE_NAT1TO1_IPS: invalid IP %q", ip)
+		}
+		cfg.ICENAT1To1IPs = append(cfg.ICENAT1To1IPs, ip)
+	}
+
+	ports := []struct {
+		key string
+		dst *int
+	}{
+		{"ICE_UDP_PORT_MIN", &cfg.ICEUDPPortMin},
+		{"ICE_UDP_PORT_MAX", &cfg.ICEUDPPortMax},
+		{"ICE_UDP_MUX_PORT", &cfg.ICEUDPMuxPort},
+		{"ICE_TCP_PORT", &cfg.ICETCPPort},
+		{"TURN_PORT", &cfg.TURNPort},
+	}
+	for _, port := range ports {
+		value, err := envInt(port.key, 0)
+		if err != nil {
+			return err
+		}
+		if value < 0 || value > 65535 {
+			return fmt.Errorf("%s must be 0-65535", port.key)
+		}
+		*port.dst = value
+	}
+	if (cfg.ICEUDPPortMin == 0) != (cfg.ICEUDPPortMax == 0) {
+		return errors.New("ICE_UDP_PORT_MIN and ICE_UDP_PORT_MAX must be set together")
+	}
+	if cfg.ICEUDPPortMin > cfg.ICEUDPPortMax {
+		return errors.New("ICE_UDP_PORT_MIN must be <= ICE_UDP_PORT_MAX")
+	}
+
+	cfg.TURNPublicIP = strings.TrimSpace(os.Getenv("TURN_PUBLIC_IP"))
+	if cfg.TURNPort > 0 && net.ParseIP(cfg.TURNPublicIP) == nil {
+		return errors.New("TURN_PUBLIC_IP must be a valid IP when TURN_PORT is set")
+	}
+	return nil
+}
+
+// parseICEServers parses a comma-separated list of "url" or "url|username|credential" entries.
+func parseICEServers(raw string) ([]ICEServer, error) {
+	var out []ICEServer
+	for _, entry := range splitList(raw) {
+		parts := strings.Split(entry, "|")
+		server := ICEServer{URL: strings.TrimSpace(parts[0])}
+		switch len(parts) {
+		case 1:
+		case 3:
+			server.Username = strings.TrimSpace(parts[1])
+			server.Credential = strings.TrimSpace(parts[2])
+		default:
+			return nil, fmt.Errorf("ICE_SERVERS: entry %q must be url or url|username|credential", entry)
+		}
+		scheme, _, _ := strings.Cut(server.URL, ":")
+		switch strings.ToLower(scheme) {
+		case "stun", "stuns":
+		case "turn", "turns":
+			if server.Username == "" || server.Credential == "" {
+				return nil, fmt.Errorf("ICE_SERVERS: %s needs a username and credential", server.URL)
+			}
+		default:
+			return nil, fmt.Errorf("ICE_SERVERS: unsupported url %q", server.URL)
+		}
+		out = append(out, server)
+	}
+	return out, nil
+}
+
 // normalizeCaptureDriver ensures a supported capture driver value.
 func normalizeCaptureDriver(value string) string {
 	switch strings.ToLower(strings.TrimSpace(value)) {
@@ -171,6 +443,22 @@ func envInt(key string, def int) (int, error) {
 	return value, nil
 }
 
+// envList returns the comma-separated values of an env var, skipping blanks.
+func envList(key string) []string {
+	return splitList(os.Getenv(key))
+}
+
+// splitList splits a comma-separated string into trimmed, non-empty values.
+func splitList(raw string) []string {
+	var out []string
+	for _, item := range strings.Split(raw, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
 // envBool returns a bool env override when present, otherwise a default.
 func envBool(key string, def bool) bool {
 	raw := strings.TrimSpace(os.Getenv(key))
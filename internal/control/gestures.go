@@ -34,28 +34,42 @@ func (g *GestureState) SetNowFunc(fn func() time.Time) {
 	}
 }
 
-// HandleDown processes a pointer down event.
+// HandleDown processes a pointer down event with only the legacy scroll rectangle.
 func (g *GestureState) HandleDown(inputEnabled bool, pointerID int, absX, absY int, plugin calib.Rect, scrollRel calib.Rect) []Action {
+	regions := []calib.Region{{Name: "scroll", Behavior: calib.BehaviorScroll, Rect: scrollRel}}
+	return g.HandleDownInRegions(inputEnabled, pointerID, absX, absY, plugin, regions)
+}
+
+// HandleDownInRegions processes a pointer down event, resolving the behavior from the
+// first plugin-relative region containing the point (click-through when none matches).
+func (g *GestureState) HandleDownInRegions(inputEnabled bool, pointerID int, absX, absY int, plugin calib.Rect, regions []calib.Region) []Action {
 	if !inputEnabled {
 		return nil
 	}
 
 	plugin = calib.Normalize(plugin)
-	scrollRel = calib.Normalize(scrollRel)
 	relX := absX - plugin.X
 	relY := absY - plugin.Y
 
-	if calib.Contains(scrollRel, relX, relY) {
+	behavior := calib.BehaviorClick
+	if region, ok := calib.RegionAt(regions, relX, relY); ok {
+		behavior = region.Behavior
+	}
+
+	g.dragActive = false
+	switch behavior {
+	case calib.BehaviorScroll:
 		g.dragActive = true
 		g.dragPointer = pointerID
 		g.lastMoveAt = g.now()
 		g.lastX = absX
 		g.lastY = absY
 		return []Action{{Type: ActLeftDown, X: absX, Y: absY}}
+	case calib.BehaviorReadOnly:
+		return nil
+	default:
+		return []Action{{Type: ActClick, X: absX, Y: absY}}
 	}
-
-	g.dragActive = false
-	return []Action{{Type: ActClick, X: absX, Y: absY}}
 }
 
 // HandleMove processes a pointer move event.
@@ -94,6 +94,28 @@ func TestInputDisabled_NoActions(t *testing.T) {
 	}
 }
 
+// TestRegions_ReadOnlyBlocksAndScrollDrags verifies named region behaviors drive touch handling.
+func TestRegions_ReadOnlyBlocksAndScrollDrags(t *testing.T) {
+	g := NewGestureState()
+	plugin := calib.Rect{X: 100, Y: 100, W: 200, H: 200}
+	regions := []calib.Region{
+		{Name: "log", Behavior: calib.BehaviorReadOnly, Rect: calib.Rect{X: 0, Y: 0, W: 200, H: 50}},
+		{Name: "diff", Behavior: calib.BehaviorScroll, Rect: calib.Rect{X: 0, Y: 50, W: 200, H: 100}},
+		{Name: "prompt", Behavior: calib.BehaviorType, Rect: calib.Rect{X: 0, Y: 150, W: 200, H: 50}},
+	}
+
+	if actions := g.HandleDownInRegions(true, 1, 120, 120, plugin, regions); len(actions) != 0 {
+		t.Fatalf("expected read-only region to swallow input, got %#v", actions)
+	}
+	if actions := g.HandleDownInRegions(true, 1, 120, 200, plugin, regions); len(actions) != 1 || actions[0].Type != ActLeftDown {
+		t.Fatalf("expected left_down in scroll region, got %#v", actions)
+	}
+	g.HandleUp(true, 1, 120, 200)
+	if actions := g.HandleDownInRegions(true, 1, 120, 270, plugin, regions); len(actions) != 1 || actions[0].Type != ActClick {
+		t.Fatalf("expected click in type region, got %#v", actions)
+	}
+}
+
 // TestTypeEnter_EmitsClickTypeEnterSequence verifies type/enter behavior.
 func TestTypeEnter_EmitsClickTypeEnterSequence(t *testing.T) {
 	chat := calib.Rect{X: 10, Y: 20, W: 100, H: 40}
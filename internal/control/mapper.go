@@ -22,6 +22,17 @@ func NormToAbsRun(xn, yn float64, plugin calib.Rect) (int, int) {
 	return plugin.X + normToPixels(xn, plugin.W), plugin.Y + normToPixels(yn, plugin.H)
 }
 
+// NormToAbsLayout maps normalized coordinates in a composite frame to absolute coords of the tile's source.
+func NormToAbsLayout(xn, yn float64, layout calib.Layout, m monitor.Monitor) (int, int, bool) {
+	xn = clamp01(xn)
+	yn = clamp01(yn)
+	x, y, ok := layout.Locate(normToPixels(xn, layout.W), normToPixels(yn, layout.H))
+	if !ok {
+		return 0, 0, false
+	}
+	return m.X + x, m.Y + y, true
+}
+
 // normToPixels converts a normalized [0..1] coordinate into a pixel offset inside a span.
 func normToPixels(norm float64, span int) int {
 	if span <= 1 {
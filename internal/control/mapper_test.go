@@ -69,3 +69,23 @@ func TestNormToAbs_ClampOutOfRange(t *testing.T) {
 		t.Fatalf("expected clamped (100,599), got (%d,%d)", x, y)
 	}
 }
+
+// TestNormToAbsLayout_SecondTile verifies composite coordinates resolve to the right tile source.
+func TestNormToAbsLayout_SecondTile(t *testing.T) {
+	m := monitor.Monitor{X: 1920, Y: 0, W: 1920, H: 1080}
+	layout := calib.StackLayout([]calib.Rect{
+		{X: 100, Y: 200, W: 400, H: 300},
+		{X: 0, Y: 800, W: 400, H: 100},
+	}, m.W, m.H)
+	x, y, ok := NormToAbsLayout(0, 1, layout, m)
+	if !ok || x != 1920 || y != 899 {
+		t.Fatalf("expected (1920,899), got (%d,%d) ok=%t", x, y, ok)
+	}
+}
+
+// TestNormToAbsLayout_Empty verifies an empty layout cannot be mapped.
+func TestNormToAbsLayout_Empty(t *testing.T) {
+	if _, _, ok := NormToAbsLayout(0.5, 0.5, calib.Layout{}, monitor.Monitor{W: 100, H: 100}); ok {
+		t.Fatalf("expected empty layout to fail")
+	}
+}
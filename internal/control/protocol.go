@@ -9,21 +9,49 @@ type Rect struct {
 	H int `json:"h"`
 }
 
+// NormRect represents a normalized (0..1) rectangle sent by the client UI.
+type NormRect struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+	W float64 `json:"w"`
+	H float64 `json:"h"`
+}
+
 // Message is a control websocket payload.
 type Message struct {
-	T       string  `json:"t"`
-	ID      int     `json:"id,omitempty"`
-	X       float64 `json:"x,omitempty"`
-	Y       float64 `json:"y,omitempty"`
-	DX      int     `json:"dx,omitempty"`
-	DY      int     `json:"dy,omitempty"`
-	WheelX  int     `json:"wheelX,omitempty"`
-	WheelY  int     `json:"wheelY,omitempty"`
-	Text    string  `json:"text,omitempty"`
-	Mode    string  `json:"mode,omitempty"`
-	Video   string  `json:"video,omitempty"`
-	Idx     int     `json:"idx,omitempty"`
-	Step    string  `json:"step,omitempty"`
-	Rect    *Rect   `json:"rect,omitempty"`
-	Enabled *bool   `json:"enabled,omitempty"`
+	T        string    `json:"t"`
+	ID       int       `json:"id,omitempty"`
+	X        float64   `json:"x,omitempty"`
+	Y        float64   `json:"y,omitempty"`
+	DX       int       `json:"dx,omitempty"`
+	DY       int       `json:"dy,omitempty"`
+	WheelX   int       `json:"wheelX,omitempty"`
+	WheelY   int       `json:"wheelY,omitempty"`
+	Text     string    `json:"text,omitempty"`
+	Key      string    `json:"key,omitempty"`
+	Mode     string    `json:"mode,omitempty"`
+	Video    string    `json:"video,omitempty"`
+	Layer    string    `json:"layer,omitempty"`
+	Idx      int       `json:"idx,omitempty"`
+	Step     string    `json:"step,omitempty"`
+	Name     string    `json:"name,omitempty"`
+	Behavior string    `json:"behavior,omitempty"`
+	Rect     *Rect     `json:"rect,omitempty"`
+	Zoom     *NormRect `json:"zoom,omitempty"`
+	Enabled  *bool     `json:"enabled,omitempty"`
+	TS       float64   `json:"ts,omitempty"`
+	Probe    int       `json:"probe,omitempty"`
+	RTTMs    float64   `json:"rttMs,omitempty"`
+	InputMs  float64   `json:"inputMs,omitempty"`
+}
+
+// Event is a message sent from the server to the control client.
+type Event struct {
+	T        string   `json:"t"`
+	TS       float64  `json:"ts,omitempty"`
+	Probe    int      `json:"probe,omitempty"`
+	EncodeMs float64  `json:"encodeMs,omitempty"`
+	HostMs   float64  `json:"hostMs,omitempty"`
+	State    string   `json:"state,omitempty"`
+	Regions  []string `json:"regions,omitempty"`
 }
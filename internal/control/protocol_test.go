@@ -92,3 +92,15 @@ func TestProtocol_Click(t *testing.T) {
 		t.Fatalf("unexpected message: %+v", msg)
 	}
 }
+
+// TestProtocol_CalibRegion verifies decoding a named region calibration message.
+func TestProtocol_CalibRegion(t *testing.T) {
+	var msg Message
+	payload := `{"t":"calibRect","step":"region","name":"terminal","behavior":"readonly","rect":{"x":1,"y":2,"w":3,"h":4}}`
+	if err := json.Unmarshal([]byte(payload), &msg); err != nil {
+		t.Fatalf("unmarshal failed: %v", err)
+	}
+	if msg.Step != "region" || msg.Name != "terminal" || msg.Behavior != "readonly" || msg.Rect == nil || msg.Rect.W != 3 {
+		t.Fatalf("unexpected message: %+v", msg)
+	}
+}
@@ -2,14 +2,19 @@
 package control
 
 import (
+	"encoding/json"
 	"fmt"
+	"log"
 	"net/http"
 	"sync"
 	"time"
 
 	"github.com/frudas24/deskslice/internal/calib"
+	"github.com/frudas24/deskslice/internal/events"
+	"github.com/frudas24/deskslice/internal/latency"
 	"github.com/frudas24/deskslice/internal/monitor"
 	"github.com/frudas24/deskslice/internal/session"
+	"github.com/frudas24/deskslice/internal/terminal"
 	"github.com/frudas24/deskslice/internal/wininput"
 	"github.com/gorilla/websocket"
 )
@@ -17,9 +22,30 @@ import (
 // MonitorProvider returns the current list of monitors.
 type MonitorProvider func() ([]monitor.Monitor, error)
 
+// TerminalInput receives keystrokes while the session streams a terminal instead of video.
+type TerminalInput interface {
+	WriteInput(p []byte) error
+}
+
+// FrameProbe reports when the first video frame started after an instant has been forwarded.
+type FrameProbe interface {
+	NextFrameSent(after time.Time) <-chan time.Time
+	CancelFrameProbe(ch <-chan time.Time)
+}
+
+// LayerSelector chooses which WebRTC quality layer is forwarded (auto, high or low).
+type LayerSelector interface {
+	SetLayer(mode string) error
+}
+
+// probeTimeout bounds how long a marked click waits for a frame (none arrive in MJPEG mode).
+const probeTimeout = 2 * time.Second
+
 // Server handles websocket control input.
 type Server struct {
 	mu               sync.Mutex
+	dispatchMu       sync.Mutex
+	writeMu          sync.Mutex
 	upgrader         websocket.Upgrader
 	session          *session.Session
 	injector         wininput.Injector
@@ -27,6 +53,11 @@ type Server struct {
 	listMonitors     MonitorProvider
 	onPipelineChange func(reason string)
 	saveCalib        func(calib.Calib) error
+	terminal         TerminalInput
+	frameProbe       FrameProbe
+	layers           LayerSelector
+	latency          *latency.Recorder
+	bus              *events.Bus
 	conn             *websocket.Conn
 }
 
@@ -47,6 +78,35 @@ func NewServer(sess *session.Session, injector wininput.Injector, listMonitors M
 	}
 }
 
+// SetTerminal routes typing messages to a PTY-hosted command while the terminal video mode is active.
+func (s *Server) SetTerminal(t TerminalInput) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.terminal = t
+}
+
+// SetLatencyProbe enables latency measurement: ping/pong, marked clicks and client reports.
+func (s *Server) SetLatencyProbe(probe FrameProbe, rec *latency.Recorder) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.frameProbe = probe
+	s.latency = rec
+}
+
+// SetLayerSelector enables the setLayer message for the dual-quality WebRTC pipeline.
+func (s *Server) SetLayerSelector(l LayerSelector) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.layers = l
+}
+
+// SetEventBus publishes viewer connections and mode, monitor and input changes to bus.
+func (s *Server) SetEventBus(bus *events.Bus) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.bus = bus
+}
+
 // ServeHTTP upgrades the connection and processes control messages.
 func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if !s.session.IsAuthenticated() {
@@ -62,6 +122,8 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		_ = conn.Close()
 		return
 	}
+	s.publish(events.ViewerConnected, map[string]any{"remote": r.RemoteAddr})
+	defer s.publish(events.ViewerDisconnected, map[string]any{"remote": r.RemoteAddr})
 	defer s.cleanupConn(conn)
 
 	for {
@@ -69,12 +131,112 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		if err := conn.ReadJSON(&msg); err != nil {
 			return
 		}
-		if err := s.handleMessage(msg); err != nil {
+		if err := s.dispatch(msg); err != nil {
 			return
 		}
 	}
 }
 
+// HandleData processes a JSON control message received over a WebRTC data channel.
+// Errors are logged rather than tearing down the peer, mirroring a dropped websocket message.
+func (s *Server) HandleData(data []byte) {
+	if !s.session.IsAuthenticated() {
+		return
+	}
+	var msg Message
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return
+	}
+	if err := s.dispatch(msg); err != nil {
+		log.Printf("control: data channel %s failed: %v", msg.T, err)
+	}
+}
+
+// dispatch serializes messages arriving over the websocket and data channels, since the
+// gesture state is not safe for concurrent use.
+func (s *Server) dispatch(msg Message) error {
+	s.dispatchMu.Lock()
+	defer s.dispatchMu.Unlock()
+	received := time.Now()
+	if err := s.handleMessage(msg); err != nil {
+		return err
+	}
+	if msg.Probe != 0 && (msg.T == "click" || msg.T == "down") {
+		s.startProbe(msg.Probe, received, time.Now())
+	}
+	return nil
+}
+
+// startProbe reports, asynchronously, when the first frame started after a marked click was sent.
+func (s *Server) startProbe(id int, received, injected time.Time) {
+	s.mu.Lock()
+	probe, rec := s.frameProbe, s.latency
+	s.mu.Unlock()
+	if probe == nil {
+		return
+	}
+	ch := probe.NextFrameSent(injected)
+	go func() {
+		timer := time.NewTimer(probeTimeout)
+		defer timer.Stop()
+		select {
+		case sent := <-ch:
+			encode := durationMs(sent.Sub(injected))
+			rec.Observe(latency.SeriesEncode, encode)
+			_ = s.reply(Event{T: "probe", Probe: id, EncodeMs: encode, HostMs: durationMs(sent.Sub(received))})
+		case <-timer.C:
+			probe.CancelFrameProbe(ch)
+		}
+	}()
+}
+
+// handleLatencyReport records client-side measurements.
+func (s *Server) handleLatencyReport(msg Message) {
+	s.mu.Lock()
+	rec := s.latency
+	s.mu.Unlock()
+	if msg.RTTMs > 0 {
+		rec.Observe(latency.SeriesRTT, msg.RTTMs)
+	}
+	if msg.InputMs > 0 {
+		rec.Observe(latency.SeriesInput, msg.InputMs)
+	}
+}
+
+// handleSetLayer forwards a quality layer choice to the publisher.
+func (s *Server) handleSetLayer(mode string) error {
+	s.mu.Lock()
+	layers := s.layers
+	s.mu.Unlock()
+	if layers == nil {
+		return nil
+	}
+	return layers.SetLayer(mode)
+}
+
+// reply sends an event to the active websocket client, if any.
+func (s *Server) reply(ev Event) error {
+	s.mu.Lock()
+	conn := s.conn
+	s.mu.Unlock()
+	if conn == nil {
+		return nil
+	}
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	return conn.WriteJSON(ev)
+}
+
+// SendActivity tells the connected client that the watched regions became active or idle.
+func (s *Server) SendActivity(state string, regions []string) error {
+	return s.reply(Event{T: "activity", State: state, Regions: regions})
+}
+
+// durationMs converts a duration to fractional milliseconds.
+func durationMs(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
 // acceptConn ensures only one active control connection exists.
 func (s *Server) acceptConn(conn *websocket.Conn) error {
 	s.mu.Lock()
@@ -99,6 +261,12 @@ func (s *Server) cleanupConn(conn *websocket.Conn) {
 
 // handleMessage dispatches a single control message.
 func (s *Server) handleMessage(msg Message) error {
+	if term := s.activeTerminal(); term != nil {
+		switch msg.T {
+		case "type", "enter", "clearChat", "key":
+			return s.handleTerminalInput(term, msg)
+		}
+	}
 	switch msg.T {
 	case "down":
 		return s.handlePointerDown(msg)
@@ -120,29 +288,51 @@ func (s *Server) handleMessage(msg Message) error {
 		return s.handleClearChat()
 	case "setMode":
 		s.session.SetMode(msg.Mode)
+		s.session.SetZoom(calib.Zoom{})
 		_ = s.cageCursorIfRun()
 		s.notifyPipeline("mode")
+		s.publish(events.ModeChanged, map[string]any{"mode": s.session.Mode()})
 		return nil
 	case "setMonitor":
 		s.session.SetMonitor(msg.Idx)
+		s.session.SetZoom(calib.Zoom{})
 		s.notifyPipeline("monitor")
+		s.publish(events.MonitorChanged, map[string]any{"monitor": s.session.Monitor()})
 		return nil
 	case "restartPresetup":
 		s.session.SetMode(session.ModePresetup)
+		s.session.SetZoom(calib.Zoom{})
 		s.notifyPipeline("restart_presetup")
+		s.publish(events.ModeChanged, map[string]any{"mode": session.ModePresetup})
 		return nil
 	case "setVideo":
 		s.session.SetVideoMode(msg.Video)
 		s.notifyPipeline("video")
 		return nil
+	case "setLayer":
+		return s.handleSetLayer(msg.Layer)
+	case "setZoom":
+		return s.handleSetZoom(msg)
 	case "calibRect":
 		return s.handleCalibRect(msg)
+	case "clearLayout":
+		return s.handleClearLayout()
+	case "hotspot":
+		return s.handleHotspot(msg.Name)
+	case "removeHotspot":
+		return s.handleRemoveHotspot(msg.Name)
+	case "ping":
+		return s.reply(Event{T: "pong", TS: msg.TS})
+	case "latency":
+		s.handleLatencyReport(msg)
+		return nil
 	case "inputEnabled":
 		if msg.Enabled != nil {
 			s.session.SetInputEnabled(*msg.Enabled)
 			if *msg.Enabled {
 				_ = s.cageCursorIfRun()
 			}
+			s.publish(events.InputToggled, map[string]any{"enabled": *msg.Enabled})
 		}
 		return nil
 	default:
@@ -150,6 +340,47 @@ func (s *Server) handleMessage(msg Message) error {
 	}
 }
 
+// activeTerminal returns the terminal input sink when the terminal video mode is active.
+func (s *Server) activeTerminal() TerminalInput {
+	if s.session.VideoMode() != session.VideoTerminal {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.terminal
+}
+
+// handleTerminalInput writes typing messages to the PTY as xterm input bytes.
+// Write errors are not fatal for the control connection: the command may simply have exited.
+func (s *Server) handleTerminalInput(term TerminalInput, msg Message) error {
+	if !s.session.InputEnabled() {
+		return nil
+	}
+	var data []byte
+	switch msg.T {
+	case "type":
+		data = []byte(msg.Text)
+	case "enter":
+		data = []byte("\r")
+	case "clearChat":
+		// Ctrl+U clears the current input line in shells and most line editors.
+		data = []byte{0x15}
+	case "key":
+		seq, ok := terminal.KeySequence(msg.Key)
+		if !ok {
+			return nil
+		}
+		data = seq
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	if err := term.WriteInput(data); err != nil {
+		log.Printf("control: terminal input failed: %v", err)
+	}
+	return nil
+}
+
 // handleRelMove injects a relative mouse move (trackpad-style).
 func (s *Server) handleRelMove(msg Message) error {
 	if !s.session.InputEnabled() {
@@ -158,36 +389,40 @@ func (s *Server) handleRelMove(msg Message) error {
 	if msg.DX == 0 && msg.DY == 0 {
 		return nil
 	}
-	if s.session.Mode() != session.ModeRun {
+	mode := s.session.Mode()
+	if !session.IsRunLike(mode) {
 		return s.injector.MoveRel(msg.DX, msg.DY)
 	}
 
-	pluginAbs, err := s.pluginAbsVirtual(s.session.GetCalib())
+	cageAbs, err := s.cageAbsVirtual(mode, s.session.GetCalib())
 	if err != nil {
 		return nil
 	}
-	pluginAbs = calib.Normalize(pluginAbs)
-	if pluginAbs.W <= 0 || pluginAbs.H <= 0 {
+	cageAbs = calib.Normalize(cageAbs)
+	if cageAbs.W <= 0 || cageAbs.H <= 0 {
 		return nil
 	}
 
 	x, y, ok := s.cursorPos()
 	if !ok {
-		x, y = RectCenter(pluginAbs)
+		x, y = RectCenter(cageAbs)
 		if err := s.injector.MoveAbs(x, y); err != nil {
 			return err
 		}
 	}
 
-	insideX, insideY := ClampPointToRect(pluginAbs, x, y)
+	insideX, insideY := ClampPointToRect(cageAbs, x, y)
 	if insideX != x || insideY != y {
-		x, y = RectCenter(pluginAbs)
+		x, y = RectCenter(cageAbs)
 		if err := s.injector.MoveAbs(x, y); err != nil {
 			return err
 		}
 	}
 
-	targetX, targetY := ClampPointToRect(pluginAbs, x+msg.DX, y+msg.DY)
+	targetX, targetY := ClampPointToRect(cageAbs, x+msg.DX, y+msg.DY)
+	if s.readOnlyAt(mode, s.session.GetCalib(), targetX, targetY) {
+		return nil
+	}
 	return s.injector.MoveAbs(targetX, targetY)
 }
 
@@ -196,8 +431,11 @@ func (s *Server) handleClick() error {
 	if !s.session.InputEnabled() {
 		return nil
 	}
-	if s.session.Mode() == session.ModeRun {
+	if mode := s.session.Mode(); session.IsRunLike(mode) {
 		_ = s.cageCursorIfRun()
+		if x, y, ok := s.cursorPos(); ok && s.readOnlyAt(mode, s.session.GetCalib(), x, y) {
+			return nil
+		}
 	}
 	if err := s.injector.LeftDown(); err != nil {
 		return err
@@ -214,10 +452,13 @@ func (s *Server) handleWheel(msg Message) error {
 		return nil
 	}
 	c := s.session.GetCalib()
-	absX, absY, _, _, err := s.mapCoordsWithCalib(msg.X, msg.Y, c)
+	absX, absY, mode, _, err := s.mapCoordsWithCalib(msg.X, msg.Y, c)
 	if err != nil {
 		return err
 	}
+	if s.readOnlyAt(mode, c, absX, absY) {
+		return nil
+	}
 	if err := s.injector.MoveAbs(absX, absY); err != nil {
 		return err
 	}
@@ -241,8 +482,8 @@ func (s *Server) handlePointerDown(msg Message) error {
 	if err != nil {
 		return err
 	}
-	if mode == session.ModeRun {
-		actions := s.gestures.HandleDown(s.session.InputEnabled(), msg.ID, absX, absY, pluginAbs, c.ScrollRel)
+	if session.IsRunLike(mode) {
+		actions := s.gestures.HandleDownInRegions(s.session.InputEnabled(), msg.ID, absX, absY, pluginAbs, c.EffectiveRegions())
 		return s.applyActions(actions)
 	}
 	actions := buildPresetupActions("down", absX, absY)
@@ -256,7 +497,7 @@ func (s *Server) handlePointerMove(msg Message) error {
 	if err != nil {
 		return err
 	}
-	if mode == session.ModeRun {
+	if session.IsRunLike(mode) {
 		actions := s.gestures.HandleMove(s.session.InputEnabled(), msg.ID, absX, absY)
 		return s.applyActions(actions)
 	}
@@ -271,7 +512,7 @@ func (s *Server) handlePointerUp(msg Message) error {
 	if err != nil {
 		return err
 	}
-	if mode == session.ModeRun {
+	if session.IsRunLike(mode) {
 		actions := s.gestures.HandleUp(s.session.InputEnabled(), msg.ID, absX, absY)
 		return s.applyActions(actions)
 	}
@@ -318,17 +559,18 @@ func (s *Server) handleClearChat() error {
 	return s.injector.Delete()
 }
 
-// focusChatInput clicks the calibrated chat rectangle and waits for focus to settle before typing destructive keys.
+// focusChatInput clicks the calibrated type target (chat rect or first type region) and waits
+// for focus to settle before typing destructive keys.
 func (s *Server) focusChatInput(c calib.Calib) error {
 	pluginAbs, err := s.pluginAbsVirtual(c)
 	if err != nil {
 		return err
 	}
-	chat := calib.Normalize(c.ChatRel)
-	if chat.W <= 0 || chat.H <= 0 {
+	target, ok := c.TypeTarget()
+	if !ok {
 		return fmt.Errorf("chat rect not calibrated")
 	}
-	chatAbs := chatRectAbsFromPlugin(pluginAbs, c.ChatRel)
+	chatAbs := chatRectAbsFromPlugin(pluginAbs, target)
 	x, y := centerPoint(chatAbs)
 	if err := s.clickPreserveCursor(x, y); err != nil {
 		return err
@@ -341,6 +583,23 @@ func (s *Server) focusChatInput(c calib.Calib) error {
 	return nil
 }
 
+// handleSetZoom updates the server-side zoom viewport and re-crops the pipeline.
+// A missing or full-size zoom rect resets the viewport to the whole crop; composite mode
+// keeps it full, so nothing is re-cropped there.
+func (s *Server) handleSetZoom(msg Message) error {
+	z := calib.Zoom{}
+	if msg.Zoom != nil {
+		z = calib.Zoom{X: msg.Zoom.X, Y: msg.Zoom.Y, W: msg.Zoom.W, H: msg.Zoom.H}
+	}
+	prev := s.session.Zoom()
+	s.session.SetZoom(z)
+	if s.session.Zoom() == prev {
+		return nil
+	}
+	s.notifyPipeline("zoom")
+	return nil
+}
+
 // handleCalibRect updates calibration state.
 func (s *Server) handleCalibRect(msg Message) error {
 	if msg.Rect == nil {
@@ -349,20 +608,45 @@ func (s *Server) handleCalibRect(msg Message) error {
 	c := s.session.GetCalib()
 	rect := calib.Rect{X: msg.Rect.X, Y: msg.Rect.Y, W: msg.Rect.W, H: msg.Rect.H}
 
+	mode := s.session.Mode()
 	switch msg.Step {
 	case "plugin":
 		c.PluginAbs = rect
 		c.MonitorIndex = s.session.Monitor()
+		s.session.SetZoom(calib.Zoom{})
 		s.notifyPipeline("plugin_rect")
 	case "chat":
 		c.ChatRel = rect
 	case "scroll":
 		c.ScrollRel = rect
+	case "region":
+		behavior := calib.RegionBehavior(msg.Behavior)
+		if msg.Name == "" || !calib.ValidBehavior(behavior) || isReservedRegionName(msg.Name) {
+			return nil
+		}
+		c.SetRegion(calib.Region{Name: msg.Name, Behavior: behavior, Rect: rect})
+	case "hotspot":
+		if msg.Name == "" {
+			return nil
+		}
+		x, y := centerPoint(calib.Normalize(rect))
+		c.SetHotspot(calib.Hotspot{Name: msg.Name, X: x, Y: y})
+	case "layout":
+		c.Layout = append(c.Layout, rect)
+		if n := len(c.Layout); n > calib.MaxLayoutRegions {
+			c.Layout = append([]calib.Rect(nil), c.Layout[n-calib.MaxLayoutRegions:]...)
+		}
 	default:
 		return nil
 	}
 
 	s.session.SetCalib(c)
+	// Chat/scroll-only modes crop to the sub-rect being edited, so re-crop once it is stored.
+	if (msg.Step == "chat" && mode == session.ModeChat) || (msg.Step == "scroll" && mode == session.ModeScroll) ||
+		(msg.Step == "layout" && mode == session.ModeComposite) {
+		s.session.SetZoom(calib.Zoom{})
+		s.notifyPipeline(msg.Step + "_rect")
+	}
 	if s.saveCalib != nil {
 		if err := s.saveCalib(c); err != nil {
 			return err
@@ -371,15 +655,87 @@ func (s *Server) handleCalibRect(msg Message) error {
 	return nil
 }
 
+// handleClearLayout removes all composite layout regions.
+func (s *Server) handleClearLayout() error {
+	c := s.session.GetCalib()
+	if len(c.Layout) == 0 {
+		return nil
+	}
+	c.Layout = nil
+	s.session.SetCalib(c)
+	if s.saveCalib != nil {
+		if err := s.saveCalib(c); err != nil {
+			return err
+		}
+	}
+	if s.session.Mode() == session.ModeComposite {
+		s.notifyPipeline("layout_rect")
+	}
+	return nil
+}
+
+// handleHotspot clicks a calibrated hotspot without leaving the cursor displaced.
+func (s *Server) handleHotspot(name string) error {
+	if !s.session.InputEnabled() {
+		return nil
+	}
+	c := s.session.GetCalib()
+	h, ok := c.FindHotspot(name)
+	if !ok {
+		return fmt.Errorf("hotspot %q not calibrated", name)
+	}
+	pluginAbs, err := s.pluginAbsVirtual(c)
+	if err != nil {
+		return err
+	}
+	x, y := ClampPointToRect(pluginAbs, pluginAbs.X+h.X, pluginAbs.Y+h.Y)
+	return s.clickPreserveCursor(x, y)
+}
+
+// handleRemoveHotspot deletes a calibrated hotspot.
+func (s *Server) handleRemoveHotspot(name string) error {
+	c := s.session.GetCalib()
+	if !c.RemoveHotspot(name) {
+		return nil
+	}
+	s.session.SetCalib(c)
+	if s.saveCalib != nil {
+		return s.saveCalib(c)
+	}
+	return nil
+}
+
 // mapCoordsWithCalib converts normalized coords into absolute screen coordinates using a consistent calibration snapshot.
+// Coordinates are relative to the visible viewport (active crop plus zoom), while the returned
+// rect is always the absolute plugin rect so gestures can resolve plugin-relative areas.
 func (s *Server) mapCoordsWithCalib(xn, yn float64, c calib.Calib) (int, int, string, calib.Rect, error) {
 	mode := s.session.Mode()
-	if mode == session.ModeRun {
+	zoom := s.session.Zoom()
+	if mode == session.ModeComposite {
+		pluginAbs, err := s.pluginAbsVirtual(c)
+		if err != nil {
+			return 0, 0, mode, calib.Rect{}, err
+		}
+		m, err := s.calibMonitor(c)
+		if err != nil {
+			return 0, 0, mode, calib.Rect{}, err
+		}
+		x, y, ok := NormToAbsLayout(xn, yn, calib.StackLayout(c.Layout, m.W, m.H), m)
+		if !ok {
+			return 0, 0, mode, calib.Rect{}, fmt.Errorf("layout not calibrated")
+		}
+		return x, y, mode, pluginAbs, nil
+	}
+	if session.IsCropped(mode) {
 		pluginAbs, err := s.pluginAbsVirtual(c)
 		if err != nil {
 			return 0, 0, mode, calib.Rect{}, err
 		}
-		x, y := NormToAbsRun(xn, yn, pluginAbs)
+		cropAbs, err := s.cropAbsVirtual(mode, c)
+		if err != nil {
+			return 0, 0, mode, calib.Rect{}, err
+		}
+		x, y := NormToAbsRun(xn, yn, calib.ApplyZoom(cropAbs, zoom))
 		return x, y, mode, pluginAbs, nil
 	}
 
@@ -392,10 +748,28 @@ func (s *Server) mapCoordsWithCalib(xn, yn float64, c calib.Calib) (int, int, st
 	if !ok {
 		return 0, 0, mode, calib.Rect{}, fmt.Errorf("monitor %d not found", monitorIndex)
 	}
+	if !zoom.IsFull() {
+		x, y := NormToAbsRun(xn, yn, calib.ApplyZoom(calib.Rect{X: m.X, Y: m.Y, W: m.W, H: m.H}, zoom))
+		return x, y, mode, calib.Rect{}, nil
+	}
 	x, y := NormToAbsPresetup(xn, yn, m)
 	return x, y, mode, calib.Rect{}, nil
 }
 
+// readOnlyAt reports whether an absolute point falls in a read-only region. Regions are
+// plugin-relative, so they only apply in run-like modes.
+func (s *Server) readOnlyAt(mode string, c calib.Calib, absX, absY int) bool {
+	if !session.IsRunLike(mode) {
+		return false
+	}
+	pluginAbs, err := s.pluginAbsVirtual(c)
+	if err != nil {
+		return false
+	}
+	region, ok := calib.RegionAt(c.EffectiveRegions(), absX-pluginAbs.X, absY-pluginAbs.Y)
+	return ok && region.Behavior == calib.BehaviorReadOnly
+}
+
 // applyActions executes actions using the injector.
 func (s *Server) applyActions(actions []Action) error {
 	for _, action := range actions {
@@ -433,9 +807,17 @@ func (s *Server) notifyPipeline(reason string) {
 	}
 }
 
+// publish sends an event to the bus, if one is attached.
+func (s *Server) publish(typ string, data map[string]any) {
+	s.mu.Lock()
+	bus := s.bus
+	s.mu.Unlock()
+	bus.Publish(typ, data)
+}
+
 // clickPreserveCursor focuses a target point without leaving the cursor displaced when supported by the injector.
 func (s *Server) clickPreserveCursor(x, y int) error {
-	if s.session.Mode() == session.ModeRun {
+	if session.IsRunLike(s.session.Mode()) {
 		return s.injector.ClickAt(x, y)
 	}
 	if injector, ok := s.injector.(interface{ ClickAtPreserveCursor(x, y int) error }); ok {
@@ -452,20 +834,21 @@ func (s *Server) cursorPos() (int, int, bool) {
 	return 0, 0, false
 }
 
-// cageCursorIfRun ensures the cursor stays inside the calibrated plugin rect in Run mode.
+// cageCursorIfRun ensures the cursor stays inside the active calibrated crop in cropped modes.
 func (s *Server) cageCursorIfRun() error {
 	if !s.session.InputEnabled() {
 		return nil
 	}
-	if s.session.Mode() != session.ModeRun {
+	mode := s.session.Mode()
+	if !session.IsRunLike(mode) {
 		return nil
 	}
-	pluginAbs, err := s.pluginAbsVirtual(s.session.GetCalib())
+	cageAbs, err := s.cageAbsVirtual(mode, s.session.GetCalib())
 	if err != nil {
 		return nil
 	}
-	pluginAbs = calib.Normalize(pluginAbs)
-	if pluginAbs.W <= 0 || pluginAbs.H <= 0 {
+	cageAbs = calib.Normalize(cageAbs)
+	if cageAbs.W <= 0 || cageAbs.H <= 0 {
 		return nil
 	}
 
@@ -473,20 +856,66 @@ func (s *Server) cageCursorIfRun() error {
 	if !ok {
 		return nil
 	}
-	cx, cy := ClampPointToRect(pluginAbs, x, y)
+	cx, cy := ClampPointToRect(cageAbs, x, y)
 	if cx == x && cy == y {
 		return nil
 	}
-	safeX, safeY := RectCenter(pluginAbs)
+	safeX, safeY := RectCenter(cageAbs)
 	return s.injector.MoveAbs(safeX, safeY)
 }
 
 // pluginAbsVirtual converts the stored plugin rectangle into absolute virtual-desktop coordinates.
 func (s *Server) pluginAbsVirtual(c calib.Calib) (calib.Rect, error) {
-	monitors, err := s.listMonitors()
+	m, err := s.calibMonitor(c)
+	if err != nil {
+		return calib.Rect{}, err
+	}
+	pluginAbs := calib.Normalize(c.PluginAbs)
+	pluginAbs.X += m.X
+	pluginAbs.Y += m.Y
+	return pluginAbs, nil
+}
+
+// cageAbsVirtual returns the absolute rect the cursor is caged to in run-like modes.
+// Composite mode cages to the bounding box of its layout regions.
+func (s *Server) cageAbsVirtual(mode string, c calib.Calib) (calib.Rect, error) {
+	if mode != session.ModeComposite {
+		return s.cropAbsVirtual(mode, c)
+	}
+	m, err := s.calibMonitor(c)
 	if err != nil {
 		return calib.Rect{}, err
 	}
+	bounds := calib.StackLayout(c.Layout, m.W, m.H).Bounds()
+	if bounds.W <= 0 || bounds.H <= 0 {
+		return calib.Rect{}, fmt.Errorf("layout not calibrated")
+	}
+	bounds.X += m.X
+	bounds.Y += m.Y
+	return bounds, nil
+}
+
+// cropAbsVirtual converts the crop of a cropped mode into absolute virtual-desktop coordinates.
+func (s *Server) cropAbsVirtual(mode string, c calib.Calib) (calib.Rect, error) {
+	crop, ok := session.CropRect(mode, c)
+	if !ok {
+		return calib.Rect{}, fmt.Errorf("mode %q has no crop", mode)
+	}
+	m, err := s.calibMonitor(c)
+	if err != nil {
+		return calib.Rect{}, err
+	}
+	crop.X += m.X
+	crop.Y += m.Y
+	return crop, nil
+}
+
+// calibMonitor resolves the monitor the calibration rectangles are relative to.
+func (s *Server) calibMonitor(c calib.Calib) (monitor.Monitor, error) {
+	monitors, err := s.listMonitors()
+	if err != nil {
+		return monitor.Monitor{}, err
+	}
 	// Use the currently selected monitor, since the capture pipeline is driven by session.Monitor().
 	monitorIndex := s.session.Monitor()
 	if monitorIndex <= 0 && c.MonitorIndex > 0 {
@@ -498,12 +927,19 @@ func (s *Server) pluginAbsVirtual(c calib.Calib) (calib.Rect, error) {
 		m, ok = monitor.GetMonitorByIndex(monitors, monitorIndex)
 	}
 	if !ok {
-		return calib.Rect{}, fmt.Errorf("monitor %d not found", monitorIndex)
+		return monitor.Monitor{}, fmt.Errorf("monitor %d not found", monitorIndex)
+	}
+	return m, nil
+}
+
+// isReservedRegionName reports whether a region name collides with a built-in calibration step.
+func isReservedRegionName(name string) bool {
+	switch name {
+	case "plugin", "chat", "scroll", "layout", "region":
+		return true
+	default:
+		return false
 	}
-	pluginAbs := calib.Normalize(c.PluginAbs)
-	pluginAbs.X += m.X
-	pluginAbs.Y += m.Y
-	return pluginAbs, nil
 }
 
 // chatRectAbsFromPlugin converts the relative chat rect to absolute coordinates using an absolute plugin rectangle.
@@ -0,0 +1,48 @@
+package control
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/frudas24/deskslice/internal/monitor"
+	"github.com/frudas24/deskslice/internal/session"
+	"github.com/frudas24/deskslice/internal/testutil"
+	"github.com/gorilla/websocket"
+)
+
+// TestSendActivity_ReachesClient verifies activity transitions are pushed to the connected client.
+func TestSendActivity_ReachesClient(t *testing.T) {
+	sess := session.New("pw")
+	sess.Authenticate("pw")
+	server := NewServer(sess, &testutil.FakeInjector{}, func() ([]monitor.Monitor, error) { return nil, nil }, nil, nil)
+	if err := server.SendActivity("idle", nil); err != nil {
+		t.Fatalf("send without a client failed: %v", err)
+	}
+
+	httpSrv := httptest.NewServer(server)
+	defer httpSrv.Close()
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(httpSrv.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	defer conn.Close()
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	// The pong proves the connection was accepted before the event is sent.
+	if err := conn.WriteJSON(Message{T: "ping", TS: 1}); err != nil {
+		t.Fatalf("write ping: %v", err)
+	}
+	var ev Event
+	if err := conn.ReadJSON(&ev); err != nil || ev.T != "pong" {
+		t.Fatalf("unexpected pong: %+v err=%v", ev, err)
+	}
+
+	if err := server.SendActivity("active", []string{"chat"}); err != nil {
+		t.Fatalf("send failed: %v", err)
+	}
+	ev = Event{}
+	if err := conn.ReadJSON(&ev); err != nil || ev.T != "activity" || ev.State != "active" || len(ev.Regions) != 1 {
+		t.Fatalf("unexpected activity event: %+v err=%v", ev, err)
+	}
+}
@@ -0,0 +1,49 @@
+package control
+
+import (
+	"testing"
+
+	"github.com/frudas24/deskslice/internal/calib"
+	"github.com/frudas24/deskslice/internal/monitor"
+	"github.com/frudas24/deskslice/internal/session"
+	"github.com/frudas24/deskslice/internal/testutil"
+)
+
+// TestChatMode_MapsAndCagesToChatRect verifies chat-only mode maps touches and cages the cursor to the chat crop.
+func TestChatMode_MapsAndCagesToChatRect(t *testing.T) {
+	sess := session.New("pw")
+	sess.SetInputEnabled(true)
+	sess.SetMode(session.ModeChat)
+	sess.SetMonitor(1)
+	sess.SetCalib(calib.Calib{
+		MonitorIndex: 1,
+		PluginAbs:    calib.Rect{X: 100, Y: 200, W: 300, H: 400},
+		ChatRel:      calib.Rect{X: 20, Y: 300, W: 200, H: 50},
+	})
+
+	inj := &testutil.FakeInjector{X: 1, Y: 2, HasXY: true}
+	monitors := []monitor.Monitor{{Index: 2, X: 0, Y: 0, W: 1920, H: 1080}, {Index: 1, X: 1920, Y: 0, W: 1920, H: 1080, Primary: true}}
+	server := NewServer(sess, inj, func() ([]monitor.Monitor, error) { return monitors, nil }, nil, nil)
+
+	if err := server.handlePointerDown(Message{ID: 1, X: 1, Y: 1}); err != nil {
+		t.Fatalf("handlePointerDown failed: %v", err)
+	}
+	if len(inj.Calls) != 1 || inj.Calls[0].Name != "ClickAt" {
+		t.Fatalf("expected single click, got %#v", inj.Calls)
+	}
+	if inj.Calls[0].X != 2239 || inj.Calls[0].Y != 549 {
+		t.Fatalf("expected click at chat bottom-right (2239,549), got (%d,%d)", inj.Calls[0].X, inj.Calls[0].Y)
+	}
+
+	inj.Calls = nil
+	inj.X, inj.Y = 2000, 210
+	if err := server.handleClick(); err != nil {
+		t.Fatalf("handleClick failed: %v", err)
+	}
+	if len(inj.Calls) != 3 || inj.Calls[0].Name != "MoveAbs" {
+		t.Fatalf("expected cage move before click, got %#v", inj.Calls)
+	}
+	if inj.Calls[0].X != 2140 || inj.Calls[0].Y != 525 {
+		t.Fatalf("expected cage to chat center (2140,525), got (%d,%d)", inj.Calls[0].X, inj.Calls[0].Y)
+	}
+}
@@ -0,0 +1,28 @@
+package control
+
+import (
+	"testing"
+
+	"github.com/frudas24/deskslice/internal/monitor"
+	"github.com/frudas24/deskslice/internal/session"
+	"github.com/frudas24/deskslice/internal/testutil"
+)
+
+// TestHandleData_DispatchesWhenAuthenticated verifies data channel payloads reach the dispatcher only for authenticated sessions.
+func TestHandleData_DispatchesWhenAuthenticated(t *testing.T) {
+	sess := session.New("pw")
+	sess.SetInputEnabled(true)
+	server := NewServer(sess, &testutil.FakeInjector{}, func() ([]monitor.Monitor, error) { return nil, nil }, nil, nil)
+
+	server.HandleData([]byte(`{"t":"inputEnabled","enabled":false}`))
+	if !sess.InputEnabled() {
+		t.Fatalf("expected unauthenticated payload to be ignored")
+	}
+
+	sess.Authenticate("pw")
+	server.HandleData([]byte(`not json`))
+	server.HandleData([]byte(`{"t":"inputEnabled","enabled":false}`))
+	if sess.InputEnabled() {
+		t.Fatalf("expected data channel payload to disable input")
+	}
+}
@@ -0,0 +1,73 @@
+package control
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/frudas24/deskslice/internal/events"
+	"github.com/frudas24/deskslice/internal/monitor"
+	"github.com/frudas24/deskslice/internal/session"
+	"github.com/frudas24/deskslice/internal/testutil"
+	"github.com/gorilla/websocket"
+)
+
+// nextEvent returns the next bus event or fails after a timeout.
+func nextEvent(t *testing.T, ch <-chan events.Event) events.Event {
+	t.Helper()
+	select {
+	case ev := <-ch:
+		return ev
+	case <-time.After(5 * time.Second):
+		t.Fatalf("no event published")
+		return events.Event{}
+	}
+}
+
+// TestEventBus_PublishesControlChanges verifies connections and mode, monitor and input
+// changes reach the bus.
+func TestEventBus_PublishesControlChanges(t *testing.T) {
+	sess := session.New("pw")
+	sess.Authenticate("pw")
+	server := NewServer(sess, &testutil.FakeInjector{}, func() ([]monitor.Monitor, error) { return nil, nil }, nil, nil)
+	bus := events.NewBus()
+	server.SetEventBus(bus)
+	ch, cancel := bus.Subscribe(16)
+	defer cancel()
+
+	httpSrv := httptest.NewServer(server)
+	defer httpSrv.Close()
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(httpSrv.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	if ev := nextEvent(t, ch); ev.Type != events.ViewerConnected {
+		t.Fatalf("expected viewer.connected, got %+v", ev)
+	}
+
+	enabled := false
+	for _, msg := range []Message{
+		{T: "setMode", Mode: session.ModeRun},
+		{T: "setMonitor", Idx: 2},
+		{T: "inputEnabled", Enabled: &enabled},
+	} {
+		if err := conn.WriteJSON(msg); err != nil {
+			t.Fatalf("write %s: %v", msg.T, err)
+		}
+	}
+	if ev := nextEvent(t, ch); ev.Type != events.ModeChanged || ev.Data["mode"] != session.ModeRun {
+		t.Fatalf("unexpected mode event %+v", ev)
+	}
+	if ev := nextEvent(t, ch); ev.Type != events.MonitorChanged || ev.Data["monitor"] != 2 {
+		t.Fatalf("unexpected monitor event %+v", ev)
+	}
+	if ev := nextEvent(t, ch); ev.Type != events.InputToggled || ev.Data["enabled"] != false {
+		t.Fatalf("unexpected input event %+v", ev)
+	}
+
+	_ = conn.Close()
+	if ev := nextEvent(t, ch); ev.Type != events.ViewerDisconnected {
+		t.Fatalf("expected viewer.disconnected, got %+v", ev)
+	}
+}
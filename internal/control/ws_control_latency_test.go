@@ -0,0 +1,76 @@
+package control
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/frudas24/deskslice/internal/latency"
+	"github.com/frudas24/deskslice/internal/monitor"
+	"github.com/frudas24/deskslice/internal/session"
+	"github.com/frudas24/deskslice/internal/testutil"
+	"github.com/gorilla/websocket"
+)
+
+// fakeFrameProbe reports a frame sent a fixed delay after the requested instant.
+type fakeFrameProbe struct {
+	delay time.Duration
+}
+
+// NextFrameSent returns a channel that already holds the simulated send time.
+func (f fakeFrameProbe) NextFrameSent(after time.Time) <-chan time.Time {
+	ch := make(chan time.Time, 1)
+	ch <- after.Add(f.delay)
+	return ch
+}
+
+// CancelFrameProbe is a no-op.
+func (fakeFrameProbe) CancelFrameProbe(<-chan time.Time) {}
+
+// TestLatency_PingProbeAndReports verifies pong echoes, marked click probes and client reports feed the recorder.
+func TestLatency_PingProbeAndReports(t *testing.T) {
+	sess := session.New("pw")
+	sess.Authenticate("pw")
+	sess.SetInputEnabled(true)
+	server := NewServer(sess, &testutil.FakeInjector{}, func() ([]monitor.Monitor, error) { return nil, nil }, nil, nil)
+	rec := latency.NewRecorder(10)
+	server.SetLatencyProbe(fakeFrameProbe{delay: 40 * time.Millisecond}, rec)
+
+	httpSrv := httptest.NewServer(server)
+	defer httpSrv.Close()
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(httpSrv.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	defer conn.Close()
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	if err := conn.WriteJSON(Message{T: "ping", TS: 1234.5}); err != nil {
+		t.Fatalf("write ping: %v", err)
+	}
+	var ev Event
+	if err := conn.ReadJSON(&ev); err != nil || ev.T != "pong" || ev.TS != 1234.5 {
+		t.Fatalf("unexpected pong: %+v err=%v", ev, err)
+	}
+
+	if err := conn.WriteJSON(Message{T: "click", Probe: 7}); err != nil {
+		t.Fatalf("write click: %v", err)
+	}
+	ev = Event{}
+	if err := conn.ReadJSON(&ev); err != nil || ev.T != "probe" || ev.Probe != 7 || ev.EncodeMs != 40 {
+		t.Fatalf("unexpected probe: %+v err=%v", ev, err)
+	}
+
+	if err := conn.WriteJSON(Message{T: "latency", RTTMs: 12, InputMs: 90}); err != nil {
+		t.Fatalf("write report: %v", err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for len(rec.Summary()) < 3 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	got := rec.Summary()
+	if got[latency.SeriesRTT].P50 != 12 || got[latency.SeriesInput].P50 != 90 || got[latency.SeriesEncode].P50 != 40 {
+		t.Fatalf("unexpected summary: %+v", got)
+	}
+}
@@ -0,0 +1,37 @@
+package control
+
+import (
+	"testing"
+
+	"github.com/frudas24/deskslice/internal/monitor"
+	"github.com/frudas24/deskslice/internal/session"
+	"github.com/frudas24/deskslice/internal/testutil"
+)
+
+// fakeLayers records requested quality layers.
+type fakeLayers struct {
+	modes []string
+}
+
+// SetLayer records the requested mode.
+func (f *fakeLayers) SetLayer(mode string) error {
+	f.modes = append(f.modes, mode)
+	return nil
+}
+
+// TestSetLayer_ForwardsToSelector verifies setLayer messages reach the layer selector.
+func TestSetLayer_ForwardsToSelector(t *testing.T) {
+	sess := session.New("pw")
+	server := NewServer(sess, &testutil.FakeInjector{}, func() ([]monitor.Monitor, error) { return nil, nil }, nil, nil)
+	if err := server.handleMessage(Message{T: "setLayer", Layer: "low"}); err != nil {
+		t.Fatalf("setLayer without selector failed: %v", err)
+	}
+	layers := &fakeLayers{}
+	server.SetLayerSelector(layers)
+	if err := server.handleMessage(Message{T: "setLayer", Layer: "auto"}); err != nil {
+		t.Fatalf("setLayer failed: %v", err)
+	}
+	if len(layers.modes) != 1 || layers.modes[0] != "auto" {
+		t.Fatalf("unexpected layer requests: %v", layers.modes)
+	}
+}
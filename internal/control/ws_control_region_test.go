@@ -0,0 +1,140 @@
+package control
+
+import (
+	"testing"
+
+	"github.com/frudas24/deskslice/internal/calib"
+	"github.com/frudas24/deskslice/internal/monitor"
+	"github.com/frudas24/deskslice/internal/session"
+	"github.com/frudas24/deskslice/internal/testutil"
+)
+
+// TestCalibRect_RegionStepStoresNamedRegion verifies named regions are stored and persisted.
+func TestCalibRect_RegionStepStoresNamedRegion(t *testing.T) {
+	sess := session.New("pw")
+	var saved []calib.Calib
+	server := NewServer(sess, &testutil.FakeInjector{}, func() ([]monitor.Monitor, error) { return nil, nil }, nil, func(c calib.Calib) error {
+		saved = append(saved, c)
+		return nil
+	})
+
+	msg := Message{T: "calibRect", Step: "region", Name: "terminal", Behavior: "readonly", Rect: &Rect{X: 1, Y: 2, W: 3, H: 4}}
+	if err := server.handleMessage(msg); err != nil {
+		t.Fatalf("calibRect failed: %v", err)
+	}
+	c := sess.GetCalib()
+	if len(c.Regions) != 1 || c.Regions[0].Name != "terminal" || c.Regions[0].Behavior != calib.BehaviorReadOnly {
+		t.Fatalf("unexpected regions: %+v", c.Regions)
+	}
+	if len(saved) != 1 || len(saved[0].Regions) != 1 {
+		t.Fatalf("expected region to be persisted, got %+v", saved)
+	}
+
+	bad := Message{T: "calibRect", Step: "region", Name: "chat", Behavior: "click", Rect: &Rect{X: 1, Y: 2, W: 3, H: 4}}
+	if err := server.handleMessage(bad); err != nil {
+		t.Fatalf("calibRect failed: %v", err)
+	}
+	if got := sess.GetCalib().Regions; len(got) != 1 {
+		t.Fatalf("expected reserved name to be ignored, got %+v", got)
+	}
+}
+
+// TestRunMode_TypeTargetsTypeRegion verifies typing focuses a type region when no chat rect exists.
+func TestRunMode_TypeTargetsTypeRegion(t *testing.T) {
+	sess := session.New("pw")
+	sess.SetInputEnabled(true)
+	sess.SetMode(session.ModeRun)
+	sess.SetMonitor(1)
+	sess.SetCalib(calib.Calib{
+		MonitorIndex: 1,
+		PluginAbs:    calib.Rect{X: 100, Y: 200, W: 300, H: 400},
+		Regions:      []calib.Region{{Name: "prompt", Behavior: calib.BehaviorType, Rect: calib.Rect{X: 0, Y: 300, W: 100, H: 20}}},
+	})
+	inj := &testutil.FakeInjector{}
+	monitors := []monitor.Monitor{{Index: 1, W: 1920, H: 1080, Primary: true}}
+	server := NewServer(sess, inj, func() ([]monitor.Monitor, error) { return monitors, nil }, nil, nil)
+
+	if err := server.handleType("hi"); err != nil {
+		t.Fatalf("handleType failed: %v", err)
+	}
+	if len(inj.Calls) != 3 || inj.Calls[0].Name != "ClickAt" || inj.Calls[2].Name != "TypeUnicode" {
+		t.Fatalf("unexpected calls: %#v", inj.Calls)
+	}
+	if inj.Calls[0].X != 150 || inj.Calls[0].Y != 510 {
+		t.Fatalf("expected focus click at (150,510), got (%d,%d)", inj.Calls[0].X, inj.Calls[0].Y)
+	}
+}
+
+// TestHotspot_ClicksCalibratedPoint verifies hotspots are stored from the traced rect center and clicked relative to the plugin rect.
+func TestHotspot_ClicksCalibratedPoint(t *testing.T) {
+	sess := session.New("pw")
+	sess.SetInputEnabled(true)
+	sess.SetMonitor(1)
+	sess.SetCalib(calib.Calib{MonitorIndex: 1, PluginAbs: calib.Rect{X: 100, Y: 200, W: 300, H: 400}})
+	inj := &testutil.FakeInjector{}
+	monitors := []monitor.Monitor{{Index: 1, X: 1920, W: 1920, H: 1080}}
+	server := NewServer(sess, inj, func() ([]monitor.Monitor, error) { return monitors, nil }, nil, nil)
+
+	calibMsg := Message{T: "calibRect", Step: "hotspot", Name: "approve", Rect: &Rect{X: 20, Y: 360, W: 40, H: 20}}
+	if err := server.handleMessage(calibMsg); err != nil {
+		t.Fatalf("calibRect failed: %v", err)
+	}
+	if err := server.handleMessage(Message{T: "hotspot", Name: "approve"}); err != nil {
+		t.Fatalf("hotspot failed: %v", err)
+	}
+	if len(inj.Calls) != 1 || inj.Calls[0].Name != "ClickAt" || inj.Calls[0].X != 2060 || inj.Calls[0].Y != 570 {
+		t.Fatalf("unexpected calls: %#v", inj.Calls)
+	}
+
+	if err := server.handleMessage(Message{T: "removeHotspot", Name: "approve"}); err != nil {
+		t.Fatalf("removeHotspot failed: %v", err)
+	}
+	if err := server.handleMessage(Message{T: "hotspot", Name: "approve"}); err == nil {
+		t.Fatalf("expected error for removed hotspot")
+	}
+}
+
+// TestRunMode_ReadOnlyRegionBlocksEveryPointerEvent verifies drags, wheel scrolls, relative
+// moves and clicks that start inside a read-only region never reach the injector.
+func TestRunMode_ReadOnlyRegionBlocksEveryPointerEvent(t *testing.T) {
+	sess := session.New("pw")
+	sess.SetInputEnabled(true)
+	sess.SetMode(session.ModeRun)
+	sess.SetMonitor(1)
+	sess.SetCalib(calib.Calib{
+		MonitorIndex: 1,
+		PluginAbs:    calib.Rect{X: 100, Y: 200, W: 300, H: 400},
+		Regions:      []calib.Region{{Name: "log", Behavior: calib.BehaviorReadOnly, Rect: calib.Rect{X: 0, Y: 0, W: 300, H: 200}}},
+	})
+	inj := &testutil.FakeInjector{X: 250, Y: 300, HasXY: true}
+	monitors := []monitor.Monitor{{Index: 1, W: 1920, H: 1080, Primary: true}}
+	server := NewServer(sess, inj, func() ([]monitor.Monitor, error) { return monitors, nil }, nil, nil)
+
+	blocked := []Message{
+		{T: "down", ID: 1, X: 0.5, Y: 0.25},
+		{T: "move", ID: 1, X: 0.5, Y: 0.5},
+		{T: "move", ID: 1, X: 0.5, Y: 0.75},
+		{T: "up", ID: 1, X: 0.5, Y: 0.75},
+		{T: "wheel", X: 0.5, Y: 0.25, WheelY: -120},
+		{T: "click"},
+	}
+	for _, msg := range blocked {
+		if err := server.handleMessage(msg); err != nil {
+			t.Fatalf("%s failed: %v", msg.T, err)
+		}
+	}
+	inj.X, inj.Y = 250, 500
+	if err := server.handleMessage(Message{T: "relMove", DY: -200}); err != nil {
+		t.Fatalf("relMove failed: %v", err)
+	}
+	if len(inj.Calls) != 0 {
+		t.Fatalf("expected read-only region to swallow input, got %#v", inj.Calls)
+	}
+
+	if err := server.handleMessage(Message{T: "wheel", X: 0.5, Y: 0.75, WheelY: -120}); err != nil {
+		t.Fatalf("wheel failed: %v", err)
+	}
+	if len(inj.Calls) != 2 || inj.Calls[0].Name != "MoveAbs" || inj.Calls[1].Name != "Wheel" {
+		t.Fatalf("expected wheel outside the region to pass, got %#v", inj.Calls)
+	}
+}
@@ -0,0 +1,65 @@
+package control
+
+import (
+	"testing"
+
+	"github.com/frudas24/deskslice/internal/monitor"
+	"github.com/frudas24/deskslice/internal/session"
+	"github.com/frudas24/deskslice/internal/testutil"
+)
+
+// fakeTerminal records bytes written to the terminal input.
+type fakeTerminal struct {
+	writes []string
+}
+
+// WriteInput records the written bytes.
+func (f *fakeTerminal) WriteInput(p []byte) error {
+	f.writes = append(f.writes, string(p))
+	return nil
+}
+
+// TestTerminalMode_RoutesTypingToPTY verifies typing messages bypass the injector in terminal mode.
+func TestTerminalMode_RoutesTypingToPTY(t *testing.T) {
+	sess := session.New("pw")
+	sess.SetInputEnabled(true)
+	sess.SetVideoMode(session.VideoTerminal)
+	inj := &testutil.FakeInjector{}
+	server := NewServer(sess, inj, func() ([]monitor.Monitor, error) { return nil, nil }, nil, nil)
+	term := &fakeTerminal{}
+	server.SetTerminal(term)
+
+	msgs := []Message{
+		{T: "type", Text: "ls"},
+		{T: "enter"},
+		{T: "key", Key: "ctrl+c"},
+		{T: "key", Key: "up"},
+		{T: "key", Key: "bogus"},
+		{T: "clearChat"},
+	}
+	for _, msg := range msgs {
+		if err := server.handleMessage(msg); err != nil {
+			t.Fatalf("%s failed: %v", msg.T, err)
+		}
+	}
+	want := []string{"ls", "\r", "\x03", "\x1b[A", "\x15"}
+	if len(term.writes) != len(want) {
+		t.Fatalf("expected %q, got %q", want, term.writes)
+	}
+	for i := range want {
+		if term.writes[i] != want[i] {
+			t.Fatalf("expected %q, got %q", want, term.writes)
+		}
+	}
+	if len(inj.Calls) != 0 {
+		t.Fatalf("expected no injector calls, got %#v", inj.Calls)
+	}
+
+	sess.SetVideoMode(session.VideoMJPEG)
+	if err := server.handleMessage(Message{T: "key", Key: "up"}); err != nil {
+		t.Fatalf("key failed: %v", err)
+	}
+	if len(term.writes) != len(want) {
+		t.Fatalf("expected no terminal writes outside terminal mode, got %q", term.writes)
+	}
+}
@@ -0,0 +1,60 @@
+package control
+
+import (
+	"testing"
+
+	"github.com/frudas24/deskslice/internal/calib"
+	"github.com/frudas24/deskslice/internal/monitor"
+	"github.com/frudas24/deskslice/internal/session"
+	"github.com/frudas24/deskslice/internal/testutil"
+)
+
+// TestRunMode_ZoomMapsThroughViewport verifies touches are mapped through the zoomed sub-rect.
+func TestRunMode_ZoomMapsThroughViewport(t *testing.T) {
+	sess := session.New("pw")
+	sess.SetInputEnabled(true)
+	sess.SetMode(session.ModeRun)
+	sess.SetMonitor(1)
+	sess.SetCalib(calib.Calib{
+		MonitorIndex: 1,
+		PluginAbs:    calib.Rect{X: 100, Y: 200, W: 400, H: 400},
+	})
+
+	var reasons []string
+	inj := &testutil.FakeInjector{}
+	monitors := []monitor.Monitor{{Index: 1, X: 0, Y: 0, W: 1920, H: 1080, Primary: true}}
+	server := NewServer(sess, inj, func() ([]monitor.Monitor, error) { return monitors, nil }, func(reason string) {
+		reasons = append(reasons, reason)
+	}, nil)
+
+	if err := server.handleMessage(Message{T: "setZoom", Zoom: &NormRect{X: 0.5, Y: 0.5, W: 0.5, H: 0.5}}); err != nil {
+		t.Fatalf("setZoom failed: %v", err)
+	}
+	if len(reasons) != 1 || reasons[0] != "zoom" {
+		t.Fatalf("expected zoom pipeline restart, got %#v", reasons)
+	}
+
+	if err := server.handlePointerDown(Message{ID: 1, X: 0, Y: 0}); err != nil {
+		t.Fatalf("handlePointerDown failed: %v", err)
+	}
+	if len(inj.Calls) != 1 || inj.Calls[0].Name != "ClickAt" {
+		t.Fatalf("expected single click, got %#v", inj.Calls)
+	}
+	if inj.Calls[0].X != 300 || inj.Calls[0].Y != 400 {
+		t.Fatalf("expected click at zoom origin (300,400), got (%d,%d)", inj.Calls[0].X, inj.Calls[0].Y)
+	}
+}
+
+// TestSetMode_ResetsZoom verifies mode changes drop the zoom viewport.
+func TestSetMode_ResetsZoom(t *testing.T) {
+	sess := session.New("pw")
+	sess.SetZoom(calib.Zoom{X: 0.1, Y: 0.1, W: 0.5, H: 0.5})
+	server := NewServer(sess, &testutil.FakeInjector{}, func() ([]monitor.Monitor, error) { return nil, nil }, nil, nil)
+
+	if err := server.handleMessage(Message{T: "setMode", Mode: session.ModePresetup}); err != nil {
+		t.Fatalf("setMode failed: %v", err)
+	}
+	if !sess.Zoom().IsFull() {
+		t.Fatalf("expected zoom reset, got %+v", sess.Zoom())
+	}
+}
@@ -0,0 +1,90 @@
+// Package events is the in-process bus server components publish notable changes to, so
+// outputs such as webhooks can follow them without each component knowing about them.
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+// Event types.
+const (
+	// ViewerConnected is published when a UI client opens the control channel.
+	ViewerConnected = "viewer.connected"
+	// ViewerDisconnected is published when that control channel closes.
+	ViewerDisconnected = "viewer.disconnected"
+	// ModeChanged is published when the capture mode changes.
+	ModeChanged = "mode.changed"
+	// MonitorChanged is published when another monitor is selected.
+	MonitorChanged = "monitor.changed"
+	// CalibSaved is published after the calibration was written to disk.
+	CalibSaved = "calib.saved"
+	// InputToggled is published when input injection is enabled or disabled.
+	InputToggled = "input.toggled"
+	// FFmpegCrashed is published when a running ffmpeg process exits on its own.
+	FFmpegCrashed = "ffmpeg.crashed"
+	// PipelineFailed is published when restarting the capture pipeline failed.
+	PipelineFailed = "pipeline.failed"
+)
+
+// Types lists every event type, for validating filters.
+var Types = []string{
+	ViewerConnected, ViewerDisconnected, ModeChanged, MonitorChanged,
+	CalibSaved, InputToggled, FFmpegCrashed, PipelineFailed,
+}
+
+// Event is one published change.
+type Event struct {
+	ID   int64          `json:"id"`
+	Type string         `json:"type"`
+	Time time.Time      `json:"time"`
+	Data map[string]any `json:"data,omitempty"`
+}
+
+// Bus fans events out to subscribers. Publishing never blocks: a subscriber whose buffer
+// is full misses the event.
+type Bus struct {
+	mu     sync.Mutex
+	nextID int64
+	subs   map[chan Event]struct{}
+}
+
+// NewBus returns an empty bus.
+func NewBus() *Bus {
+	return &Bus{subs: make(map[chan Event]struct{})}
+}
+
+// Publish stamps and delivers an event to every subscriber.
+func (b *Bus) Publish(typ string, data map[string]any) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.nextID++
+	ev := Event{ID: b.nextID, Type: typ, Time: time.Now().UTC(), Data: data}
+	for ch := range b.subs {
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+}
+
+// Subscribe returns a channel receiving events published from now on and a function that
+// unsubscribes and closes it.
+func (b *Bus) Subscribe(buffer int) (<-chan Event, func()) {
+	ch := make(chan Event, buffer)
+	b.mu.Lock()
+	b.subs[ch] = struct{}{}
+	b.mu.Unlock()
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subs, ch)
+			b.mu.Unlock()
+			close(ch)
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package events
+
+import "testing"
+
+// TestBus_FansOutAndDropsWhenFull verifies every subscriber gets events in order and a full
+// subscriber does not block publishing.
+func TestBus_FansOutAndDropsWhenFull(t *testing.T) {
+	bus := NewBus()
+	a, cancelA := bus.Subscribe(4)
+	b, cancelB := bus.Subscribe(1)
+	defer cancelA()
+
+	bus.Publish(ModeChanged, map[string]any{"mode": "run"})
+	bus.Publish(InputToggled, map[string]any{"enabled": true})
+
+	first, second := <-a, <-a
+	if first.Type != ModeChanged || second.Type != InputToggled || second.ID != first.ID+1 {
+		t.Fatalf("unexpected events %+v %+v", first, second)
+	}
+	if got := <-b; got.Type != ModeChanged {
+		t.Fatalf("full subscriber got %+v", got)
+	}
+	select {
+	case ev := <-b:
+		t.Fatalf("event should have been dropped, got %+v", ev)
+	default:
+	}
+
+	cancelB()
+	cancelB()
+	if _, ok := <-b; ok {
+		t.Fatalf("channel not closed after unsubscribe")
+	}
+	bus.Publish(CalibSaved, nil)
+}
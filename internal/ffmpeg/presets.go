@@ -3,35 +3,137 @@ package ffmpeg
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/frudas24/deskslice/internal/calib"
 	"github.com/frudas24/deskslice/internal/monitor"
 )
 
+// Video codecs the RTP output can be encoded with; names match the WebRTC publisher's.
+const (
+	CodecH264 = "h264"
+	CodecVP8  = "vp8"
+	CodecVP9  = "vp9"
+	CodecAV1  = "av1"
+)
+
 // Options describes ffmpeg runtime parameters.
 type Options struct {
 	FFmpegPath    string
 	FPS           int
 	BitrateKbps   int
 	CaptureDriver string
+	// Codec selects the RTP encoder; empty means H264.
+	Codec string
+	// LowPort, when set, adds a half-resolution, quarter-bitrate encoding of the same
+	// picture sent to this RTP port (the publisher's low simulcast layer).
+	LowPort int
+	// RTPPort, when set on a Preview, makes the preview's ffmpeg process also encode the
+	// RTP output(s) to this port, so WebRTC and MJPEG share a single capture.
+	RTPPort int
+	// PreviewFPS caps the raw preview branch of a shared pipeline (0 keeps the capture rate).
+	PreviewFPS int
+	// LiveCrop names the crop filter so a running process can be moved with SetCrop, and
+	// makes previews capture the whole monitor and crop raw frames in-process.
+	LiveCrop bool
+	// FFmpegJPEG makes previews read JPEG frames encoded by ffmpeg (-f mjpeg) at JPEGQuality
+	// instead of raw rgb24 frames encoded in Go. Previews then crop in ffmpeg, not in-process.
+	FFmpegJPEG  bool
+	JPEGQuality int
 }
 
+// liveCropFilter is the crop filter instance addressed by runtime commands.
+const liveCropFilter = "crop@live"
+
 // BuildPresetupArgs returns ffmpeg args for fullscreen capture.
 func BuildPresetupArgs(m monitor.Monitor, opts Options, port int, useD3D11 bool) []string {
 	input := buildInputArgs(m, opts, useD3D11)
-	output := buildOutputArgs(opts, port, "")
+	var filterArgs []string
+	if opts.LiveCrop {
+		// Start on the whole monitor so a later zoom can be applied without a restart.
+		filterArgs = liveCropFilterArgs(m, calib.Rect{W: m.W, H: m.H})
+	}
+	output := buildOutputArgs(opts, port, filterArgs)
 	return append(input, output...)
 }
 
 // BuildRunArgs returns ffmpeg args for cropped capture.
 func BuildRunArgs(m monitor.Monitor, plugin calib.Rect, opts Options, port int, useD3D11 bool) []string {
-	plugin = normalizeCropRect(plugin, m)
-	crop := fmt.Sprintf("crop=%d:%d:%d:%d", plugin.W, plugin.H, plugin.X, plugin.Y)
 	input := buildInputArgs(m, opts, useD3D11)
-	output := buildOutputArgs(opts, port, crop)
+	filterArgs := cropFilterArgs(m, plugin)
+	if opts.LiveCrop {
+		filterArgs = liveCropFilterArgs(m, plugin)
+	}
+	output := buildOutputArgs(opts, port, filterArgs)
+	return append(input, output...)
+}
+
+// BuildCompositeArgs returns ffmpeg args that stack several regions into one frame.
+func BuildCompositeArgs(m monitor.Monitor, regions []calib.Rect, opts Options, port int, useD3D11 bool) []string {
+	input := buildInputArgs(m, opts, useD3D11)
+	output := buildOutputArgs(opts, port, compositeFilterArgs(m, regions))
 	return append(input, output...)
 }
 
+// cropFilterArgs returns the -vf arguments cropping a single monitor-relative rectangle.
+func cropFilterArgs(m monitor.Monitor, r calib.Rect) []string {
+	r = NormalizeCropRect(r, m)
+	return []string{"-vf", fmt.Sprintf("crop=%d:%d:%d:%d", r.W, r.H, r.X, r.Y)}
+}
+
+// liveCropFilterArgs returns -vf arguments for a named crop whose position can be changed
+// while ffmpeg runs (see cropCommands).
+func liveCropFilterArgs(m monitor.Monitor, r calib.Rect) []string {
+	r = NormalizeCropRect(r, m)
+	return []string{"-vf", fmt.Sprintf("%s=w=%d:h=%d:x=%d:y=%d", liveCropFilter, r.W, r.H, r.X, r.Y)}
+}
+
+// cropCommands returns the interactive ffmpeg commands ("c" + target, time, command, arg)
+// that move the live crop from one position to another. The size is fixed by the encoder.
+func cropCommands(from, to calib.Rect) string {
+	var b strings.Builder
+	if to.X != from.X {
+		fmt.Fprintf(&b, "c%s -1 x %d\n", liveCropFilter, to.X)
+	}
+	if to.Y != from.Y {
+		fmt.Fprintf(&b, "c%s -1 y %d\n", liveCropFilter, to.Y)
+	}
+	return b.String()
+}
+
+// compositeFilterArgs returns the -filter_complex arguments for a vertical stack of regions.
+// Each region is cropped from a split of the capture and padded to the widest tile before vstack.
+func compositeFilterArgs(m monitor.Monitor, regions []calib.Rect) []string {
+	layout := calib.StackLayout(regions, m.W, m.H)
+	n := len(layout.Tiles)
+	if n == 0 {
+		return nil
+	}
+	if n == 1 {
+		src := layout.Tiles[0].Src
+		return []string{"-vf", fmt.Sprintf("crop=%d:%d:%d:%d", src.W, src.H, src.X, src.Y)}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "[0:v]split=%d", n)
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b, "[s%d]", i)
+	}
+	for i, t := range layout.Tiles {
+		fmt.Fprintf(&b, ";[s%d]crop=%d:%d:%d:%d", i, t.Src.W, t.Src.H, t.Src.X, t.Src.Y)
+		if t.Src.W < layout.W {
+			fmt.Fprintf(&b, ",pad=%d:%d:0:0", layout.W, t.Src.H)
+		}
+		fmt.Fprintf(&b, "[t%d]", i)
+	}
+	b.WriteString(";")
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b, "[t%d]", i)
+	}
+	fmt.Fprintf(&b, "vstack=inputs=%d[out]", n)
+	return []string{"-filter_complex", b.String(), "-map", "[out]"}
+}
+
 // buildInputArgs builds the capture-side arguments.
 func buildInputArgs(m monitor.Monitor, opts Options, useD3D11 bool) []string {
 	grabber := "gdigrab"
@@ -51,41 +153,195 @@ func buildInputArgs(m monitor.Monitor, opts Options, useD3D11 bool) []string {
 }
 
 // buildOutputArgs builds the encode/output arguments.
-func buildOutputArgs(opts Options, port int, cropFilter string) []string {
+func buildOutputArgs(opts Options, port int, filterArgs []string) []string {
+	keyint := keyframeInterval(opts.FPS)
+	args := []string{
+		"-an",
+	}
+	if opts.LowPort > 0 {
+		args = append(args, "-filter_complex", splitFilterGraph("[0:v]", filterArgs, outputSplit(opts, false)))
+		return append(args, layerOutputArgs(opts, keyint, port)...)
+	}
+	args = append(args, filterArgs...)
+	return append(args, rtpOutputArgs(opts.Codec, keyint, opts.BitrateKbps, port)...)
+}
+
+// buildPreviewOutputArgs builds the raw rgb24 stdout output of a preview. With opts.RTPPort
+// set, the same graph also feeds the RTP encoder(s), so one process serves both outputs.
+// With opts.LiveCrop the raw output is the uncropped capture and filterArgs only apply to RTP.
+func buildPreviewOutputArgs(opts Options, filterArgs []string) []string {
+	if opts.RTPPort <= 0 {
+		args := append([]string(nil), filterArgs...)
+		return append(append(args, "-an"), previewFrameArgs(opts)...)
+	}
+	graph := splitFilterGraph("[0:v]", filterArgs, outputSplit(opts, true))
+	if opts.LiveCrop {
+		raw := "[raw]"
+		if opts.PreviewFPS > 0 {
+			raw = fmt.Sprintf("[raw0];[raw0]fps=%d[raw]", opts.PreviewFPS)
+		}
+		graph = "[0:v]split=2[cap]" + raw + ";" + splitFilterGraph("[cap]", filterArgs, outputSplit(opts, false))
+	}
+	args := []string{
+		"-an",
+		"-filter_complex", graph,
+	}
+	args = append(args, layerOutputArgs(opts, keyframeInterval(opts.FPS), opts.RTPPort)...)
+	args = append(args, "-map", "[raw]", "-an")
+	return append(args, previewFrameArgs(opts)...)
+}
+
+// previewFrameArgs returns the stdout output for preview frames: rgb24 for the Go encoder or
+// a concatenated JPEG stream when ffmpeg encodes.
+func previewFrameArgs(opts Options) []string {
+	if !opts.FFmpegJPEG {
+		return []string{"-pix_fmt", "rgb24", "-f", "rawvideo", "-"}
+	}
+	return []string{
+		"-c:v", "mjpeg",
+		"-q:v", fmt.Sprintf("%d", jpegQScale(opts.JPEGQuality)),
+		"-pix_fmt", "yuvj420p",
+		"-f", "mjpeg", "-",
+	}
+}
+
+// jpegQScale maps a 1-100 JPEG quality onto ffmpeg's 2-31 qscale (lower is better).
+func jpegQScale(quality int) int {
+	if quality <= 0 || quality > 100 {
+		quality = 60
+	}
+	return 2 + (100-quality)*29/99
+}
+
+// keyframeInterval returns the GOP size for the framerate.
+func keyframeInterval(fps int) int {
 	// Keep keyframes frequent to help decoders recover quickly after restarts/crop changes.
-	keyint := opts.FPS
+	keyint := fps
 	if keyint <= 0 {
 		keyint = 30
 	}
 	if keyint < 15 {
 		keyint = 15
 	}
-	args := []string{
-		"-an",
-	}
-	if cropFilter != "" {
-		args = append(args, "-vf", cropFilter)
+	return keyint
+}
+
+// layerOutputArgs maps the [hi] pad, and [lo] when a low layer is requested, to RTP outputs.
+func layerOutputArgs(opts Options, keyint, port int) []string {
+	args := []string{"-map", "[hi]"}
+	args = append(args, rtpOutputArgs(opts.Codec, keyint, opts.BitrateKbps, port)...)
+	if opts.LowPort <= 0 {
+		return args
 	}
-	args = append(args,
-		"-vcodec", "libx264",
-		"-preset", "ultrafast",
-		"-tune", "zerolatency",
-		"-profile:v", "baseline",
-		"-g", fmt.Sprintf("%d", keyint),
-		"-keyint_min", fmt.Sprintf("%d", keyint),
-		"-bf", "0",
-		"-x264-params", "scenecut=0:repeat-headers=1",
+	args = append(args, "-map", "[lo]")
+	return append(args, rtpOutputArgs(opts.Codec, keyint, lowBitrateKbps(opts.BitrateKbps), opts.LowPort)...)
+}
+
+// rtpOutputArgs returns the encoder and RTP muxer arguments for one output.
+func rtpOutputArgs(codec string, keyint, bitrateKbps, port int) []string {
+	args := encoderArgs(codec, keyint)
+	return append(args,
 		"-pix_fmt", "yuv420p",
-		"-b:v", fmt.Sprintf("%dk", opts.BitrateKbps),
+		"-b:v", fmt.Sprintf("%dk", bitrateKbps),
 		"-payload_type", "96",
 		"-f", "rtp",
 		fmt.Sprintf("rtp://127.0.0.1:%d?pkt_size=1200", port),
 	)
-	return args
 }
 
-// normalizeCropRect clamps and aligns a crop rectangle to even dimensions.
-func normalizeCropRect(r calib.Rect, m monitor.Monitor) calib.Rect {
+// outputSplit returns the graph tail that splits the picture into a full-size [hi] pad,
+// a half-size [lo] pad when opts.LowPort is set and, for shared pipelines, a [raw] pad.
+func outputSplit(opts Options, raw bool) string {
+	pads := []string{"[hi]"}
+	var chains []string
+	if opts.LowPort > 0 {
+		pads = append(pads, "[lo0]")
+		chains = append(chains, "[lo0]scale=trunc(iw/4)*2:trunc(ih/4)*2[lo]")
+	}
+	if raw && opts.PreviewFPS > 0 {
+		pads = append(pads, "[raw0]")
+		chains = append(chains, fmt.Sprintf("[raw0]fps=%d[raw]", opts.PreviewFPS))
+	} else if raw {
+		pads = append(pads, "[raw]")
+	}
+	split := "null[hi]"
+	if len(pads) > 1 {
+		split = fmt.Sprintf("split=%d%s", len(pads), strings.Join(pads, ""))
+	}
+	return strings.Join(append([]string{split}, chains...), ";")
+}
+
+// splitFilterGraph turns crop/composite filter args applied to the input pad into a graph
+// ending in the given tail.
+func splitFilterGraph(input string, filterArgs []string, tail string) string {
+	switch {
+	case len(filterArgs) >= 2 && filterArgs[0] == "-vf":
+		return input + filterArgs[1] + "," + tail
+	case len(filterArgs) >= 2 && filterArgs[0] == "-filter_complex":
+		// Composite graphs read [0:v] and end in a labeled [out] pad that is re-split here.
+		return filterArgs[1] + ";[out]" + tail
+	default:
+		return input + tail
+	}
+}
+
+// lowBitrateKbps returns the low layer bitrate: a quarter of the main one, at least 300k.
+func lowBitrateKbps(kbps int) int {
+	return maxInt(kbps/4, 300)
+}
+
+// encoderArgs returns low-latency encoder settings for the codec with a fixed GOP.
+func encoderArgs(codec string, keyint int) []string {
+	gop := []string{
+		"-g", fmt.Sprintf("%d", keyint),
+		"-keyint_min", fmt.Sprintf("%d", keyint),
+	}
+	var args []string
+	switch codec {
+	case CodecVP8:
+		args = []string{
+			"-vcodec", "libvpx",
+			"-deadline", "realtime",
+			"-cpu-used", "8",
+			"-lag-in-frames", "0",
+			"-error-resilient", "1",
+			"-auto-alt-ref", "0",
+		}
+	case CodecVP9:
+		args = []string{
+			"-vcodec", "libvpx-vp9",
+			"-deadline", "realtime",
+			"-cpu-used", "8",
+			"-row-mt", "1",
+			"-lag-in-frames", "0",
+			"-error-resilient", "1",
+			// ffmpeg still flags the VP9 RTP packetizer as experimental.
+			"-strict", "experimental",
+		}
+	case CodecAV1:
+		args = []string{
+			"-vcodec", "libaom-av1",
+			"-usage", "realtime",
+			"-cpu-used", "8",
+			"-row-mt", "1",
+			"-lag-in-frames", "0",
+		}
+	default:
+		args = []string{
+			"-vcodec", "libx264",
+			"-preset", "ultrafast",
+			"-tune", "zerolatency",
+			"-profile:v", "baseline",
+			"-bf", "0",
+			"-x264-params", "scenecut=0:repeat-headers=1",
+		}
+	}
+	return append(args, gop...)
+}
+
+// NormalizeCropRect clamps and aligns a crop rectangle to even offsets and dimensions, as
+// every capture pipeline applies it; frames of a crop have the size of this rectangle.
+func NormalizeCropRect(r calib.Rect, m monitor.Monitor) calib.Rect {
 	r = calib.Normalize(r)
 	if r.W < 2 {
 		r.W = 2
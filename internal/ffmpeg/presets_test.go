@@ -0,0 +1,192 @@
+package ffmpeg
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/frudas24/deskslice/internal/calib"
+	"github.com/frudas24/deskslice/internal/monitor"
+)
+
+// TestCompositeFilterArgs_StacksRegions verifies the filter graph crops, pads and stacks each region.
+func TestCompositeFilterArgs_StacksRegions(t *testing.T) {
+	m := monitor.Monitor{Index: 1, W: 1920, H: 1080}
+	args := compositeFilterArgs(m, []calib.Rect{
+		{X: 100, Y: 200, W: 400, H: 300},
+		{X: 0, Y: 800, W: 1200, H: 200},
+	})
+	if len(args) != 4 || args[0] != "-filter_complex" || args[2] != "-map" || args[3] != "[out]" {
+		t.Fatalf("unexpected args: %#v", args)
+	}
+	want := "[0:v]split=2[s0][s1];[s0]crop=400:300:100:200,pad=1200:300:0:0[t0];[s1]crop=1200:200:0:800[t1];[t0][t1]vstack=inputs=2[out]"
+	if args[1] != want {
+		t.Fatalf("unexpected filter:\n got %s\nwant %s", args[1], want)
+	}
+}
+
+// TestCompositeFilterArgs_SingleRegion verifies a single region falls back to a plain crop.
+func TestCompositeFilterArgs_SingleRegion(t *testing.T) {
+	m := monitor.Monitor{Index: 1, W: 1920, H: 1080}
+	args := compositeFilterArgs(m, []calib.Rect{{X: 10, Y: 20, W: 100, H: 50}})
+	if len(args) != 2 || args[0] != "-vf" || !strings.HasPrefix(args[1], "crop=100:50:10:20") {
+		t.Fatalf("unexpected args: %#v", args)
+	}
+}
+
+// TestBuildOutputArgs_CodecSelectsEncoder verifies each codec maps to its encoder and H264 stays the default.
+func TestBuildOutputArgs_CodecSelectsEncoder(t *testing.T) {
+	cases := map[string]string{
+		"":        "libx264",
+		CodecH264: "libx264",
+		CodecVP8:  "libvpx",
+		CodecVP9:  "libvpx-vp9",
+		CodecAV1:  "libaom-av1",
+	}
+	for codec, encoder := range cases {
+		args := buildOutputArgs(Options{FPS: 30, BitrateKbps: 4000, Codec: codec}, 5004, nil)
+		joined := strings.Join(args, " ")
+		if !strings.Contains(joined, "-vcodec "+encoder+" ") {
+			t.Fatalf("codec %q: expected encoder %s in %q", codec, encoder, joined)
+		}
+		if !strings.HasSuffix(joined, "-f rtp rtp://127.0.0.1:5004?pkt_size=1200") {
+			t.Fatalf("codec %q: unexpected output target in %q", codec, joined)
+		}
+	}
+}
+
+// TestBuildOutputArgs_LowLayerSplitsCrop verifies the low layer shares the crop and gets its own scaled RTP output.
+func TestBuildOutputArgs_LowLayerSplitsCrop(t *testing.T) {
+	m := monitor.Monitor{Index: 1, W: 1920, H: 1080}
+	args := buildOutputArgs(Options{FPS: 30, BitrateKbps: 4000, LowPort: 5006}, 5004, cropFilterArgs(m, calib.Rect{X: 10, Y: 20, W: 400, H: 300}))
+	joined := strings.Join(args, " ")
+	wantGraph := "-filter_complex [0:v]crop=400:300:10:20,split=2[hi][lo0];[lo0]scale=trunc(iw/4)*2:trunc(ih/4)*2[lo]"
+	if !strings.Contains(joined, wantGraph) {
+		t.Fatalf("missing layer graph in %q", joined)
+	}
+	hi := strings.Index(joined, "-map [hi]")
+	lo := strings.Index(joined, "-map [lo]")
+	if hi < 0 || lo < hi {
+		t.Fatalf("expected [hi] then [lo] outputs in %q", joined)
+	}
+	if !strings.Contains(joined[hi:lo], "-b:v 4000k") || !strings.Contains(joined[hi:lo], "rtp://127.0.0.1:5004") {
+		t.Fatalf("unexpected high output: %q", joined[hi:lo])
+	}
+	if !strings.Contains(joined[lo:], "-b:v 1000k") || !strings.HasSuffix(joined, "rtp://127.0.0.1:5006?pkt_size=1200") {
+		t.Fatalf("unexpected low output: %q", joined[lo:])
+	}
+}
+
+// TestBuildPreviewOutputArgs_RawOnly verifies a plain preview keeps the crop and only writes raw frames to stdout.
+func TestBuildPreviewOutputArgs_RawOnly(t *testing.T) {
+	m := monitor.Monitor{Index: 1, W: 1920, H: 1080}
+	args := buildPreviewOutputArgs(Options{FPS: 10}, cropFilterArgs(m, calib.Rect{X: 10, Y: 20, W: 400, H: 300}))
+	joined := strings.Join(args, " ")
+	if joined != "-vf crop=400:300:10:20 -an -pix_fmt rgb24 -f rawvideo -" {
+		t.Fatalf("unexpected preview args %q", joined)
+	}
+}
+
+// TestBuildPreviewOutputArgs_SharedFeedsRTPAndRaw verifies a shared preview splits one graph into RTP layers and a throttled raw output.
+func TestBuildPreviewOutputArgs_SharedFeedsRTPAndRaw(t *testing.T) {
+	m := monitor.Monitor{Index: 1, W: 1920, H: 1080}
+	opts := Options{FPS: 30, BitrateKbps: 4000, RTPPort: 5004, LowPort: 5006, PreviewFPS: 8}
+	args := buildPreviewOutputArgs(opts, cropFilterArgs(m, calib.Rect{X: 10, Y: 20, W: 400, H: 300}))
+	joined := strings.Join(args, " ")
+	wantGraph := "-filter_complex [0:v]crop=400:300:10:20,split=3[hi][lo0][raw0];[lo0]scale=trunc(iw/4)*2:trunc(ih/4)*2[lo];[raw0]fps=8[raw]"
+	if !strings.Contains(joined, wantGraph) {
+		t.Fatalf("missing shared graph in %q", joined)
+	}
+	hi := strings.Index(joined, "-map [hi]")
+	lo := strings.Index(joined, "-map [lo]")
+	raw := strings.Index(joined, "-map [raw]")
+	if hi < 0 || lo < hi || raw < lo {
+		t.Fatalf("expected [hi], [lo] then [raw] outputs in %q", joined)
+	}
+	if !strings.Contains(joined[hi:lo], "rtp://127.0.0.1:5004") || !strings.Contains(joined[lo:raw], "rtp://127.0.0.1:5006") {
+		t.Fatalf("unexpected rtp outputs in %q", joined)
+	}
+	if !strings.HasSuffix(joined, "-pix_fmt rgb24 -f rawvideo -") {
+		t.Fatalf("expected raw stdout output last in %q", joined)
+	}
+}
+
+// TestBuildPreviewOutputArgs_SharedComposite verifies composite graphs are re-split from [out] without a low layer.
+func TestBuildPreviewOutputArgs_SharedComposite(t *testing.T) {
+	m := monitor.Monitor{Index: 1, W: 1920, H: 1080}
+	filter := compositeFilterArgs(m, []calib.Rect{{X: 0, Y: 0, W: 400, H: 300}, {X: 0, Y: 800, W: 400, H: 200}})
+	args := buildPreviewOutputArgs(Options{FPS: 30, RTPPort: 5004}, filter)
+	joined := strings.Join(args, " ")
+	if !strings.Contains(joined, "vstack=inputs=2[out];[out]split=2[hi][raw] ") {
+		t.Fatalf("unexpected composite split in %q", joined)
+	}
+	if strings.Contains(joined, "-map [out]") || strings.Contains(joined, "[lo]") {
+		t.Fatalf("unexpected extra outputs in %q", joined)
+	}
+}
+
+// TestBuildRunArgs_LiveCropNamesFilter verifies live crop uses the command-addressable crop instance.
+func TestBuildRunArgs_LiveCropNamesFilter(t *testing.T) {
+	m := monitor.Monitor{Index: 1, W: 1920, H: 1080}
+	args := BuildRunArgs(m, calib.Rect{X: 11, Y: 20, W: 401, H: 300}, Options{FPS: 30, BitrateKbps: 4000, LiveCrop: true}, 5004, true)
+	joined := strings.Join(args, " ")
+	if !strings.Contains(joined, "-vf crop@live=w=400:h=300:x=10:y=20 ") {
+		t.Fatalf("missing live crop filter in %q", joined)
+	}
+	presetup := strings.Join(BuildPresetupArgs(m, Options{FPS: 30, LiveCrop: true}, 5004, true), " ")
+	if !strings.Contains(presetup, "-vf crop@live=w=1920:h=1080:x=0:y=0 ") {
+		t.Fatalf("expected full-monitor live crop in %q", presetup)
+	}
+}
+
+// TestCropCommands_OnlyChangedAxes verifies only moved coordinates are sent to ffmpeg.
+func TestCropCommands_OnlyChangedAxes(t *testing.T) {
+	from := calib.Rect{X: 10, Y: 20, W: 400, H: 300}
+	if got := cropCommands(from, from); got != "" {
+		t.Fatalf("expected no commands, got %q", got)
+	}
+	if got := cropCommands(from, calib.Rect{X: 10, Y: 60, W: 400, H: 300}); got != "ccrop@live -1 y 60\n" {
+		t.Fatalf("unexpected y command %q", got)
+	}
+	if got := cropCommands(from, calib.Rect{X: 0, Y: 0, W: 400, H: 300}); got != "ccrop@live -1 x 0\nccrop@live -1 y 0\n" {
+		t.Fatalf("unexpected commands %q", got)
+	}
+}
+
+// TestBuildPreviewOutputArgs_LiveSharedKeepsRawUncropped verifies the raw branch is split off before the live crop.
+func TestBuildPreviewOutputArgs_LiveSharedKeepsRawUncropped(t *testing.T) {
+	m := monitor.Monitor{Index: 1, W: 1920, H: 1080}
+	opts := Options{FPS: 30, BitrateKbps: 4000, RTPPort: 5004, PreviewFPS: 10, LiveCrop: true}
+	args := buildPreviewOutputArgs(opts, liveCropFilterArgs(m, calib.Rect{X: 10, Y: 20, W: 400, H: 300}))
+	want := "[0:v]split=2[cap][raw0];[raw0]fps=10[raw];[cap]crop@live=w=400:h=300:x=10:y=20,null[hi]"
+	if len(args) < 3 || args[2] != want {
+		t.Fatalf("unexpected graph:\n got %v\nwant %s", args, want)
+	}
+}
+
+// TestBuildPreviewOutputArgs_FFmpegJPEG verifies the ffmpeg encoder path writes a JPEG stream at the mapped qscale.
+func TestBuildPreviewOutputArgs_FFmpegJPEG(t *testing.T) {
+	args := buildPreviewOutputArgs(Options{FPS: 10, FFmpegJPEG: true, JPEGQuality: 100}, nil)
+	joined := strings.Join(args, " ")
+	if joined != "-an -c:v mjpeg -q:v 2 -pix_fmt yuvj420p -f mjpeg -" {
+		t.Fatalf("unexpected preview args %q", joined)
+	}
+	if q := jpegQScale(1); q != 31 {
+		t.Fatalf("expected qscale 31 for quality 1, got %d", q)
+	}
+}
+
+// TestBuildSnapshotArgs_CropsOneFrameToPNG verifies a run snapshot crops like the stream and writes a single PNG.
+func TestBuildSnapshotArgs_CropsOneFrameToPNG(t *testing.T) {
+	m := monitor.Monitor{Index: 1, W: 1920, H: 1080}
+	args, err := BuildSnapshotArgs(ModeRun, m, calib.Rect{X: 10, Y: 20, W: 400, H: 300}, nil, Options{FPS: 30}, false)
+	if err != nil {
+		t.Fatalf("build failed: %v", err)
+	}
+	joined := strings.Join(args, " ")
+	if !strings.Contains(joined, "-vf crop=400:300:10:20 ") || !strings.HasSuffix(joined, "-frames:v 1 -c:v png -f image2pipe -") {
+		t.Fatalf("unexpected snapshot args %q", joined)
+	}
+	if _, err := BuildSnapshotArgs(ModeComposite, m, calib.Rect{}, nil, Options{FPS: 30}, false); err == nil {
+		t.Fatal("expected error for an empty composite layout")
+	}
+}
@@ -2,38 +2,68 @@
 package ffmpeg
 
 import (
+	"bytes"
+	"context"
 	"errors"
-	"fmt"
+	"hash/maphash"
+	"image"
+	"image/jpeg"
 	"io"
 	"log"
 	"os/exec"
+	"slices"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/frudas24/deskslice/internal/activity"
 	"github.com/frudas24/deskslice/internal/calib"
 	"github.com/frudas24/deskslice/internal/mjpeg"
 	"github.com/frudas24/deskslice/internal/monitor"
+	"github.com/frudas24/deskslice/internal/tiles"
+	"github.com/frudas24/deskslice/internal/timeline"
 )
 
 const previewRestartBackoff = 2 * time.Second
 
+// frameSeed seeds the hashes used to detect unchanged preview frames.
+var frameSeed = maphash.MakeSeed()
+
 // Preview captures raw frames via ffmpeg and publishes MJPEG previews.
 type Preview struct {
 	mu      sync.Mutex
 	cmd     *exec.Cmd
+	stdin   io.WriteCloser
 	stdout  io.ReadCloser
 	stream  *mjpeg.Stream
+	tiles   *tiles.Server
+	history *timeline.Timeline
+	motion  *activity.Detector
+	onCrash func(err error)
 	quality int
 	w       int
 	h       int
 	closed  bool
+	shared  bool
+	ffjpeg  bool
 	path    string
 	args    []string
 	loopID  int
 	stopCh  chan struct{}
+	// Live crop state: frames cover the whole monitor and are cropped to crop in-process.
+	// A shared pipeline also moves its RTP crop, which started at startCrop and is at rtpCrop.
+	live      bool
+	monitor   monitor.Monitor
+	crop      calib.Rect
+	startCrop calib.Rect
+	rtpCrop   calib.Rect
+	// waiters receive the next frame read, for snapshots.
+	waiters []chan image.Image
 }
 
+// ErrPreviewStopped is returned by Frame when no preview process is running.
+var ErrPreviewStopped = errors.New("ffmpeg: preview not running")
+
 // NewPreview returns a preview pipeline bound to the given MJPEG stream.
 func NewPreview(stream *mjpeg.Stream, quality int) *Preview {
 	if quality <= 0 || quality > 100 {
@@ -45,6 +75,64 @@ func NewPreview(stream *mjpeg.Stream, quality int) *Preview {
 	}
 }
 
+// SetTiles attaches a tile server that receives the changed tiles of each raw frame. It is
+// only fed by the Go encoder path; previews encoded by ffmpeg have no raw frames to diff.
+func (p *Preview) SetTiles(t *tiles.Server) {
+	p.mu.Lock()
+	p.tiles = t
+	p.mu.Unlock()
+}
+
+// SetTimeline attaches a session timeline that is offered every raw frame. Previews
+// encoded by ffmpeg are sampled through Frame by the timeline's own sampler instead.
+func (p *Preview) SetTimeline(t *timeline.Timeline) {
+	p.mu.Lock()
+	p.history = t
+	p.mu.Unlock()
+}
+
+// SetActivity attaches an activity detector that is offered every raw frame. Previews
+// encoded by ffmpeg have no raw frames, so the detector stays in its unknown state.
+func (p *Preview) SetActivity(d *activity.Detector) {
+	p.mu.Lock()
+	p.motion = d
+	p.mu.Unlock()
+}
+
+// SetCrashHandler sets a callback run when the preview process dies and is restarted.
+func (p *Preview) SetCrashHandler(fn func(err error)) {
+	p.mu.Lock()
+	p.onCrash = fn
+	p.mu.Unlock()
+}
+
+// Frame returns the next frame the running preview reads, already cropped like the stream.
+func (p *Preview) Frame(ctx context.Context) (image.Image, error) {
+	ch := make(chan image.Image, 1)
+	p.mu.Lock()
+	if p.closed || p.cmd == nil {
+		p.mu.Unlock()
+		return nil, ErrPreviewStopped
+	}
+	p.waiters = append(p.waiters, ch)
+	p.mu.Unlock()
+	select {
+	case img := <-ch:
+		return img, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// takeWaiters removes and returns the pending Frame callers.
+func (p *Preview) takeWaiters() []chan image.Image {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	waiters := p.waiters
+	p.waiters = nil
+	return waiters
+}
+
 // SetQuality updates the JPEG quality used for subsequent MJPEG frames.
 func (p *Preview) SetQuality(quality int) {
 	if quality <= 0 || quality > 100 {
@@ -57,12 +145,43 @@ func (p *Preview) SetQuality(quality int) {
 
 // StartPresetup starts a full-screen MJPEG preview for the selected monitor.
 func (p *Preview) StartPresetup(m monitor.Monitor, opts Options) error {
-	return p.start(m, calib.Rect{}, opts, false)
+	if opts.LiveCrop && !opts.FFmpegJPEG {
+		return p.startLive(m, opts, calib.Rect{W: m.W, H: m.H})
+	}
+	_, err := p.start(m, opts, nil, m.W, m.H)
+	return err
 }
 
 // StartRun starts a cropped MJPEG preview of the plugin area.
 func (p *Preview) StartRun(m monitor.Monitor, plugin calib.Rect, opts Options) error {
-	return p.start(m, plugin, opts, true)
+	if opts.LiveCrop && !opts.FFmpegJPEG {
+		return p.startLive(m, opts, plugin)
+	}
+	plugin = NormalizeCropRect(plugin, m)
+	_, err := p.start(m, opts, cropFilterArgs(m, plugin), plugin.W, plugin.H)
+	return err
+}
+
+// StartComposite starts an MJPEG preview of several regions stacked into one frame.
+func (p *Preview) StartComposite(m monitor.Monitor, regions []calib.Rect, opts Options) error {
+	layout := calib.StackLayout(regions, m.W, m.H)
+	if len(layout.Tiles) == 0 {
+		return errors.New("composite layout has no regions")
+	}
+	_, err := p.start(m, opts, compositeFilterArgs(m, regions), layout.W, layout.H)
+	return err
+}
+
+// SetCrop changes the crop of a live preview without restarting ffmpeg. It reports false
+// when the preview was not started with Options.LiveCrop on the same monitor, or when a
+// shared pipeline would have to change its encoded size, so the caller must restart.
+func (p *Preview) SetCrop(m monitor.Monitor, crop calib.Rect) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.live || p.closed || p.cmd == nil || m != p.monitor {
+		return false
+	}
+	return p.setCropLocked(NormalizeCropRect(crop, m))
 }
 
 // Stop terminates the preview process.
@@ -74,51 +193,108 @@ func (p *Preview) Stop() error {
 	return p.stopLocked()
 }
 
-// start configures and launches the preview pipeline for the given mode.
-func (p *Preview) start(m monitor.Monitor, plugin calib.Rect, opts Options, cropped bool) error {
+// startLive captures the whole monitor and applies the crop in-process. A running shared
+// pipeline with the same crop size keeps its process and only moves its RTP crop.
+func (p *Preview) startLive(m monitor.Monitor, opts Options, crop calib.Rect) error {
+	crop = NormalizeCropRect(crop, m)
+	base := crop
+	var filterArgs []string
+	if opts.RTPPort > 0 {
+		p.mu.Lock()
+		if p.live && p.monitor == m && p.startCrop.W == crop.W && p.startCrop.H == crop.H {
+			base = p.startCrop
+		}
+		p.mu.Unlock()
+		filterArgs = liveCropFilterArgs(m, base)
+	}
+	started, err := p.start(m, opts, filterArgs, m.W, m.H)
+	if err != nil {
+		return err
+	}
+
 	p.mu.Lock()
 	defer p.mu.Unlock()
-	p.closed = false
-	p.stopLoopLocked()
-	if err := p.stopLocked(); err != nil {
-		return err
+	if started {
+		p.startCrop = base
+		p.rtpCrop = base
+	}
+	p.live = true
+	p.monitor = m
+	if !p.setCropLocked(crop) {
+		return errors.New("live crop failed")
+	}
+	return nil
+}
+
+// setCropLocked records the in-process crop and moves the shared RTP crop to match.
+func (p *Preview) setCropLocked(crop calib.Rect) bool {
+	if p.shared {
+		if crop.W != p.rtpCrop.W || crop.H != p.rtpCrop.H || p.stdin == nil {
+			return false
+		}
+		if _, err := io.WriteString(p.stdin, cropCommands(p.rtpCrop, crop)); err != nil {
+			log.Printf("ffmpeg: preview live crop failed: %v", err)
+			return false
+		}
+		p.rtpCrop = crop
 	}
+	p.crop = crop
+	return true
+}
+
+// start configures and launches the preview pipeline with the given filter and output size.
+// A running pipeline with identical arguments is left untouched; the result reports whether
+// a new process was started.
+func (p *Preview) start(m monitor.Monitor, opts Options, filterArgs []string, outW, outH int) (bool, error) {
 	if opts.FFmpegPath == "" {
-		return errors.New("FFmpegPath is required")
+		return false, errors.New("FFmpegPath is required")
 	}
 	if opts.FPS <= 0 {
 		opts.FPS = 30
 	}
+	if opts.RTPPort > 0 && opts.BitrateKbps <= 0 {
+		opts.BitrateKbps = 6000
+	}
 	useD3D11 := opts.CaptureDriver == "" || strings.EqualFold(opts.CaptureDriver, "d3d11grab")
 	args := buildInputArgs(m, opts, useD3D11)
+	args = append(args, buildPreviewOutputArgs(opts, filterArgs)...)
 
-	outW, outH := m.W, m.H
-	if cropped {
-		plugin = normalizeCropRect(plugin, m)
-		outW, outH = plugin.W, plugin.H
-		args = append(args, "-vf", fmt.Sprintf("crop=%d:%d:%d:%d", plugin.W, plugin.H, plugin.X, plugin.Y))
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.closed && p.cmd != nil && p.path == opts.FFmpegPath && slices.Equal(p.args, args) {
+		return false, nil
+	}
+	p.closed = false
+	p.stopLoopLocked()
+	if err := p.stopLocked(); err != nil {
+		return false, err
 	}
-	args = append(args, "-an", "-pix_fmt", "rgb24", "-f", "rawvideo", "-")
 
 	p.path = opts.FFmpegPath
 	p.args = args
 	p.w = outW
 	p.h = outH
+	p.shared = opts.RTPPort > 0
+	p.ffjpeg = opts.FFmpegJPEG
+	p.live = false
 	p.loopID++
 	p.stopCh = make(chan struct{})
 	loopID := p.loopID
 	stopCh := p.stopCh
 	stream := p.stream
-	quality := p.quality
 	width := p.w
 	height := p.h
 
 	log.Printf("ffmpeg: preview %s %s", p.path, strings.Join(args, " "))
 	if err := p.startProcessLocked(); err != nil {
-		return err
+		return false, err
 	}
-	go p.loop(loopID, stopCh, stream, quality, width, height)
-	return nil
+	if p.ffjpeg {
+		go p.jpegLoop(loopID, stopCh, stream)
+	} else {
+		go p.loop(loopID, stopCh, stream, width, height)
+	}
+	return true, nil
 }
 
 // startProcessLocked launches ffmpeg while holding the preview lock.
@@ -129,16 +305,25 @@ func (p *Preview) startProcessLocked() error {
 	if err != nil {
 		return err
 	}
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
 	if err := cmd.Start(); err != nil {
 		return err
 	}
 	p.cmd = cmd
+	p.stdin = stdin
 	p.stdout = stdout
 	return nil
 }
 
 // stopLocked stops any running ffmpeg process while holding the preview lock.
 func (p *Preview) stopLocked() error {
+	if p.stdin != nil {
+		_ = p.stdin.Close()
+		p.stdin = nil
+	}
 	if p.stdout != nil {
 		_ = p.stdout.Close()
 		p.stdout = nil
@@ -151,9 +336,14 @@ func (p *Preview) stopLocked() error {
 	return nil
 }
 
-// loop reads raw frames and publishes them to the MJPEG stream.
-func (p *Preview) loop(loopID int, stopCh <-chan struct{}, stream *mjpeg.Stream, quality, width, height int) {
+// loop reads raw frames and publishes them to the MJPEG stream and tile viewers. Frames are
+// only encoded while someone watches: a shared pipeline keeps producing them under WebRTC.
+func (p *Preview) loop(loopID int, stopCh <-chan struct{}, stream *mjpeg.Stream, width, height int) {
 	raw := make([]byte, width*height*3)
+	var (
+		cropped []byte
+		dedup   frameDedup
+	)
 	for {
 		select {
 		case <-stopCh:
@@ -163,6 +353,11 @@ func (p *Preview) loop(loopID int, stopCh <-chan struct{}, stream *mjpeg.Stream,
 		p.mu.Lock()
 		stdout := p.stdout
 		closed := p.closed || loopID != p.loopID
+		quality := p.quality
+		live, crop := p.live, p.crop
+		tileSink := p.tiles
+		history := p.history
+		motion := p.motion
 		p.mu.Unlock()
 		if closed || stdout == nil {
 			return
@@ -173,9 +368,107 @@ func (p *Preview) loop(loopID int, stopCh <-chan struct{}, stream *mjpeg.Stream,
 			}
 			continue
 		}
-		if stream != nil {
-			jpg := mjpeg.EncodeRGBToJPEG(raw, width, height, quality)
-			stream.Publish(jpg)
+		frame, w, h := raw, width, height
+		if live {
+			cropped = cropRGB(cropped, raw, width, crop)
+			frame, w, h = cropped, crop.W, crop.H
+		}
+		history.Observe(frame, w, h)
+		motion.Observe(frame, w, h)
+		if waiters := p.takeWaiters(); len(waiters) > 0 {
+			img := mjpeg.RGBToImage(frame, w, h)
+			for _, ch := range waiters {
+				ch <- img
+			}
+		}
+		// A static panel produces identical frames; skip encoding until something changes.
+		sum := maphash.Bytes(frameSeed, frame)
+		tileViewers := tileSink != nil && tileSink.HasSubscribers()
+		if dedup.same(sum, quality, tileViewers) {
+			continue
+		}
+		published := false
+		if stream != nil && stream.HasSubscribers() {
+			stream.PublishRGB(frame, w, h, quality)
+			published = true
+		}
+		if tileViewers {
+			tileSink.Publish(frame, w, h, quality)
+			published = true
+		}
+		if published {
+			dedup = frameDedup{sum: sum, quality: quality, tiles: tileViewers, valid: true}
+		} else {
+			dedup = frameDedup{}
+		}
+	}
+}
+
+// frameDedup remembers the last published raw frame so a static panel is not re-encoded.
+// Whether tile viewers were connected is part of it: one that joins needs a frame even if
+// the panel never changes.
+type frameDedup struct {
+	sum     uint64
+	quality int
+	tiles   bool
+	valid   bool
+}
+
+// same reports whether a frame matches the last published one for the same audience.
+func (d frameDedup) same(sum uint64, quality int, tiles bool) bool {
+	return d.valid && d.sum == sum && d.quality == quality && d.tiles == tiles
+}
+
+// jpegLoop splits the JPEG frames ffmpeg encodes and publishes them to the MJPEG stream.
+func (p *Preview) jpegLoop(loopID int, stopCh <-chan struct{}, stream *mjpeg.Stream) {
+	var (
+		reader  *mjpeg.Reader
+		source  io.Reader
+		lastSum uint64
+	)
+	for {
+		select {
+		case <-stopCh:
+			return
+		default:
+		}
+		p.mu.Lock()
+		stdout := p.stdout
+		closed := p.closed || loopID != p.loopID
+		p.mu.Unlock()
+		if closed || stdout == nil {
+			return
+		}
+		if source != stdout {
+			// A restarted process has a new pipe and starts on a frame boundary.
+			reader, source = mjpeg.NewReader(stdout), stdout
+		}
+		jpg, err := reader.Next()
+		if err != nil {
+			if !p.handleReadError(err, loopID, stopCh) {
+				return
+			}
+			continue
+		}
+		if waiters := p.takeWaiters(); len(waiters) > 0 {
+			if img, err := jpeg.Decode(bytes.NewReader(jpg)); err == nil {
+				for _, ch := range waiters {
+					ch <- img
+				}
+			} else {
+				p.mu.Lock()
+				p.waiters = append(p.waiters, waiters...)
+				p.mu.Unlock()
+			}
+		}
+		if stream != nil && stream.HasSubscribers() {
+			// ffmpeg's encoder is deterministic, so an unchanged picture gives identical bytes.
+			if sum := maphash.Bytes(frameSeed, jpg); sum != lastSum {
+				stream.Publish(jpg)
+				lastSum = sum
+			}
+		} else {
+			lastSum = 0
 		}
 	}
 }
@@ -192,9 +485,13 @@ func (p *Preview) handleReadError(err error, loopID int, stopCh <-chan struct{})
 		p.mu.Unlock()
 		return false
 	}
+	onCrash := p.onCrash
 	p.mu.Unlock()
 
 	log.Printf("ffmpeg: preview read error: %v (restart in %s)", err, previewRestartBackoff)
+	if onCrash != nil {
+		onCrash(err)
+	}
 	time.Sleep(previewRestartBackoff)
 
 	p.mu.Lock()
@@ -210,9 +507,23 @@ func (p *Preview) handleReadError(err error, loopID int, stopCh <-chan struct{})
 		log.Printf("ffmpeg: preview restart error: %v", err)
 		return false
 	}
+	if p.live && p.shared {
+		// The new process starts from the crop in its arguments; replay the live moves.
+		_, _ = io.WriteString(p.stdin, cropCommands(p.startCrop, p.rtpCrop))
+	}
 	return true
 }
 
+// cropRGB copies the rectangle r out of a packed RGB24 frame of the given width into dst.
+func cropRGB(dst, src []byte, width int, r calib.Rect) []byte {
+	dst = slices.Grow(dst[:0], r.W*r.H*3)
+	for y := r.Y; y < r.Y+r.H; y++ {
+		start := (y*width + r.X) * 3
+		dst = append(dst, src[start:start+r.W*3]...)
+	}
+	return dst
+}
+
 // stopLoopLocked signals the current preview loop to stop.
 func (p *Preview) stopLoopLocked() {
 	if p.stopCh == nil {
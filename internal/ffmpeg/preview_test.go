@@ -0,0 +1,45 @@
+package ffmpeg
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/frudas24/deskslice/internal/calib"
+)
+
+// TestCropRGB_CopiesRectangle verifies in-process cropping picks the right rows and columns.
+func TestCropRGB_CopiesRectangle(t *testing.T) {
+	const width, height = 4, 3
+	src := make([]byte, width*height*3)
+	for i := range src {
+		src[i] = byte(i)
+	}
+	got := cropRGB(nil, src, width, calib.Rect{X: 1, Y: 1, W: 2, H: 2})
+	want := []byte{15, 16, 17, 18, 19, 20, 27, 28, 29, 30, 31, 32}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("unexpected crop %v, want %v", got, want)
+	}
+	again := cropRGB(got, src, width, calib.Rect{X: 0, Y: 2, W: 1, H: 1})
+	if !bytes.Equal(again, []byte{24, 25, 26}) {
+		t.Fatalf("unexpected reused crop %v", again)
+	}
+}
+
+// TestFrameDedup_SkipsStaticFramesUntilTileViewersJoin verifies identical frames are skipped
+// while the audience stays the same and republished when tile viewers arrive or quality changes.
+func TestFrameDedup_SkipsStaticFramesUntilTileViewersJoin(t *testing.T) {
+	var d frameDedup
+	if d.same(42, 60, false) {
+		t.Fatalf("nothing published yet")
+	}
+	d = frameDedup{sum: 42, quality: 60, valid: true}
+	if !d.same(42, 60, false) {
+		t.Fatalf("identical MJPEG-only frame should be skipped")
+	}
+	if d.same(42, 60, true) {
+		t.Fatalf("a joining tile viewer needs the frame")
+	}
+	if d.same(42, 80, false) || d.same(43, 60, false) {
+		t.Fatalf("quality or content changes must be published")
+	}
+}
@@ -4,6 +4,7 @@ package ffmpeg
 import (
 	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net"
 	"os"
@@ -21,13 +22,22 @@ const (
 	ModePresetup = "presetup"
 	// ModeRun captures a cropped plugin rectangle.
 	ModeRun = "run"
+	// ModeComposite captures several regions stacked into one frame.
+	ModeComposite = "composite"
 )
 
 // Runner manages the ffmpeg process lifecycle.
 type Runner struct {
 	mu     sync.Mutex
 	cmd    *exec.Cmd
+	stdin  io.WriteCloser
 	waitCh chan error
+	// live is set when the process runs a named crop that SetCrop can move.
+	live    bool
+	monitor monitor.Monitor
+	crop    calib.Rect
+	// onExit is told when the process exits without being stopped.
+	onExit func(err error)
 }
 
 // NewRunner returns a new Runner instance.
@@ -35,6 +45,13 @@ func NewRunner() *Runner {
 	return &Runner{}
 }
 
+// SetExitHandler sets a callback run when a started ffmpeg exits on its own.
+func (r *Runner) SetExitHandler(fn func(err error)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.onExit = fn
+}
+
 // StartPresetup starts fullscreen capture and returns the RTP port and stop function.
 func (r *Runner) StartPresetup(m monitor.Monitor, opts Options) (int, func() error, error) {
 	return r.start(ModePresetup, m, calib.Rect{}, opts)
@@ -57,6 +74,14 @@ func (r *Runner) StartRunOnPort(m monitor.Monitor, plugin calib.Rect, opts Optio
 	return stop, err
 }
 
+// StartCompositeOnPort starts a stacked capture of several regions to a fixed RTP port.
+func (r *Runner) StartCompositeOnPort(m monitor.Monitor, regions []calib.Rect, opts Options, port int) (func() error, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, stop, err := r.startLockedOnPort(ModeComposite, m, calib.Rect{}, regions, opts, port)
+	return stop, err
+}
+
 // Stop terminates any running ffmpeg process.
 func (r *Runner) Stop() error {
 	r.mu.Lock()
@@ -85,7 +110,7 @@ func (r *Runner) start(mode string, m monitor.Monitor, plugin calib.Rect, opts O
 func (r *Runner) startOnPort(mode string, m monitor.Monitor, plugin calib.Rect, opts Options, port int) (int, func() error, error) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	return r.startLockedOnPort(mode, m, plugin, opts, port)
+	return r.startLockedOnPort(mode, m, plugin, nil, opts, port)
 }
 
 // startLocked starts ffmpeg while holding the runner lock.
@@ -105,31 +130,39 @@ func (r *Runner) startLocked(mode string, m monitor.Monitor, plugin calib.Rect,
 		return 0, nil, err
 	}
 
-	return r.startLockedOnPort(mode, m, plugin, opts, port)
+	return r.startLockedOnPort(mode, m, plugin, nil, opts, port)
 }
 
 // startLockedOnPort starts ffmpeg while holding the runner lock, targeting the provided RTP port.
-func (r *Runner) startLockedOnPort(mode string, m monitor.Monitor, plugin calib.Rect, opts Options, port int) (int, func() error, error) {
+func (r *Runner) startLockedOnPort(mode string, m monitor.Monitor, plugin calib.Rect, regions []calib.Rect, opts Options, port int) (int, func() error, error) {
 	if port <= 0 {
 		return 0, nil, fmt.Errorf("invalid rtp port %d", port)
 	}
 
 	useD3D11 := opts.CaptureDriver == "" || strings.EqualFold(opts.CaptureDriver, "d3d11grab")
-	args, err := buildArgs(mode, m, plugin, opts, port, useD3D11)
+	args, err := buildArgs(mode, m, plugin, regions, opts, port, useD3D11)
 	if err != nil {
 		return 0, nil, err
 	}
 	log.Printf("ffmpeg: start %s %s", opts.FFmpegPath, strings.Join(args, " "))
 
-	cmd, waitCh, err := startWithRetry(opts.FFmpegPath, args, func() ([]string, error) {
-		return buildArgs(mode, m, plugin, opts, port, false)
+	cmd, stdin, waitCh, err := startWithRetry(opts.FFmpegPath, args, func() ([]string, error) {
+		return buildArgs(mode, m, plugin, regions, opts, port, false)
 	})
 	if err != nil {
 		return 0, nil, err
 	}
 
 	r.cmd = cmd
-	r.waitCh = waitCh
+	r.stdin = stdin
+	r.waitCh = r.watch(cmd, waitCh)
+	r.live = opts.LiveCrop && mode != ModeComposite
+	r.monitor = m
+	r.crop = calib.Rect{W: m.W, H: m.H}
+	if mode == ModeRun {
+		r.crop = plugin
+	}
+	r.crop = NormalizeCropRect(r.crop, m)
 	stop := func() error {
 		r.mu.Lock()
 		defer r.mu.Unlock()
@@ -138,8 +171,55 @@ func (r *Runner) startLockedOnPort(mode string, m monitor.Monitor, plugin calib.
 	return port, stop, nil
 }
 
+// watch relays the exit of cmd and reports it to the exit handler unless stopLocked, which
+// holds the lock while it waits, stopped the process.
+func (r *Runner) watch(cmd *exec.Cmd, waitCh <-chan error) chan error {
+	done := make(chan error, 1)
+	go func() {
+		err := <-waitCh
+		done <- err
+		r.mu.Lock()
+		crashed := r.cmd == cmd
+		onExit := r.onExit
+		r.mu.Unlock()
+		if crashed && onExit != nil {
+			if err == nil {
+				err = errors.New("ffmpeg exited")
+			}
+			onExit(err)
+		}
+	}()
+	return done
+}
+
+// SetCrop moves the crop of a running capture without restarting ffmpeg. It reports false
+// when the process was not started with Options.LiveCrop, captures another monitor or the
+// crop size changed (the encoder cannot change resolution), so the caller must restart.
+func (r *Runner) SetCrop(m monitor.Monitor, crop calib.Rect) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.live || r.cmd == nil || r.stdin == nil || m != r.monitor {
+		return false
+	}
+	crop = NormalizeCropRect(crop, m)
+	if crop.W != r.crop.W || crop.H != r.crop.H {
+		return false
+	}
+	if _, err := io.WriteString(r.stdin, cropCommands(r.crop, crop)); err != nil {
+		log.Printf("ffmpeg: live crop failed: %v", err)
+		return false
+	}
+	r.crop = crop
+	return true
+}
+
 // stopLocked stops the current ffmpeg process without acquiring the lock.
 func (r *Runner) stopLocked() error {
+	r.live = false
+	if r.stdin != nil {
+		_ = r.stdin.Close()
+		r.stdin = nil
+	}
 	if r.cmd == nil || r.cmd.Process == nil {
 		return nil
 	}
@@ -155,53 +235,64 @@ func (r *Runner) stopLocked() error {
 }
 
 // buildArgs selects the correct preset for the requested mode.
-func buildArgs(mode string, m monitor.Monitor, plugin calib.Rect, opts Options, port int, useD3D11 bool) ([]string, error) {
+func buildArgs(mode string, m monitor.Monitor, plugin calib.Rect, regions []calib.Rect, opts Options, port int, useD3D11 bool) ([]string, error) {
 	switch mode {
 	case ModePresetup:
 		return BuildPresetupArgs(m, opts, port, useD3D11), nil
 	case ModeRun:
 		return BuildRunArgs(m, plugin, opts, port, useD3D11), nil
+	case ModeComposite:
+		if len(calib.StackLayout(regions, m.W, m.H).Tiles) == 0 {
+			return nil, errors.New("composite layout has no regions")
+		}
+		return BuildCompositeArgs(m, regions, opts, port, useD3D11), nil
 	default:
 		return nil, fmt.Errorf("unknown mode %q", mode)
 	}
 }
 
-// startCmd launches ffmpeg with the provided args.
-func startCmd(path string, args []string) (*exec.Cmd, error) {
+// startCmd launches ffmpeg with the provided args. Its stdin stays open for interactive
+// filter commands.
+func startCmd(path string, args []string) (*exec.Cmd, io.WriteCloser, error) {
 	cmd := exec.Command(path, args...)
 	configureCmd(cmd)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, nil, err
+	}
 	if err := cmd.Start(); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	return cmd, nil
+	return cmd, stdin, nil
 }
 
 // startWithRetry launches ffmpeg and retries with backoff if it exits early.
-func startWithRetry(path string, args []string, fallback func() ([]string, error)) (*exec.Cmd, chan error, error) {
+func startWithRetry(path string, args []string, fallback func() ([]string, error)) (*exec.Cmd, io.WriteCloser, chan error, error) {
 	var (
 		cmd    *exec.Cmd
+		stdin  io.WriteCloser
 		waitCh chan error
 		err    error
 	)
 	backoff := 500 * time.Millisecond
 	for attempt := 0; attempt < 3; attempt++ {
-		cmd, waitCh, err = startWithFallback(path, args, fallback)
+		cmd, stdin, waitCh, err = startWithFallback(path, args, fallback)
 		if err == nil {
-			return cmd, waitCh, nil
+			return cmd, stdin, waitCh, nil
 		}
 		time.Sleep(backoff)
 		backoff *= 2
 	}
-	return nil, nil, err
+	return nil, nil, nil, err
 }
 
 // startWithFallback launches ffmpeg and falls back if it exits early.
-func startWithFallback(path string, args []string, fallback func() ([]string, error)) (*exec.Cmd, chan error, error) {
-	cmd, err := startCmd(path, args)
+func startWithFallback(path string, args []string, fallback func() ([]string, error)) (*exec.Cmd, io.WriteCloser, chan error, error) {
+	cmd, stdin, err := startCmd(path, args)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
 	waitCh := make(chan error, 1)
 	go func() {
@@ -217,15 +308,15 @@ func startWithFallback(path string, args []string, fallback func() ([]string, er
 		}
 		fallbackArgs, err := fallback()
 		if err != nil {
-			return nil, nil, err
+			return nil, nil, nil, err
 		}
 		log.Printf("ffmpeg: fallback %s %s", path, strings.Join(fallbackArgs, " "))
-		cmd, err = startCmd(path, fallbackArgs)
+		cmd, stdin, err = startCmd(path, fallbackArgs)
 		if err != nil {
 			if exitErr != nil {
-				return nil, nil, fmt.Errorf("ffmpeg exited early: %w", exitErr)
+				return nil, nil, nil, fmt.Errorf("ffmpeg exited early: %w", exitErr)
 			}
-			return nil, nil, err
+			return nil, nil, nil, err
 		}
 		waitCh = make(chan error, 1)
 		go func() {
@@ -239,13 +330,13 @@ func startWithFallback(path string, args []string, fallback func() ([]string, er
 				log.Printf("ffmpeg: fallback exited early without error")
 			}
 			if exitErr != nil {
-				return nil, nil, fmt.Errorf("ffmpeg exited early: %w", exitErr)
+				return nil, nil, nil, fmt.Errorf("ffmpeg exited early: %w", exitErr)
 			}
-			return nil, nil, fmt.Errorf("ffmpeg exited early")
+			return nil, nil, nil, fmt.Errorf("ffmpeg exited early")
 		}
 	}
 
-	return cmd, waitCh, nil
+	return cmd, stdin, waitCh, nil
 }
 
 // waitForExit waits for a process to exit or times out.
@@ -0,0 +1,78 @@
+// Package ffmpeg builds ffmpeg command presets for streaming.
+package ffmpeg
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"os/exec"
+	"strings"
+
+	"github.com/frudas24/deskslice/internal/calib"
+	"github.com/frudas24/deskslice/internal/monitor"
+)
+
+// BuildSnapshotArgs returns ffmpeg args that capture one frame of the mode's crop and write
+// it to stdout as PNG.
+func BuildSnapshotArgs(mode string, m monitor.Monitor, plugin calib.Rect, regions []calib.Rect, opts Options, useD3D11 bool) ([]string, error) {
+	var filterArgs []string
+	switch mode {
+	case ModePresetup:
+	case ModeRun:
+		filterArgs = cropFilterArgs(m, plugin)
+	case ModeComposite:
+		if len(calib.StackLayout(regions, m.W, m.H).Tiles) == 0 {
+			return nil, errors.New("composite layout has no regions")
+		}
+		filterArgs = compositeFilterArgs(m, regions)
+	default:
+		return nil, fmt.Errorf("unknown mode %q", mode)
+	}
+	args := buildInputArgs(m, opts, useD3D11)
+	args = append(args, filterArgs...)
+	return append(args, "-an", "-frames:v", "1", "-c:v", "png", "-f", "image2pipe", "-"), nil
+}
+
+// Capture grabs a single frame with a one-shot ffmpeg process, for snapshots taken while no
+// preview is running. The default driver falls back to gdigrab like the streaming runner.
+func Capture(ctx context.Context, mode string, m monitor.Monitor, plugin calib.Rect, regions []calib.Rect, opts Options) (image.Image, error) {
+	if opts.FFmpegPath == "" {
+		return nil, errors.New("FFmpegPath is required")
+	}
+	if opts.FPS <= 0 {
+		opts.FPS = 30
+	}
+	useD3D11 := opts.CaptureDriver == "" || strings.EqualFold(opts.CaptureDriver, "d3d11grab")
+	img, err := captureOnce(ctx, mode, m, plugin, regions, opts, useD3D11)
+	if err != nil && useD3D11 && ctx.Err() == nil {
+		return captureOnce(ctx, mode, m, plugin, regions, opts, false)
+	}
+	return img, err
+}
+
+// captureOnce runs one capture attempt and decodes its PNG output.
+func captureOnce(ctx context.Context, mode string, m monitor.Monitor, plugin calib.Rect, regions []calib.Rect, opts Options, useD3D11 bool) (image.Image, error) {
+	args, err := BuildSnapshotArgs(mode, m, plugin, regions, opts, useD3D11)
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.CommandContext(ctx, opts.FFmpegPath, append([]string{"-hide_banner", "-loglevel", "error"}, args...)...)
+	configureCmd(cmd)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("ffmpeg snapshot: %w: %s", err, msg)
+		}
+		return nil, fmt.Errorf("ffmpeg snapshot: %w", err)
+	}
+	img, err := png.Decode(&stdout)
+	if err != nil {
+		return nil, fmt.Errorf("ffmpeg snapshot: %w", err)
+	}
+	return img, nil
+}
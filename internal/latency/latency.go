@@ -0,0 +1,91 @@
+// Package latency aggregates input and streaming latency samples into percentiles.
+package latency
+
+import (
+	"math"
+	"sort"
+	"sync"
+)
+
+// Series names reported by the control protocol and exposed in /api/state.
+const (
+	// SeriesRTT is the network round trip of a control ping/pong.
+	SeriesRTT = "rttMs"
+	// SeriesInput is the client-observed time from sending a marked click to receiving its probe report.
+	SeriesInput = "inputMs"
+	// SeriesEncode is the host time from injecting a marked click to forwarding the next complete frame.
+	SeriesEncode = "encodeMs"
+)
+
+// DefaultWindow is the number of recent samples kept per series.
+const DefaultWindow = 200
+
+// Summary describes the distribution of recent samples of one series.
+type Summary struct {
+	Count int     `json:"count"`
+	P50   float64 `json:"p50"`
+	P90   float64 `json:"p90"`
+	P99   float64 `json:"p99"`
+}
+
+// Recorder keeps a bounded window of samples per series.
+type Recorder struct {
+	mu     sync.Mutex
+	window int
+	series map[string][]float64
+}
+
+// NewRecorder returns a recorder keeping the last window samples of each series.
+func NewRecorder(window int) *Recorder {
+	if window <= 0 {
+		window = DefaultWindow
+	}
+	return &Recorder{window: window, series: make(map[string][]float64)}
+}
+
+// Observe records a sample in milliseconds. Negative or non-finite samples are dropped.
+func (r *Recorder) Observe(series string, ms float64) {
+	if r == nil || ms < 0 || math.IsNaN(ms) || math.IsInf(ms, 0) {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	samples := append(r.series[series], ms)
+	if over := len(samples) - r.window; over > 0 {
+		samples = append(samples[:0:0], samples[over:]...)
+	}
+	r.series[series] = samples
+}
+
+// Summary returns percentiles for every series with at least one sample.
+func (r *Recorder) Summary() map[string]Summary {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.series) == 0 {
+		return nil
+	}
+	out := make(map[string]Summary, len(r.series))
+	for name, samples := range r.series {
+		sorted := append([]float64(nil), samples...)
+		sort.Float64s(sorted)
+		out[name] = Summary{
+			Count: len(sorted),
+			P50:   percentile(sorted, 50),
+			P90:   percentile(sorted, 90),
+			P99:   percentile(sorted, 99),
+		}
+	}
+	return out
+}
+
+// percentile returns the nearest-rank percentile of sorted samples.
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	return sorted[max(rank-1, 0)]
+}
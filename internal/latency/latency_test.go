@@ -0,0 +1,31 @@
+package latency
+
+import "testing"
+
+// TestRecorder_SummarizesPercentiles verifies nearest-rank percentiles over the recorded window.
+func TestRecorder_SummarizesPercentiles(t *testing.T) {
+	r := NewRecorder(100)
+	for i := 1; i <= 100; i++ {
+		r.Observe(SeriesRTT, float64(i))
+	}
+	r.Observe(SeriesRTT, -1)
+	got := r.Summary()[SeriesRTT]
+	if got != (Summary{Count: 100, P50: 50, P90: 90, P99: 99}) {
+		t.Fatalf("unexpected summary: %+v", got)
+	}
+}
+
+// TestRecorder_KeepsWindow verifies old samples fall out of the window.
+func TestRecorder_KeepsWindow(t *testing.T) {
+	r := NewRecorder(3)
+	for _, v := range []float64{100, 1, 2, 3} {
+		r.Observe(SeriesInput, v)
+	}
+	got := r.Summary()[SeriesInput]
+	if got.Count != 3 || got.P99 != 3 {
+		t.Fatalf("unexpected summary: %+v", got)
+	}
+	if NewRecorder(0).Summary() != nil {
+		t.Fatalf("expected nil summary without samples")
+	}
+}
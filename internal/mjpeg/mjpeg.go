@@ -13,102 +13,174 @@ import (
 
 const boundary = "frame"
 
-// Stream broadcasts JPEG frames to connected HTTP clients.
+// keepaliveInterval is how often an idle subscriber is re-sent the latest frame.
+const keepaliveInterval = time.Second
+
+// Stream broadcasts JPEG frames to connected HTTP clients. Each subscriber picks its own
+// interval, quality and scale; every variant is encoded at most once per frame and shared
+// by all subscribers that asked for it. Encoding runs on the subscribers' goroutines, so mu
+// only guards swapping the latest frame and the subscriber bookkeeping.
 type Stream struct {
 	mu          sync.RWMutex
-	subs        map[chan []byte]struct{}
-	last        []byte
+	subs        map[*subscriber]struct{}
+	nextID      int
+	cur         *frame
 	minInterval time.Duration
-	lastPush    time.Time
 }
 
-// NewStream creates a new stream with a minimum publish interval.
+// NewStream creates a new stream with the default minimum interval between frames.
 func NewStream(minInterval time.Duration) *Stream {
 	return &Stream{
-		subs:        make(map[chan []byte]struct{}),
+		subs:        make(map[*subscriber]struct{}),
 		minInterval: minInterval,
 	}
 }
 
-// SetMinInterval sets the minimum interval between published frames.
+// SetMinInterval sets the interval used by subscribers that did not request their own.
 func (s *Stream) SetMinInterval(d time.Duration) {
 	s.mu.Lock()
 	s.minInterval = d
 	s.mu.Unlock()
 }
 
-// Publish sends a JPEG frame to all subscribers with throttling.
+// Publish sends an already encoded JPEG frame to the subscribers that are due. Subscribers
+// asking for another quality or scale get it re-encoded from the decoded frame.
 func (s *Stream) Publish(jpg []byte) {
-	now := time.Now()
+	f := newFrame(&source{jpg: append([]byte(nil), jpg...)})
 	s.mu.Lock()
-	if s.minInterval > 0 && now.Sub(s.lastPush) < s.minInterval {
-		s.last = append([]byte(nil), jpg...)
-		s.mu.Unlock()
+	defer s.mu.Unlock()
+	s.publishLocked(f)
+}
+
+// PublishRGB encodes a packed RGB24 frame for the subscribers that are due. quality is used
+// by subscribers that did not request their own.
+func (s *Stream) PublishRGB(rgb []byte, w, h, quality int) {
+	f := newFrame(&source{rgb: append([]byte(nil), rgb...), w: w, h: h, quality: quality})
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.publishLocked(f)
+}
+
+// publishLocked makes f the latest frame and queues it for every due subscriber.
+func (s *Stream) publishLocked(f *frame) {
+	now := time.Now()
+	s.cur = f
+	for sub := range s.subs {
+		if now.Sub(sub.lastSent) < s.intervalLocked(sub) {
+			continue
+		}
+		s.sendLocked(sub, now)
+	}
+}
+
+// intervalLocked returns the minimum time between frames for a subscriber.
+func (s *Stream) intervalLocked(sub *subscriber) time.Duration {
+	if sub.variant.Interval > 0 {
+		return sub.variant.Interval
+	}
+	return s.minInterval
+}
+
+// sendLocked queues the latest frame for a subscriber, replacing one it has not written yet.
+func (s *Stream) sendLocked(sub *subscriber, now time.Time) {
+	if s.cur == nil {
 		return
 	}
-	frame := append([]byte(nil), jpg...)
-	s.last = frame
-	s.lastPush = now
-	for ch := range s.subs {
-		select {
-		case <-ch:
-		default:
-		}
-		select {
-		case ch <- frame:
-		default:
-		}
+	select {
+	case <-sub.ch:
+		sub.dropped++
+	default:
 	}
-	s.mu.Unlock()
+	sub.ch <- queuedFrame{f: s.cur, variant: sub.variant, at: now}
+	sub.lastSent = now
 }
 
-// Handler serves the MJPEG multipart stream to the HTTP client.
-func (s *Stream) Handler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "multipart/x-mixed-replace; boundary="+boundary)
-	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
-	w.Header().Set("Connection", "keep-alive")
-	w.Header().Set("Pragma", "no-cache")
+// keepaliveLocked returns the latest frame when a subscriber has been idle for both its
+// interval and the keepalive interval, and counts it as sent; otherwise nil.
+func (s *Stream) keepaliveLocked(sub *subscriber, now time.Time) *frame {
+	if s.cur == nil || now.Sub(sub.lastSent) < max(s.intervalLocked(sub), keepaliveInterval) {
+		return nil
+	}
+	sub.lastSent = now
+	return s.cur
+}
 
+// HasSubscribers reports whether any HTTP client is currently streaming.
+func (s *Stream) HasSubscribers() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.subs) > 0
+}
+
+// Handler serves the MJPEG multipart stream to the HTTP client. The optional query
+// parameters interval (ms), quality (1-100) and scale (0.05-1) select the variant.
+func (s *Stream) Handler(w http.ResponseWriter, r *http.Request) {
+	v, err := ParseVariant(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	fl, ok := w.(http.Flusher)
 	if !ok {
 		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
 		return
 	}
 
-	ch := s.subscribe()
-	defer s.unsubscribe(ch)
+	w.Header().Set("Content-Type", "multipart/x-mixed-replace; boundary="+boundary)
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("Pragma", "no-cache")
+
+	sub := s.subscribe(v, r.RemoteAddr)
+	defer s.unsubscribe(sub)
 
-	keep := time.NewTicker(1 * time.Second)
+	keep := time.NewTicker(keepaliveInterval)
 	defer keep.Stop()
 
 	for {
 		select {
 		case <-r.Context().Done():
 			return
-		case jpg := <-ch:
-			if err := writePart(w, jpg); err != nil {
+		case frame := <-sub.ch:
+			if err := writePart(w, frame.jpeg()); err != nil {
 				return
 			}
 			fl.Flush()
+			s.noteWritten(sub, frame.at)
 		case <-keep.C:
-			s.mu.RLock()
-			j := append([]byte(nil), s.last...)
-			s.mu.RUnlock()
-			if len(j) > 0 {
-				if err := writePart(w, j); err != nil {
-					return
-				}
-				fl.Flush()
+			now := time.Now()
+			s.mu.Lock()
+			f := s.keepaliveLocked(sub, now)
+			s.mu.Unlock()
+			if f == nil {
+				continue
+			}
+			if err := writePart(w, f.jpeg(sub.variant)); err != nil {
+				return
 			}
+			fl.Flush()
+			s.noteWritten(sub, now)
 		}
 	}
 }
 
+// noteWritten records a frame written to a subscriber and how long it waited since queueing.
+func (s *Stream) noteWritten(sub *subscriber, queued time.Time) {
+	lag := time.Since(queued)
+	s.mu.Lock()
+	sub.sent++
+	sub.lag = lag
+	sub.maxLag = max(sub.maxLag, lag)
+	s.mu.Unlock()
+}
+
 // EncodeRGBToJPEG encodes RGB24 bytes into a JPEG buffer.
 func EncodeRGBToJPEG(rgb []byte, w, h int, quality int) []byte {
-	if quality <= 0 || quality > 100 {
-		quality = 60
-	}
+	return encodeImage(RGBToImage(rgb, w, h), quality)
+}
+
+// RGBToImage expands packed RGB24 bytes into an opaque RGBA image.
+func RGBToImage(rgb []byte, w, h int) *image.RGBA {
 	img := image.NewRGBA(image.Rect(0, 0, w, h))
 	si := 0
 	di := 0
@@ -127,28 +199,40 @@ func EncodeRGBToJPEG(rgb []byte, w, h int, quality int) []byte {
 			di += 4
 		}
 	}
+	return img
+}
+
+// encodeImage encodes an image as JPEG, falling back to quality 60 when out of range.
+func encodeImage(img image.Image, quality int) []byte {
+	if quality <= 0 || quality > 100 {
+		quality = 60
+	}
 	var buf bytes.Buffer
 	_ = jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality})
 	return buf.Bytes()
 }
 
-// subscribe registers a new client for MJPEG frames.
-func (s *Stream) subscribe() chan []byte {
-	ch := make(chan []byte, 1)
+// subscribe registers a new client and queues the latest frame for it.
+func (s *Stream) subscribe(v Variant, remote string) *subscriber {
 	s.mu.Lock()
-	s.subs[ch] = struct{}{}
-	if len(s.last) > 0 {
-		ch <- append([]byte(nil), s.last...)
+	defer s.mu.Unlock()
+	s.nextID++
+	sub := &subscriber{
+		id:      s.nextID,
+		remote:  remote,
+		variant: v,
+		ch:      make(chan queuedFrame, 1),
+		since:   time.Now(),
 	}
-	s.mu.Unlock()
-	return ch
+	s.subs[sub] = struct{}{}
+	s.sendLocked(sub, sub.since)
+	return sub
 }
 
 // unsubscribe removes a client subscription.
-func (s *Stream) unsubscribe(ch chan []byte) {
+func (s *Stream) unsubscribe(sub *subscriber) {
 	s.mu.Lock()
-	delete(s.subs, ch)
-	close(ch)
+	delete(s.subs, sub)
 	s.mu.Unlock()
 }
 
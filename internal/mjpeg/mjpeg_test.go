@@ -3,7 +3,10 @@ package mjpeg
 import (
 	"bytes"
 	"context"
+	"image"
+	"image/jpeg"
 	"net/http"
+	"net/url"
 	"sync"
 	"testing"
 	"time"
@@ -133,16 +136,16 @@ func TestStreamPublishThrottle(t *testing.T) {
 	t.Parallel()
 
 	s := NewStream(time.Hour)
-	ch := s.subscribe()
-	defer s.unsubscribe(ch)
+	sub := s.subscribe(Variant{}, "test")
+	defer s.unsubscribe(sub)
 
 	jpgA := EncodeRGBToJPEG([]byte{0, 0, 255}, 1, 1, 60)
 	jpgB := EncodeRGBToJPEG([]byte{255, 255, 0}, 1, 1, 60)
 
 	s.Publish(jpgA)
 	select {
-	case got := <-ch:
-		if !bytes.Equal(got, jpgA) {
+	case got := <-sub.ch:
+		if !bytes.Equal(got.jpeg(), jpgA) {
 			t.Fatalf("expected first publish to broadcast jpgA")
 		}
 	case <-time.After(200 * time.Millisecond):
@@ -151,14 +154,14 @@ func TestStreamPublishThrottle(t *testing.T) {
 
 	s.Publish(jpgB)
 	select {
-	case <-ch:
+	case <-sub.ch:
 		t.Fatal("expected throttled publish to not broadcast immediately")
 	case <-time.After(50 * time.Millisecond):
 	}
 
 	s.mu.RLock()
 	defer s.mu.RUnlock()
-	if !bytes.Equal(s.last, jpgB) {
+	if !bytes.Equal(s.cur.src.jpg, jpgB) {
 		t.Fatal("expected last frame to update even when throttled")
 	}
 }
@@ -186,15 +189,153 @@ func TestStreamPublishConcurrent(t *testing.T) {
 		go func() {
 			defer wg.Done()
 			for j := 0; j < 200; j++ {
-				ch := s.subscribe()
+				sub := s.subscribe(Variant{}, "test")
 				select {
-				case <-ch:
+				case <-sub.ch:
 				default:
 				}
-				s.unsubscribe(ch)
+				s.unsubscribe(sub)
 			}
 		}()
 	}
 
 	wg.Wait()
 }
+
+// TestStreamHasSubscribers verifies subscriber tracking follows subscribe/unsubscribe.
+func TestStreamHasSubscribers(t *testing.T) {
+	t.Parallel()
+
+	s := NewStream(0)
+	if s.HasSubscribers() {
+		t.Fatal("expected no subscribers on a new stream")
+	}
+	sub := s.subscribe(Variant{}, "test")
+	if !s.HasSubscribers() {
+		t.Fatal("expected a subscriber after subscribe")
+	}
+	s.unsubscribe(sub)
+	if s.HasSubscribers() {
+		t.Fatal("expected no subscribers after unsubscribe")
+	}
+}
+
+// TestStreamSharesVariantEncoding verifies matching subscribers get the same encoded bytes,
+// other variants get their own size, and an unread frame counts as a drop.
+func TestStreamSharesVariantEncoding(t *testing.T) {
+	t.Parallel()
+
+	s := NewStream(0)
+	full := s.subscribe(Variant{}, "a")
+	half := s.subscribe(Variant{Scale: 0.5, Quality: 50}, "b")
+	halfSlow := s.subscribe(Variant{Scale: 0.5, Quality: 50, Interval: time.Hour}, "c")
+	defer s.unsubscribe(full)
+	defer s.unsubscribe(half)
+	defer s.unsubscribe(halfSlow)
+
+	rgb := make([]byte, 8*4*3)
+	s.PublishRGB(rgb, 8, 4, 80)
+	qa, qb, qc := <-full.ch, <-half.ch, <-halfSlow.ch
+	a, b, c := qa.jpeg(), qb.jpeg(), qc.jpeg()
+	if &b[0] != &c[0] {
+		t.Fatal("expected subscribers with the same quality and scale to share one encoding")
+	}
+	if cfg := decodeConfig(t, a); cfg.Width != 8 || cfg.Height != 4 {
+		t.Fatalf("full variant is %dx%d, want 8x4", cfg.Width, cfg.Height)
+	}
+	if cfg := decodeConfig(t, b); cfg.Width != 4 || cfg.Height != 2 {
+		t.Fatalf("half variant is %dx%d, want 4x2", cfg.Width, cfg.Height)
+	}
+
+	s.PublishRGB(rgb, 8, 4, 80)
+	s.PublishRGB(rgb, 8, 4, 80)
+	stats := s.Stats()
+	if len(stats) != 3 || stats[0].Dropped != 1 || stats[2].Dropped != 0 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+	select {
+	case <-halfSlow.ch:
+		t.Fatal("expected the slow subscriber to wait for its interval")
+	default:
+	}
+}
+
+// TestStreamPublishDefersEncoding verifies publishing only queues the frame, leaving the
+// encode to the subscriber, and that each variant is encoded once per frame.
+func TestStreamPublishDefersEncoding(t *testing.T) {
+	t.Parallel()
+
+	s := NewStream(0)
+	sub := s.subscribe(Variant{Scale: 0.5}, "a")
+	defer s.unsubscribe(sub)
+
+	s.PublishRGB(make([]byte, 8*4*3), 8, 4, 80)
+	got := <-sub.ch
+	got.f.mu.Lock()
+	pending := len(got.f.encoded)
+	got.f.mu.Unlock()
+	if pending != 0 {
+		t.Fatalf("expected no encoding at publish, got %d variants", pending)
+	}
+	first := got.jpeg()
+	if again := got.f.jpeg(Variant{Scale: 0.5, Interval: time.Second}); &again[0] != &first[0] {
+		t.Fatal("expected the variant to be encoded once and reused")
+	}
+}
+
+// TestStreamKeepaliveRespectsInterval verifies the keepalive waits for the subscriber's own
+// interval and counts its resend as sent.
+func TestStreamKeepaliveRespectsInterval(t *testing.T) {
+	t.Parallel()
+
+	s := NewStream(0)
+	s.Publish(EncodeRGBToJPEG([]byte{1, 2, 3}, 1, 1, 60))
+	slow := s.subscribe(Variant{Interval: 5 * time.Second}, "slow")
+	fast := s.subscribe(Variant{}, "fast")
+	defer s.unsubscribe(slow)
+	defer s.unsubscribe(fast)
+
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	slow.lastSent = now.Add(-2 * time.Second)
+	if s.keepaliveLocked(slow, now) != nil {
+		t.Fatal("expected no keepalive before the subscriber interval")
+	}
+	fast.lastSent = now.Add(-keepaliveInterval / 2)
+	if s.keepaliveLocked(fast, now) != nil {
+		t.Fatal("expected no keepalive while frames are flowing")
+	}
+	slow.lastSent = now.Add(-6 * time.Second)
+	if s.keepaliveLocked(slow, now) == nil || !slow.lastSent.Equal(now) {
+		t.Fatal("expected a keepalive after the interval to update lastSent")
+	}
+}
+
+// TestParseVariant verifies query parameters are parsed and out-of-range values rejected.
+func TestParseVariant(t *testing.T) {
+	t.Parallel()
+
+	v, err := ParseVariant(url.Values{"interval": {"250"}, "quality": {"40"}, "scale": {"0.5"}})
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	if v != (Variant{Interval: 250 * time.Millisecond, Quality: 40, Scale: 0.5}) {
+		t.Fatalf("unexpected variant: %+v", v)
+	}
+	for _, q := range []url.Values{{"quality": {"0"}}, {"scale": {"2"}}, {"interval": {"-1"}}, {"scale": {"x"}}} {
+		if _, err := ParseVariant(q); err == nil {
+			t.Fatalf("expected error for %v", q)
+		}
+	}
+}
+
+// decodeConfig reads the dimensions of a JPEG frame.
+func decodeConfig(t *testing.T, jpg []byte) image.Config {
+	t.Helper()
+	cfg, err := jpeg.DecodeConfig(bytes.NewReader(jpg))
+	if err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	return cfg
+}
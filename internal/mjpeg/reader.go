@@ -0,0 +1,136 @@
+// Package mjpeg provides a minimal MJPEG stream for browser previews.
+package mjpeg
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+)
+
+// errShortSegment reports a marker segment whose length field is below its own size.
+var errShortSegment = errors.New("mjpeg: invalid segment length")
+
+// Reader splits a concatenated JPEG stream, such as ffmpeg's -f mjpeg output, into frames.
+// Frames are delimited by their SOI/EOI markers; marker segments are skipped by length and
+// the entropy-coded scan is walked past stuffed bytes, so marker-like bytes inside tables
+// or image data never end a frame early.
+type Reader struct {
+	r   *bufio.Reader
+	buf []byte
+}
+
+// NewReader returns a frame reader over r.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: bufio.NewReaderSize(r, 64<<10)}
+}
+
+// Next returns the next complete JPEG frame. The slice is reused by the following call.
+func (r *Reader) Next() ([]byte, error) {
+	if err := r.skipToSOI(); err != nil {
+		return nil, err
+	}
+	r.buf = append(r.buf[:0], 0xFF, 0xD8)
+	marker, err := r.readMarker()
+	for {
+		if err != nil {
+			return nil, err
+		}
+		r.buf = append(r.buf, 0xFF, marker)
+		switch {
+		case marker == 0xD9: // EOI
+			return r.buf, nil
+		case marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7): // TEM, RSTn: no payload
+			marker, err = r.readMarker()
+			continue
+		}
+		if err = r.copySegment(); err != nil {
+			return nil, err
+		}
+		if marker == 0xDA { // SOS: entropy-coded data follows the header
+			marker, err = r.copyScan()
+		} else {
+			marker, err = r.readMarker()
+		}
+	}
+}
+
+// skipToSOI discards bytes up to and including the next start-of-image marker.
+func (r *Reader) skipToSOI() error {
+	var prev byte
+	for {
+		b, err := r.r.ReadByte()
+		if err != nil {
+			return err
+		}
+		if prev == 0xFF && b == 0xD8 {
+			return nil
+		}
+		prev = b
+	}
+}
+
+// readMarker reads the next marker code, skipping 0xFF fill bytes.
+func (r *Reader) readMarker() (byte, error) {
+	b, err := r.r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	if b != 0xFF {
+		return 0, fmt.Errorf("mjpeg: expected marker, got 0x%02x", b)
+	}
+	for b == 0xFF {
+		if b, err = r.r.ReadByte(); err != nil {
+			return 0, err
+		}
+	}
+	return b, nil
+}
+
+// copySegment appends a length-prefixed marker segment to the frame.
+func (r *Reader) copySegment() error {
+	var hdr [2]byte
+	if _, err := io.ReadFull(r.r, hdr[:]); err != nil {
+		return err
+	}
+	n := int(binary.BigEndian.Uint16(hdr[:]))
+	if n < 2 {
+		return errShortSegment
+	}
+	r.buf = append(r.buf, hdr[:]...)
+	start := len(r.buf)
+	r.buf = slices.Grow(r.buf, n-2)[:start+n-2]
+	_, err := io.ReadFull(r.r, r.buf[start:])
+	return err
+}
+
+// copyScan appends entropy-coded data and returns the marker that ends it. Stuffed 0xFF00
+// bytes and restart markers belong to the scan.
+func (r *Reader) copyScan() (byte, error) {
+	for {
+		chunk, err := r.r.ReadSlice(0xFF)
+		r.buf = append(r.buf, chunk...)
+		if errors.Is(err, bufio.ErrBufferFull) {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		next, err := r.r.ReadByte()
+		for err == nil && next == 0xFF {
+			next, err = r.r.ReadByte()
+		}
+		if err != nil {
+			return 0, err
+		}
+		if next == 0x00 || (next >= 0xD0 && next <= 0xD7) {
+			r.buf = append(r.buf, next)
+			continue
+		}
+		// Drop the 0xFF already copied; Next appends the full marker.
+		r.buf = r.buf[:len(r.buf)-1]
+		return next, nil
+	}
+}
@@ -0,0 +1,122 @@
+package mjpeg
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+// testFrame encodes a small gradient frame whose content depends on seed.
+func testFrame(w, h int, seed byte) []byte {
+	rgb := make([]byte, w*h*3)
+	for i := range rgb {
+		rgb[i] = byte(i) ^ seed
+	}
+	return EncodeRGBToJPEG(rgb, w, h, 80)
+}
+
+// TestReaderSplitsConcatenatedFrames verifies frames are returned whole and in order, skipping leading junk.
+func TestReaderSplitsConcatenatedFrames(t *testing.T) {
+	t.Parallel()
+
+	frames := [][]byte{testFrame(32, 16, 1), testFrame(16, 16, 2), testFrame(8, 24, 3)}
+	stream := []byte{0x00, 0xFF, 0x12}
+	for _, f := range frames {
+		stream = append(stream, f...)
+	}
+
+	r := NewReader(bytes.NewReader(stream))
+	for i, want := range frames {
+		got, err := r.Next()
+		if err != nil {
+			t.Fatalf("frame %d: %v", i, err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Fatalf("frame %d: got %d bytes, want %d", i, len(got), len(want))
+		}
+	}
+	if _, err := r.Next(); !errors.Is(err, io.EOF) {
+		t.Fatalf("expected EOF after last frame, got %v", err)
+	}
+}
+
+// TestReaderIgnoresMarkerBytesInsideFrame verifies EOI-like bytes in segments and stuffed scan bytes do not end a frame.
+func TestReaderIgnoresMarkerBytesInsideFrame(t *testing.T) {
+	t.Parallel()
+
+	frame := []byte{
+		0xFF, 0xD8,
+		0xFF, 0xFE, 0x00, 0x06, 0xFF, 0xD9, 0xFF, 0xD8, // COM segment holding SOI/EOI bytes
+		0xFF, 0xDA, 0x00, 0x02, // SOS with an empty header
+		0x12, 0xFF, 0x00, 0x34, 0xFF, 0xD0, 0x56, // stuffed byte and RST0
+		0xFF, 0xD9,
+	}
+	r := NewReader(bytes.NewReader(append(append([]byte(nil), frame...), frame...)))
+	for i := 0; i < 2; i++ {
+		got, err := r.Next()
+		if err != nil {
+			t.Fatalf("frame %d: %v", i, err)
+		}
+		if !bytes.Equal(got, frame) {
+			t.Fatalf("frame %d: got %x, want %x", i, got, frame)
+		}
+	}
+}
+
+// TestReaderTruncatedFrame verifies a stream cut mid-frame reports an error instead of a partial frame.
+func TestReaderTruncatedFrame(t *testing.T) {
+	t.Parallel()
+
+	frame := testFrame(16, 16, 4)
+	r := NewReader(bytes.NewReader(frame[:len(frame)/2]))
+	if got, err := r.Next(); err == nil {
+		t.Fatalf("expected error for truncated frame, got %d bytes", len(got))
+	}
+}
+
+// BenchmarkEncodeRGBToJPEG measures the Go-side cost per frame of the rgb24 preview path at 720p.
+func BenchmarkEncodeRGBToJPEG(b *testing.B) {
+	const w, h = 1280, 720
+	rgb := make([]byte, w*h*3)
+	for i := range rgb {
+		rgb[i] = byte(i * 7)
+	}
+	b.SetBytes(int64(len(rgb)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		EncodeRGBToJPEG(rgb, w, h, 60)
+	}
+}
+
+// BenchmarkReaderNext measures the Go-side cost per frame of the ffmpeg mjpeg preview path at 720p.
+func BenchmarkReaderNext(b *testing.B) {
+	const w, h = 1280, 720
+	rgb := make([]byte, w*h*3)
+	for i := range rgb {
+		rgb[i] = byte(i * 7)
+	}
+	frame := EncodeRGBToJPEG(rgb, w, h, 60)
+	src := &repeatReader{frame: frame}
+	r := NewReader(src)
+	b.SetBytes(int64(len(frame)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := r.Next(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// repeatReader endlessly repeats one JPEG frame, like an ffmpeg mjpeg pipe.
+type repeatReader struct {
+	frame []byte
+	off   int
+}
+
+// Read copies the next part of the repeated frame into p.
+func (r *repeatReader) Read(p []byte) (int, error) {
+	n := copy(p, r.frame[r.off:])
+	r.off = (r.off + n) % len(r.frame)
+	return n, nil
+}
@@ -0,0 +1,82 @@
+// Package mjpeg provides a minimal MJPEG stream for browser previews.
+package mjpeg
+
+import (
+	"sort"
+	"time"
+)
+
+// subscriber is one connected MJPEG client and its delivery counters.
+type subscriber struct {
+	id       int
+	remote   string
+	variant  Variant
+	ch       chan queuedFrame
+	since    time.Time
+	lastSent time.Time
+	sent     uint64
+	dropped  uint64
+	lag      time.Duration
+	maxLag   time.Duration
+}
+
+// queuedFrame is a frame waiting to be written in a subscriber's variant, stamped with
+// when it was queued.
+type queuedFrame struct {
+	f       *frame
+	variant Variant
+	at      time.Time
+}
+
+// jpeg returns the queued frame encoded for the subscriber's variant.
+func (q queuedFrame) jpeg() []byte {
+	return q.f.jpeg(q.variant)
+}
+
+// SubscriberStats reports how well one MJPEG client keeps up. Dropped counts frames that
+// were replaced by a newer one before the client could write them; lag is the time from
+// queueing a frame to finishing its write.
+type SubscriberStats struct {
+	ID          int     `json:"id"`
+	Remote      string  `json:"remote"`
+	IntervalMs  int64   `json:"intervalMs"`
+	Quality     int     `json:"quality"`
+	Scale       float64 `json:"scale"`
+	ConnectedMs int64   `json:"connectedMs"`
+	Sent        uint64  `json:"sent"`
+	Dropped     uint64  `json:"dropped"`
+	LagMs       float64 `json:"lagMs"`
+	MaxLagMs    float64 `json:"maxLagMs"`
+}
+
+// Stats returns per-subscriber delivery counters ordered by connection (nil for a nil stream).
+func (s *Stream) Stats() []SubscriberStats {
+	if s == nil {
+		return nil
+	}
+	now := time.Now()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]SubscriberStats, 0, len(s.subs))
+	for sub := range s.subs {
+		out = append(out, SubscriberStats{
+			ID:          sub.id,
+			Remote:      sub.remote,
+			IntervalMs:  s.intervalLocked(sub).Milliseconds(),
+			Quality:     sub.variant.Quality,
+			Scale:       sub.variant.encoding().Scale,
+			ConnectedMs: now.Sub(sub.since).Milliseconds(),
+			Sent:        sub.sent,
+			Dropped:     sub.dropped,
+			LagMs:       durationMs(sub.lag),
+			MaxLagMs:    durationMs(sub.maxLag),
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+// durationMs converts a duration to fractional milliseconds.
+func durationMs(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,188 @@
+// Package mjpeg provides a minimal MJPEG stream for browser previews.
+package mjpeg
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// maxVariantInterval bounds the interval a subscriber may request.
+	maxVariantInterval = 10 * time.Second
+	// minVariantScale is the smallest scale factor a subscriber may request.
+	minVariantScale = 0.05
+)
+
+// Variant is the stream a subscriber asked for. Zero values mean the stream defaults:
+// the configured interval and quality at full resolution.
+type Variant struct {
+	Interval time.Duration
+	Quality  int
+	Scale    float64
+}
+
+// ParseVariant reads the interval (ms), quality and scale query parameters.
+func ParseVariant(q url.Values) (Variant, error) {
+	var v Variant
+	if raw := q.Get("interval"); raw != "" {
+		ms, err := strconv.Atoi(raw)
+		if err != nil || ms < 0 || time.Duration(ms)*time.Millisecond > maxVariantInterval {
+			return Variant{}, fmt.Errorf("interval must be 0-%d ms", maxVariantInterval.Milliseconds())
+		}
+		v.Interval = time.Duration(ms) * time.Millisecond
+	}
+	if raw := q.Get("quality"); raw != "" {
+		quality, err := strconv.Atoi(raw)
+		if err != nil || quality < 1 || quality > 100 {
+			return Variant{}, fmt.Errorf("quality must be 1-100")
+		}
+		v.Quality = quality
+	}
+	if raw := q.Get("scale"); raw != "" {
+		scale, err := strconv.ParseFloat(raw, 64)
+		if err != nil || scale < minVariantScale || scale > 1 {
+			return Variant{}, fmt.Errorf("scale must be %g-1", minVariantScale)
+		}
+		v.Scale = scale
+	}
+	return v, nil
+}
+
+// encoding returns the part of the variant that affects the JPEG bytes, used as the
+// cache key shared by subscribers with different intervals.
+func (v Variant) encoding() Variant {
+	scale := v.Scale
+	if scale <= 0 || scale >= 1 {
+		scale = 1
+	}
+	return Variant{Quality: v.Quality, Scale: scale}
+}
+
+// frame is one published frame and the variants encoded from it so far. It is never
+// modified after publishing, so subscribers encode from it without holding Stream.mu.
+type frame struct {
+	src     *source
+	mu      sync.Mutex
+	encoded map[Variant]*encodedVariant
+}
+
+// encodedVariant is a frame encoded for one variant, filled by the first subscriber that
+// needs it while the others wait.
+type encodedVariant struct {
+	once sync.Once
+	jpg  []byte
+}
+
+// newFrame wraps a source as a published frame.
+func newFrame(src *source) *frame {
+	return &frame{src: src, encoded: make(map[Variant]*encodedVariant)}
+}
+
+// jpeg returns the frame encoded for a variant, encoding it on first use.
+func (f *frame) jpeg(v Variant) []byte {
+	key := v.encoding()
+	f.mu.Lock()
+	e, ok := f.encoded[key]
+	if !ok {
+		e = &encodedVariant{}
+		f.encoded[key] = e
+	}
+	f.mu.Unlock()
+	e.once.Do(func() { e.jpg = f.src.encode(key) })
+	return e.jpg
+}
+
+// source is a published frame: raw RGB24 pixels, or a JPEG encoded upstream.
+type source struct {
+	rgb     []byte
+	jpg     []byte
+	w       int
+	h       int
+	quality int
+	imgOnce sync.Once
+	img     *image.RGBA
+}
+
+// encode renders the frame for an encoding key. A JPEG source is passed through untouched
+// when no quality or scale was requested.
+func (s *source) encode(v Variant) []byte {
+	if s.jpg != nil && v.Quality == 0 && v.Scale == 1 {
+		return s.jpg
+	}
+	quality := v.Quality
+	if quality == 0 {
+		quality = s.quality
+	}
+	if s.jpg != nil && quality == 0 {
+		quality = jpeg.DefaultQuality
+	}
+	if s.rgb != nil && v.Scale == 1 {
+		return EncodeRGBToJPEG(s.rgb, s.w, s.h, quality)
+	}
+	img := s.image()
+	if img == nil {
+		return s.jpg
+	}
+	if v.Scale < 1 {
+		b := img.Bounds()
+		img = ScaleImage(img, max(1, int(float64(b.Dx())*v.Scale+0.5)), max(1, int(float64(b.Dy())*v.Scale+0.5)))
+	}
+	return encodeImage(img, quality)
+}
+
+// image returns the frame as RGBA, converting or decoding it once for all variants.
+func (s *source) image() *image.RGBA {
+	s.imgOnce.Do(func() {
+		if s.rgb != nil {
+			s.img = RGBToImage(s.rgb, s.w, s.h)
+			return
+		}
+		decoded, err := jpeg.Decode(bytes.NewReader(s.jpg))
+		if err != nil {
+			return
+		}
+		img := image.NewRGBA(decoded.Bounds())
+		draw.Draw(img, img.Rect, decoded, decoded.Bounds().Min, draw.Src)
+		s.img = img
+	})
+	return s.img
+}
+
+// ScaleImage downsamples an image to w x h by averaging the source pixels each target covers.
+func ScaleImage(src *image.RGBA, w, h int) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	b := src.Bounds()
+	sw, sh := b.Dx(), b.Dy()
+	for y := 0; y < h; y++ {
+		y0 := y * sh / h
+		y1 := max((y+1)*sh/h, y0+1)
+		for x := 0; x < w; x++ {
+			x0 := x * sw / w
+			x1 := max((x+1)*sw/w, x0+1)
+			var r, g, bl, n int
+			for sy := y0; sy < y1; sy++ {
+				i := src.PixOffset(b.Min.X+x0, b.Min.Y+sy)
+				for sx := x0; sx < x1; sx++ {
+					r += int(src.Pix[i])
+					g += int(src.Pix[i+1])
+					bl += int(src.Pix[i+2])
+					n++
+					i += 4
+				}
+			}
+			j := dst.PixOffset(x, y)
+			dst.Pix[j] = uint8(r / n)
+			dst.Pix[j+1] = uint8(g / n)
+			dst.Pix[j+2] = uint8(bl / n)
+			dst.Pix[j+3] = 255
+		}
+	}
+	return dst
+}
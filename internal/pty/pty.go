@@ -0,0 +1,24 @@
+// Package pty spawns commands attached to a pseudo-terminal.
+package pty
+
+import "errors"
+
+// DefaultCols is the terminal width used until the viewer reports its size.
+const DefaultCols = 100
+
+// DefaultRows is the terminal height used until the viewer reports its size.
+const DefaultRows = 30
+
+// ErrUnsupported is returned on platforms without pseudo-terminal support.
+var ErrUnsupported = errors.New("pty: unsupported platform")
+
+// clampSize keeps a terminal size within sane bounds.
+func clampSize(cols, rows int) (int, int) {
+	if cols <= 0 {
+		cols = DefaultCols
+	}
+	if rows <= 0 {
+		rows = DefaultRows
+	}
+	return min(cols, 1000), min(rows, 1000)
+}
@@ -0,0 +1,129 @@
+//go:build linux
+
+// Package pty spawns commands attached to a pseudo-terminal.
+package pty
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"sync"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// PTY is a running command attached to a pseudo-terminal.
+type PTY struct {
+	master    *os.File
+	cmd       *exec.Cmd
+	done      chan struct{}
+	waitErr   error
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// Start runs name with args inside a new pseudo-terminal of the given size.
+func Start(name string, args []string, cols, rows int) (*PTY, error) {
+	fd, err := unix.Open("/dev/ptmx", unix.O_RDWR|unix.O_NOCTTY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, fmt.Errorf("pty: open ptmx: %w", err)
+	}
+	if err := unix.IoctlSetPointerInt(fd, unix.TIOCSPTLCK, 0); err != nil {
+		_ = unix.Close(fd)
+		return nil, fmt.Errorf("pty: unlock: %w", err)
+	}
+	n, err := unix.IoctlGetUint32(fd, unix.TIOCGPTN)
+	if err != nil {
+		_ = unix.Close(fd)
+		return nil, fmt.Errorf("pty: ptsname: %w", err)
+	}
+	// A non-blocking master is registered with the runtime poller, so Close unblocks Read.
+	if err := unix.SetNonblock(fd, true); err != nil {
+		_ = unix.Close(fd)
+		return nil, err
+	}
+	master := os.NewFile(uintptr(fd), "/dev/ptmx")
+
+	slave, err := os.OpenFile(fmt.Sprintf("/dev/pts/%d", n), os.O_RDWR|unix.O_NOCTTY, 0)
+	if err != nil {
+		_ = master.Close()
+		return nil, fmt.Errorf("pty: open pts: %w", err)
+	}
+	defer slave.Close()
+
+	p := &PTY{master: master}
+	if err := p.Resize(cols, rows); err != nil {
+		_ = master.Close()
+		return nil, err
+	}
+
+	cmd := exec.Command(name, args...)
+	cmd.Env = append(os.Environ(), "TERM=xterm-256color")
+	cmd.Stdin = slave
+	cmd.Stdout = slave
+	cmd.Stderr = slave
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true, Setctty: true}
+	if err := cmd.Start(); err != nil {
+		_ = master.Close()
+		return nil, err
+	}
+	p.cmd = cmd
+	p.done = make(chan struct{})
+	// Reap the command as soon as it exits, whether it was killed or ended on its own,
+	// so no caller has to remember to Wait.
+	go func() {
+		p.waitErr = cmd.Wait()
+		close(p.done)
+	}()
+	return p, nil
+}
+
+// Read reads terminal output. It returns io.EOF-like errors once the command exits.
+func (p *PTY) Read(b []byte) (int, error) {
+	n, err := p.master.Read(b)
+	if errors.Is(err, syscall.EIO) {
+		// Linux reports EIO on the master once every slave descriptor is closed.
+		return n, os.ErrClosed
+	}
+	return n, err
+}
+
+// Write sends input to the command as if typed on the terminal.
+func (p *PTY) Write(b []byte) (int, error) {
+	return p.master.Write(b)
+}
+
+// Resize updates the terminal window size.
+func (p *PTY) Resize(cols, rows int) error {
+	cols, rows = clampSize(cols, rows)
+	conn, err := p.master.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var ioctlErr error
+	err = conn.Control(func(fd uintptr) {
+		ioctlErr = unix.IoctlSetWinsize(int(fd), unix.TIOCSWINSZ, &unix.Winsize{Col: uint16(cols), Row: uint16(rows)})
+	})
+	if err != nil {
+		return err
+	}
+	return ioctlErr
+}
+
+// Wait blocks until the command exits and returns its exit error.
+func (p *PTY) Wait() error {
+	<-p.done
+	return p.waitErr
+}
+
+// Close kills the command and releases the terminal. The command is reaped in the
+// background; Wait returns once that happened.
+func (p *PTY) Close() error {
+	p.closeOnce.Do(func() {
+		_ = p.cmd.Process.Kill()
+		p.closeErr = p.master.Close()
+	})
+	return p.closeErr
+}
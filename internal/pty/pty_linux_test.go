@@ -0,0 +1,80 @@
+//go:build linux
+
+package pty
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// TestStart_EchoesInputThroughTerminal verifies input written to the PTY reaches the command and output flows back.
+func TestStart_EchoesInputThroughTerminal(t *testing.T) {
+	p, err := Start("sh", []string{"-c", `stty -echo; printf ready; read line; printf "got:%s" "$line"`}, 80, 24)
+	if err != nil {
+		t.Skipf("pty unavailable: %v", err)
+	}
+	defer p.Close()
+
+	out := make(chan string, 1)
+	go func() {
+		var buf bytes.Buffer
+		sent := false
+		chunk := make([]byte, 256)
+		for {
+			n, err := p.Read(chunk)
+			buf.Write(chunk[:n])
+			if err != nil {
+				out <- buf.String()
+				return
+			}
+			if !sent && strings.Contains(buf.String(), "ready") {
+				sent = true
+				if _, err := p.Write([]byte("hello\r")); err != nil {
+					out <- buf.String()
+					return
+				}
+			}
+		}
+	}()
+
+	select {
+	case got := <-out:
+		if !strings.Contains(got, "got:hello") {
+			t.Fatalf("unexpected output: %q", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for command output")
+	}
+}
+
+// TestClose_ReapsCommand verifies a killed command does not linger as a zombie.
+func TestClose_ReapsCommand(t *testing.T) {
+	p, err := Start("sleep", []string{"30"}, 80, 24)
+	if err != nil {
+		t.Skipf("pty unavailable: %v", err)
+	}
+	pid := p.cmd.Process.Pid
+	if err := p.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	_ = p.Close()
+
+	waited := make(chan struct{})
+	go func() {
+		_ = p.Wait()
+		close(waited)
+	}()
+	select {
+	case <-waited:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("command was not reaped")
+	}
+	if err := unix.Kill(pid, 0); !errors.Is(err, unix.ESRCH) {
+		t.Fatalf("process %d still exists: %v", pid, err)
+	}
+}
@@ -0,0 +1,27 @@
+//go:build !linux && !windows
+
+// Package pty spawns commands attached to a pseudo-terminal.
+package pty
+
+// PTY is a running command attached to a pseudo-terminal.
+type PTY struct{}
+
+// Start reports that pseudo-terminals are not supported on this platform.
+func Start(string, []string, int, int) (*PTY, error) {
+	return nil, ErrUnsupported
+}
+
+// Read is not supported on this platform.
+func (p *PTY) Read([]byte) (int, error) { return 0, ErrUnsupported }
+
+// Write is not supported on this platform.
+func (p *PTY) Write([]byte) (int, error) { return 0, ErrUnsupported }
+
+// Resize is not supported on this platform.
+func (p *PTY) Resize(int, int) error { return ErrUnsupported }
+
+// Wait is not supported on this platform.
+func (p *PTY) Wait() error { return ErrUnsupported }
+
+// Close is a no-op on this platform.
+func (p *PTY) Close() error { return nil }
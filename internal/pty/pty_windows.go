@@ -0,0 +1,182 @@
+//go:build windows
+
+// Package pty spawns commands attached to a pseudo-terminal.
+package pty
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"sync"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var procUpdateProcThreadAttribute = windows.NewLazySystemDLL("kernel32.dll").NewProc("UpdateProcThreadAttribute")
+
+// PTY is a running command attached to a Windows pseudo console (ConPTY).
+type PTY struct {
+	console     windows.Handle
+	consoleOnce sync.Once
+	in          *os.File
+	out         *os.File
+	closeOnce   sync.Once
+
+	// procMu guards process, which the reaper closes and zeroes once the command exited.
+	procMu  sync.Mutex
+	process windows.Handle
+	done    chan struct{}
+	waitErr error
+}
+
+// Start runs name with args inside a new pseudo console of the given size.
+func Start(name string, args []string, cols, rows int) (*PTY, error) {
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return nil, err
+	}
+	cols, rows = clampSize(cols, rows)
+
+	var inRead, inWrite, outRead, outWrite windows.Handle
+	if err := windows.CreatePipe(&inRead, &inWrite, nil, 0); err != nil {
+		return nil, fmt.Errorf("pty: input pipe: %w", err)
+	}
+	if err := windows.CreatePipe(&outRead, &outWrite, nil, 0); err != nil {
+		closeHandles(inRead, inWrite)
+		return nil, fmt.Errorf("pty: output pipe: %w", err)
+	}
+	var console windows.Handle
+	size := windows.Coord{X: int16(cols), Y: int16(rows)}
+	err = windows.CreatePseudoConsole(size, inRead, outWrite, 0, &console)
+	// The console duplicates its ends of the pipes; ours are no longer needed.
+	closeHandles(inRead, outWrite)
+	if err != nil {
+		closeHandles(inWrite, outRead)
+		return nil, fmt.Errorf("pty: create pseudo console: %w", err)
+	}
+
+	p := &PTY{
+		console: console,
+		in:      os.NewFile(uintptr(inWrite), "conpty-in"),
+		out:     os.NewFile(uintptr(outRead), "conpty-out"),
+	}
+	process, err := startProcess(console, windows.ComposeCommandLine(append([]string{path}, args...)))
+	if err != nil {
+		_ = p.Close()
+		return nil, err
+	}
+	p.process = process
+	p.done = make(chan struct{})
+	// ConPTY keeps the output pipe open after the command exits on its own, so wait on the
+	// process and close the console: that ends the pipe and lets Read report the exit.
+	go func() {
+		p.waitErr = waitProcess(process)
+		p.closeConsole()
+		p.procMu.Lock()
+		_ = windows.CloseHandle(p.process)
+		p.process = 0
+		p.procMu.Unlock()
+		close(p.done)
+	}()
+	return p, nil
+}
+
+// waitProcess blocks until a process exits and returns an error for a non-zero exit code.
+func waitProcess(process windows.Handle) error {
+	if _, err := windows.WaitForSingleObject(process, windows.INFINITE); err != nil {
+		return err
+	}
+	var code uint32
+	if err := windows.GetExitCodeProcess(process, &code); err != nil {
+		return err
+	}
+	if code != 0 {
+		return fmt.Errorf("exit status %d", code)
+	}
+	return nil
+}
+
+// startProcess launches a command line attached to the pseudo console.
+func startProcess(console windows.Handle, cmdline string) (windows.Handle, error) {
+	attrs, err := windows.NewProcThreadAttributeList(1)
+	if err != nil {
+		return 0, err
+	}
+	defer attrs.Delete()
+	// PROC_THREAD_ATTRIBUTE_PSEUDOCONSOLE takes the console handle itself as the value.
+	r, _, callErr := procUpdateProcThreadAttribute.Call(
+		uintptr(unsafe.Pointer(attrs.List())), 0, windows.PROC_THREAD_ATTRIBUTE_PSEUDOCONSOLE,
+		uintptr(console), unsafe.Sizeof(console), 0, 0)
+	if r == 0 {
+		return 0, fmt.Errorf("pty: attach pseudo console: %w", callErr)
+	}
+
+	si := windows.StartupInfoEx{ProcThreadAttributeList: attrs.List()}
+	si.Cb = uint32(unsafe.Sizeof(si))
+	cmd, err := windows.UTF16PtrFromString(cmdline)
+	if err != nil {
+		return 0, err
+	}
+	var pi windows.ProcessInformation
+	flags := uint32(windows.EXTENDED_STARTUPINFO_PRESENT | windows.CREATE_UNICODE_ENVIRONMENT)
+	if err := windows.CreateProcess(nil, cmd, nil, nil, false, flags, nil, nil, &si.StartupInfo, &pi); err != nil {
+		return 0, fmt.Errorf("pty: create process: %w", err)
+	}
+	_ = windows.CloseHandle(pi.Thread)
+	return pi.Process, nil
+}
+
+// Read reads terminal output (VT sequences emitted by the pseudo console).
+func (p *PTY) Read(b []byte) (int, error) {
+	return p.out.Read(b)
+}
+
+// Write sends input to the command as if typed on the terminal.
+func (p *PTY) Write(b []byte) (int, error) {
+	return p.in.Write(b)
+}
+
+// Resize updates the pseudo console size.
+func (p *PTY) Resize(cols, rows int) error {
+	cols, rows = clampSize(cols, rows)
+	return windows.ResizePseudoConsole(p.console, windows.Coord{X: int16(cols), Y: int16(rows)})
+}
+
+// Wait blocks until the command exits and returns its exit error.
+func (p *PTY) Wait() error {
+	if p.done == nil {
+		return nil
+	}
+	<-p.done
+	return p.waitErr
+}
+
+// Close kills the command and releases the pseudo console. The process handle is left to
+// the reaper, which closes it once the command exited; Wait returns after that.
+func (p *PTY) Close() error {
+	p.closeOnce.Do(func() {
+		p.procMu.Lock()
+		if p.process != 0 {
+			_ = windows.TerminateProcess(p.process, 1)
+		}
+		p.procMu.Unlock()
+		// Closing the console flushes and ends the output pipe so pending reads return.
+		p.closeConsole()
+		_ = p.in.Close()
+		_ = p.out.Close()
+	})
+	return nil
+}
+
+// closeConsole releases the pseudo console; the reaper and Close may both call it.
+func (p *PTY) closeConsole() {
+	p.consoleOnce.Do(func() { windows.ClosePseudoConsole(p.console) })
+}
+
+// closeHandles closes raw handles, ignoring errors.
+func closeHandles(handles ...windows.Handle) {
+	for _, h := range handles {
+		_ = windows.CloseHandle(h)
+	}
+}
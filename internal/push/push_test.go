@@ -0,0 +1,248 @@
+package push
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// browser is a fake user agent holding the receiving side of a subscription.
+type browser struct {
+	priv *ecdh.PrivateKey
+	auth []byte
+}
+
+// newBrowser generates subscription keys like a browser would.
+func newBrowser(t *testing.T) browser {
+	t.Helper()
+	priv, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	auth := make([]byte, 16)
+	_, _ = rand.Read(auth)
+	return browser{priv: priv, auth: auth}
+}
+
+// subscription returns the PushSubscription JSON the browser would post for endpoint.
+func (b browser) subscription(endpoint string) Subscription {
+	var sub Subscription
+	sub.Endpoint = endpoint
+	sub.Keys.P256dh = b64.EncodeToString(b.priv.PublicKey().Bytes())
+	sub.Keys.Auth = b64.EncodeToString(b.auth)
+	return sub
+}
+
+// decrypt opens an aes128gcm body the way the browser's push stack does.
+func (b browser) decrypt(t *testing.T, body []byte) []byte {
+	t.Helper()
+	if len(body) < 21 {
+		t.Fatalf("body too short: %d", len(body))
+	}
+	salt, rs, idLen := body[:16], binary.BigEndian.Uint32(body[16:20]), int(body[20])
+	if rs != recordSize || idLen != 65 {
+		t.Fatalf("unexpected header rs=%d idlen=%d", rs, idLen)
+	}
+	asRaw := body[21 : 21+idLen]
+	asPub, err := ecdh.P256().NewPublicKey(asRaw)
+	if err != nil {
+		t.Fatalf("sender key: %v", err)
+	}
+	shared, err := b.priv.ECDH(asPub)
+	if err != nil {
+		t.Fatalf("ecdh: %v", err)
+	}
+	cek, nonce, err := deriveKeys(shared, b.auth, salt, b.priv.PublicKey().Bytes(), asRaw)
+	if err != nil {
+		t.Fatalf("derive: %v", err)
+	}
+	block, _ := aes.NewCipher(cek)
+	gcm, _ := cipher.NewGCM(block)
+	plain, err := gcm.Open(nil, nonce, body[21+idLen:], nil)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if len(plain) == 0 || plain[len(plain)-1] != 0x02 {
+		t.Fatalf("missing last-record delimiter")
+	}
+	return plain[:len(plain)-1]
+}
+
+// verifyVAPID checks the Authorization header signature against its own key and returns the claims.
+func verifyVAPID(t *testing.T, header string) map[string]any {
+	t.Helper()
+	token, key, ok := strings.Cut(strings.TrimPrefix(header, "vapid t="), ", k=")
+	if !ok {
+		t.Fatalf("malformed authorization %q", header)
+	}
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		t.Fatalf("malformed token %q", token)
+	}
+	rawKey, _ := b64.DecodeString(key)
+	pub, err := ecdsa.ParseUncompressedPublicKey(elliptic.P256(), rawKey)
+	if err != nil {
+		t.Fatalf("public key: %v", err)
+	}
+	sig, _ := b64.DecodeString(parts[2])
+	if len(sig) != 64 {
+		t.Fatalf("signature is %d bytes, want 64", len(sig))
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	r, s := new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])
+	if !ecdsa.Verify(pub, digest[:], r, s) {
+		t.Fatalf("signature does not verify")
+	}
+	claims := map[string]any{}
+	raw, _ := b64.DecodeString(parts[1])
+	if err := json.Unmarshal(raw, &claims); err != nil {
+		t.Fatalf("claims: %v", err)
+	}
+	return claims
+}
+
+// TestSender_DeliversToStandIn verifies a notification reaches a local push service signed
+// with the stored VAPID key and decryptable by the subscribed browser.
+func TestSender_DeliversToStandIn(t *testing.T) {
+	var (
+		mu   sync.Mutex
+		got  []byte
+		auth string
+		hdr  http.Header
+	)
+	service := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		got, auth, hdr = body, r.Header.Get("Authorization"), r.Header.Clone()
+		mu.Unlock()
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer service.Close()
+
+	dir := t.TempDir()
+	sender, err := NewSender(dir, "mailto:ops@example.com")
+	if err != nil {
+		t.Fatalf("new sender: %v", err)
+	}
+	b := newBrowser(t)
+	if err := sender.Subscribe(b.subscription(service.URL + "/push/abc")); err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	sent, err := sender.Broadcast(context.Background(), Message{Title: "Pipeline failed", Body: "ffmpeg exited", Tag: "pipeline", Urgent: true})
+	if err != nil || sent != 1 {
+		t.Fatalf("broadcast sent=%d err=%v", sent, err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if hdr.Get("Content-Encoding") != "aes128gcm" || hdr.Get("Urgency") != "high" || hdr.Get("Topic") != "pipeline" || hdr.Get("TTL") == "" {
+		t.Fatalf("unexpected headers: %v", hdr)
+	}
+	claims := verifyVAPID(t, auth)
+	if claims["aud"] != service.URL || claims["sub"] != "mailto:ops@example.com" {
+		t.Fatalf("unexpected claims: %v", claims)
+	}
+	if !strings.HasSuffix(auth, "k="+sender.PublicKey()) {
+		t.Fatalf("authorization does not carry the public key")
+	}
+	var msg Message
+	if err := json.Unmarshal(b.decrypt(t, got), &msg); err != nil || msg.Title != "Pipeline failed" || msg.Body != "ffmpeg exited" {
+		t.Fatalf("decrypted %+v err=%v", msg, err)
+	}
+
+	reopened, err := NewSender(dir, "mailto:ops@example.com")
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	if reopened.PublicKey() != sender.PublicKey() || len(reopened.Subscriptions()) != 1 {
+		t.Fatalf("keys or subscriptions were not persisted")
+	}
+}
+
+// TestSender_DropsGoneSubscriptions verifies a 410 from the push service forgets the subscription.
+func TestSender_DropsGoneSubscriptions(t *testing.T) {
+	service := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusGone)
+	}))
+	defer service.Close()
+	sender, err := NewSender(t.TempDir(), "mailto:ops@example.com")
+	if err != nil {
+		t.Fatalf("new sender: %v", err)
+	}
+	if err := sender.Subscribe(newBrowser(t).subscription(service.URL + "/gone")); err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	if sent, err := sender.Broadcast(context.Background(), Message{Title: "x"}); sent != 0 || err != nil {
+		t.Fatalf("broadcast sent=%d err=%v", sent, err)
+	}
+	if subs := sender.Subscriptions(); len(subs) != 0 {
+		t.Fatalf("gone subscription kept: %+v", subs)
+	}
+}
+
+// TestValidateSubscription verifies remote endpoints must use https and keys must have the right size.
+func TestValidateSubscription(t *testing.T) {
+	b := newBrowser(t)
+	cases := []struct {
+		endpoint string
+		ok       bool
+	}{
+		{"https://push.example.com/send/1", true},
+		{"http://127.0.0.1:9000/push", true},
+		{"http://localhost/push", true},
+		{"http://push.example.com/send/1", false},
+		{"ftp://push.example.com", false},
+		{"not a url", false},
+	}
+	for _, c := range cases {
+		if err := validateSubscription(b.subscription(c.endpoint)); (err == nil) != c.ok {
+			t.Fatalf("%s: err=%v, want ok=%v", c.endpoint, err, c.ok)
+		}
+	}
+	sub := b.subscription("https://push.example.com/send/1")
+	sub.Keys.Auth = b64.EncodeToString([]byte("short"))
+	if validateSubscription(sub) == nil {
+		t.Fatalf("short auth secret accepted")
+	}
+}
+
+// TestSeal_RFC8291Vector verifies the encryption against the example in RFC 8291 Appendix A.
+func TestSeal_RFC8291Vector(t *testing.T) {
+	decode := func(s string) []byte {
+		b, err := b64.DecodeString(s)
+		if err != nil {
+			t.Fatalf("decode %q: %v", s, err)
+		}
+		return b
+	}
+	uaPub, err := ecdh.P256().NewPublicKey(decode("BCVxsr7N_eNgVRqvHtD0zTZsEc6-VV-JvLexhqUzORcxaOzi6-AYWXvTBHm4bjyPjs7Vd8pZGH6SRpkNtoIAiw4"))
+	if err != nil {
+		t.Fatalf("ua key: %v", err)
+	}
+	asPriv, err := ecdh.P256().NewPrivateKey(decode("yfWPiYE-n46HLnH0KqZOF1fJJU3MYrct3AELtAQ-oRw"))
+	if err != nil {
+		t.Fatalf("as key: %v", err)
+	}
+	body, err := seal(uaPub, decode("BTBZMqHH6r4Tts7J_aSIgg"), asPriv, decode("DGv6ra1nlYgDCS1FRnbzlw"), []byte("When I grow up, I want to be a watermelon"))
+	if err != nil {
+		t.Fatalf("seal: %v", err)
+	}
+	want := "DGv6ra1nlYgDCS1FRnbzlwAAEABBBP4z9KsN6nGRTbVYI_c7VJSPQTBtkgcy27mlmlMoZIIgDll6e3vCYLocInmYWAmS6TlzAC8wEqKK6PBru3jl7A_yl95bQpu6cVPTpK4Mqgkf1CXztLVBSt2Ks3oZwbuwXPXLWyouBWLVWGNWQexSgSxsj_Qulcy4a-fN"
+	if got := b64.EncodeToString(body); got != want {
+		t.Fatalf("body = %s\nwant   %s", got, want)
+	}
+}
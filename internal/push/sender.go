@@ -0,0 +1,285 @@
+// Package push sends Web Push notifications (RFC 8030) with VAPID authentication (RFC 8292)
+// and aes128gcm payload encryption (RFC 8291), using keys generated and stored locally.
+package push
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// keysFile and subscriptionsFile live in the data directory.
+	keysFile          = "vapid.json"
+	subscriptionsFile = "push_subscriptions.json"
+	// defaultTTL is how long a push service keeps an undelivered notification.
+	defaultTTL = 10 * time.Minute
+	// maxSubscriptions bounds the stored browsers; the oldest is dropped first.
+	maxSubscriptions = 20
+)
+
+// ErrGone is returned by Send when the push service reports the subscription expired.
+var ErrGone = errors.New("push: subscription gone")
+
+// Subscription is a browser PushSubscription as serialized by its toJSON method.
+type Subscription struct {
+	Endpoint string `json:"endpoint"`
+	Keys     struct {
+		P256dh string `json:"p256dh"`
+		Auth   string `json:"auth"`
+	} `json:"keys"`
+}
+
+// Message is the notification payload handed to the service worker.
+type Message struct {
+	Title string `json:"title"`
+	Body  string `json:"body,omitempty"`
+	// Tag lets a newer notification of the same kind replace an older one.
+	Tag string `json:"tag,omitempty"`
+	// Urgent asks the push service to wake the device right away.
+	Urgent bool `json:"-"`
+}
+
+// Sender stores subscriptions and delivers notifications to them.
+type Sender struct {
+	mu      sync.Mutex
+	keys    *Keys
+	subject string
+	path    string
+	client  *http.Client
+	subs    []Subscription
+}
+
+// NewSender loads (or generates) the VAPID keys and the subscriptions stored in dir.
+// subject identifies the sender to push services, usually a mailto: URL.
+func NewSender(dir, subject string) (*Sender, error) {
+	keys, err := LoadOrCreateKeys(filepath.Join(dir, keysFile))
+	if err != nil {
+		return nil, err
+	}
+	s := &Sender{
+		keys:    keys,
+		subject: subject,
+		path:    filepath.Join(dir, subscriptionsFile),
+		client:  &http.Client{Timeout: 15 * time.Second},
+	}
+	data, err := os.ReadFile(s.path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &s.subs); err != nil {
+			return nil, fmt.Errorf("push: read subscriptions: %w", err)
+		}
+	}
+	return s, nil
+}
+
+// SetClient replaces the HTTP client used to reach push services.
+func (s *Sender) SetClient(c *http.Client) {
+	s.mu.Lock()
+	s.client = c
+	s.mu.Unlock()
+}
+
+// PublicKey returns the VAPID public key browsers subscribe with.
+func (s *Sender) PublicKey() string {
+	return s.keys.PublicKey()
+}
+
+// Subscribe validates and stores a subscription, replacing one with the same endpoint.
+func (s *Sender) Subscribe(sub Subscription) error {
+	if err := validateSubscription(sub); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.removeLocked(sub.Endpoint)
+	s.subs = append(s.subs, sub)
+	if len(s.subs) > maxSubscriptions {
+		s.subs = s.subs[len(s.subs)-maxSubscriptions:]
+	}
+	return s.saveLocked()
+}
+
+// Unsubscribe forgets the subscription with the given endpoint.
+func (s *Sender) Unsubscribe(endpoint string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.removeLocked(endpoint) {
+		return nil
+	}
+	return s.saveLocked()
+}
+
+// Subscriptions returns the stored subscriptions.
+func (s *Sender) Subscriptions() []Subscription {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Subscription(nil), s.subs...)
+}
+
+// Broadcast sends a message to every subscription, dropping the ones the push service
+// reports gone. It returns how many deliveries were accepted.
+func (s *Sender) Broadcast(ctx context.Context, msg Message) (int, error) {
+	if s == nil {
+		return 0, nil
+	}
+	var errs []error
+	sent := 0
+	for _, sub := range s.Subscriptions() {
+		err := s.Send(ctx, sub, msg)
+		switch {
+		case err == nil:
+			sent++
+		case errors.Is(err, ErrGone):
+			if err := s.Unsubscribe(sub.Endpoint); err != nil {
+				errs = append(errs, err)
+			}
+		default:
+			errs = append(errs, err)
+		}
+	}
+	return sent, errors.Join(errs...)
+}
+
+// Send encrypts a message for one subscription and posts it to its push service.
+func (s *Sender) Send(ctx context.Context, sub Subscription, msg Message) error {
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	body, err := encrypt(sub, payload)
+	if err != nil {
+		return err
+	}
+	auth, err := s.keys.authorization(sub.Endpoint, s.subject, time.Now())
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", auth)
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("Content-Encoding", "aes128gcm")
+	req.Header.Set("TTL", strconv.Itoa(int(defaultTTL.Seconds())))
+	if msg.Urgent {
+		req.Header.Set("Urgency", "high")
+	}
+	if validTopic(msg.Tag) {
+		// A pending message with the same topic is replaced instead of queued.
+		req.Header.Set("Topic", msg.Tag)
+	}
+
+	s.mu.Lock()
+	client := s.client
+	s.mu.Unlock()
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return ErrGone
+	case resp.StatusCode >= 300:
+		return fmt.Errorf("push: %s answered %s", hostOf(sub.Endpoint), resp.Status)
+	}
+	return nil
+}
+
+// removeLocked drops the subscription with the endpoint, reporting whether one existed.
+func (s *Sender) removeLocked(endpoint string) bool {
+	for i, sub := range s.subs {
+		if sub.Endpoint == endpoint {
+			s.subs = append(s.subs[:i:i], s.subs[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// saveLocked persists the subscriptions.
+func (s *Sender) saveLocked() error {
+	data, err := json.Marshal(s.subs)
+	if err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// validateSubscription checks the endpoint and keys. Endpoints must be https, except on
+// loopback hosts so a local push-service stand-in can be used.
+func validateSubscription(sub Subscription) error {
+	u, err := url.Parse(sub.Endpoint)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("push: invalid endpoint")
+	}
+	if u.Scheme != "https" && !(u.Scheme == "http" && isLoopback(u.Hostname())) {
+		return fmt.Errorf("push: endpoint must be https")
+	}
+	if raw, err := decodeKey(sub.Keys.P256dh); err != nil || len(raw) != 65 {
+		return fmt.Errorf("push: invalid p256dh key")
+	}
+	if raw, err := decodeKey(sub.Keys.Auth); err != nil || len(raw) != 16 {
+		return fmt.Errorf("push: invalid auth secret")
+	}
+	return nil
+}
+
+// decodeKey decodes a base64url key, tolerating the padding some browsers add.
+func decodeKey(s string) ([]byte, error) {
+	return b64.DecodeString(strings.TrimRight(s, "="))
+}
+
+// validTopic reports whether tag can be sent as a Topic header: at most 32 base64url characters.
+func validTopic(tag string) bool {
+	if tag == "" || len(tag) > 32 {
+		return false
+	}
+	for _, c := range tag {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// isLoopback reports whether host names the local machine.
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// hostOf returns the host of an endpoint for log messages, which must not leak the token path.
+func hostOf(endpoint string) string {
+	if u, err := url.Parse(endpoint); err == nil {
+		return u.Host
+	}
+	return "push service"
+}
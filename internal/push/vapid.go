@@ -0,0 +1,127 @@
+// Package push sends Web Push notifications (RFC 8030) with VAPID authentication (RFC 8292)
+// and aes128gcm payload encryption (RFC 8291), using keys generated and stored locally.
+package push
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// jwtLifetime is how long a VAPID token stays valid; push services reject more than 24h.
+const jwtLifetime = 12 * time.Hour
+
+// b64 is the unpadded base64url encoding used throughout Web Push.
+var b64 = base64.RawURLEncoding
+
+// Keys is the application server's VAPID key pair.
+type Keys struct {
+	priv *ecdsa.PrivateKey
+	pub  []byte
+}
+
+// keyFile is the on-disk form of Keys.
+type keyFile struct {
+	PublicKey  string `json:"publicKey"`
+	PrivateKey string `json:"privateKey"`
+}
+
+// LoadOrCreateKeys reads the VAPID key pair stored at path, generating and saving a new
+// P-256 pair when the file does not exist yet.
+func LoadOrCreateKeys(path string) (*Keys, error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		var f keyFile
+		if err := json.Unmarshal(data, &f); err != nil {
+			return nil, fmt.Errorf("push: read keys: %w", err)
+		}
+		raw, err := b64.DecodeString(f.PrivateKey)
+		if err != nil {
+			return nil, fmt.Errorf("push: read keys: %w", err)
+		}
+		priv, err := ecdsa.ParseRawPrivateKey(elliptic.P256(), raw)
+		if err != nil {
+			return nil, fmt.Errorf("push: read keys: %w", err)
+		}
+		return newKeys(priv)
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := newKeys(priv)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := priv.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	data, err = json.MarshalIndent(keyFile{PublicKey: keys.PublicKey(), PrivateKey: b64.EncodeToString(raw)}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// newKeys caches the encoded public key of priv.
+func newKeys(priv *ecdsa.PrivateKey) (*Keys, error) {
+	pub, err := priv.PublicKey.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	return &Keys{priv: priv, pub: pub}, nil
+}
+
+// PublicKey returns the uncompressed public key, base64url encoded, as browsers expect for
+// the applicationServerKey subscription option.
+func (k *Keys) PublicKey() string {
+	return b64.EncodeToString(k.pub)
+}
+
+// authorization returns the VAPID Authorization header value for a push endpoint.
+func (k *Keys) authorization(endpoint, subject string, now time.Time) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+	header := b64.EncodeToString([]byte(`{"typ":"JWT","alg":"ES256"}`))
+	claims, err := json.Marshal(map[string]any{
+		"aud": u.Scheme + "://" + u.Host,
+		"exp": now.Add(jwtLifetime).Unix(),
+		"sub": subject,
+	})
+	if err != nil {
+		return "", err
+	}
+	unsigned := header + "." + b64.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+	r, s, err := ecdsa.Sign(rand.Reader, k.priv, digest[:])
+	if err != nil {
+		return "", err
+	}
+	// JWS wants the fixed-size r||s form rather than ASN.1.
+	sig := make([]byte, 64)
+	r.FillBytes(sig[:32])
+	s.FillBytes(sig[32:])
+	return "vapid t=" + unsigned + "." + b64.EncodeToString(sig) + ", k=" + k.PublicKey(), nil
+}
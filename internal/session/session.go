@@ -13,12 +13,24 @@ const ModePresetup = "presetup"
 // ModeRun is the cropped streaming mode.
 const ModeRun = "run"
 
+// ModeChat streams only the calibrated chat input rectangle.
+const ModeChat = "chat"
+
+// ModeScroll streams only the calibrated scroll rectangle.
+const ModeScroll = "scroll"
+
+// ModeComposite streams the calibrated layout regions stacked into one frame.
+const ModeComposite = "composite"
+
 // VideoWebRTC runs the RTP pipeline for WebRTC video.
 const VideoWebRTC = "webrtc"
 
 // VideoMJPEG runs the MJPEG preview pipeline only.
 const VideoMJPEG = "mjpeg"
 
+// VideoTerminal streams a PTY-hosted command as text instead of video.
+const VideoTerminal = "terminal"
+
 // Snapshot represents a read-only view of the current session state.
 type Snapshot struct {
 	Authenticated bool
@@ -27,6 +39,46 @@ type Snapshot struct {
 	MonitorIndex  int
 	VideoMode     string
 	Calib         calib.Calib
+	Zoom          calib.Zoom
+}
+
+// IsCropped reports whether the mode streams a calibrated crop instead of the whole monitor.
+func IsCropped(mode string) bool {
+	switch mode {
+	case ModeRun, ModeChat, ModeScroll:
+		return true
+	default:
+		return false
+	}
+}
+
+// IsRunLike reports whether the mode streams calibrated content and therefore uses
+// run-mode gestures and cursor caging.
+func IsRunLike(mode string) bool {
+	return IsCropped(mode) || mode == ModeComposite
+}
+
+// CropRect returns the monitor-relative crop rectangle for a cropped mode.
+// Chat and scroll crops are converted to absolute via the plugin rect and fall back
+// to the whole plugin rect when they are not calibrated yet.
+func CropRect(mode string, c calib.Calib) (calib.Rect, bool) {
+	if !IsCropped(mode) {
+		return calib.Rect{}, false
+	}
+	plugin := calib.Normalize(c.PluginAbs)
+	var sub calib.Rect
+	switch mode {
+	case ModeChat:
+		sub = calib.Normalize(c.ChatRel)
+	case ModeScroll:
+		sub = calib.Normalize(c.ScrollRel)
+	default:
+		return plugin, true
+	}
+	if sub.W <= 0 || sub.H <= 0 {
+		return plugin, true
+	}
+	return calib.Rect{X: plugin.X + sub.X, Y: plugin.Y + sub.Y, W: sub.W, H: sub.H}, true
 }
 
 // Session holds runtime state for the active viewer.
@@ -39,6 +91,8 @@ type Session struct {
 	monitorIndex  int
 	videoMode     string
 	calib         calib.Calib
+	zoom          calib.Zoom
+	onChange      func()
 }
 
 // New returns an initialized session with the given password.
@@ -67,6 +121,13 @@ func (s *Session) Authenticate(pass string) bool {
 	return false
 }
 
+// CheckPassword validates a password without changing the session's authentication state.
+func (s *Session) CheckPassword(pass string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.password == "" || (pass != "" && pass == s.password)
+}
+
 // Logout clears authentication state.
 func (s *Session) Logout() {
 	s.mu.Lock()
@@ -87,8 +148,9 @@ func (s *Session) IsAuthenticated() bool {
 // SetInputEnabled toggles whether inputs are forwarded to the host.
 func (s *Session) SetInputEnabled(enabled bool) {
 	s.mu.Lock()
-	defer s.mu.Unlock()
 	s.inputEnabled = enabled
+	s.mu.Unlock()
+	s.changed()
 }
 
 // InputEnabled reports whether inputs are forwarded to the host.
@@ -101,8 +163,12 @@ func (s *Session) InputEnabled() bool {
 // SetMode sets the current session mode.
 func (s *Session) SetMode(mode string) {
 	s.mu.Lock()
-	defer s.mu.Unlock()
 	s.mode = mode
+	if mode == ModeComposite {
+		s.zoom = calib.Zoom{}
+	}
+	s.mu.Unlock()
+	s.changed()
 }
 
 // Mode returns the current session mode.
@@ -115,8 +181,9 @@ func (s *Session) Mode() string {
 // SetMonitor sets the selected monitor index.
 func (s *Session) SetMonitor(idx int) {
 	s.mu.Lock()
-	defer s.mu.Unlock()
 	s.monitorIndex = idx
+	s.mu.Unlock()
+	s.changed()
 }
 
 // Monitor returns the selected monitor index.
@@ -129,13 +196,16 @@ func (s *Session) Monitor() int {
 // SetVideoMode sets which video pipeline the server should run.
 func (s *Session) SetVideoMode(mode string) {
 	s.mu.Lock()
-	defer s.mu.Unlock()
 	switch mode {
 	case VideoMJPEG:
 		s.videoMode = VideoMJPEG
+	case VideoTerminal:
+		s.videoMode = VideoTerminal
 	default:
 		s.videoMode = VideoWebRTC
 	}
+	s.mu.Unlock()
+	s.changed()
 }
 
 // VideoMode returns the active video pipeline mode.
@@ -151,15 +221,36 @@ func (s *Session) VideoMode() string {
 // SetCalib stores calibration data.
 func (s *Session) SetCalib(c calib.Calib) {
 	s.mu.Lock()
-	defer s.mu.Unlock()
-	s.calib = c
+	s.calib = c.Clone()
+	s.mu.Unlock()
+	s.changed()
 }
 
 // GetCalib returns the current calibration data.
 func (s *Session) GetCalib() calib.Calib {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
-	return s.calib
+	return s.calib.Clone()
+}
+
+// SetZoom sets the normalized zoom viewport inside the active crop. The composite filter
+// graph has no zoom stage, so the viewport stays full while that mode is active.
+func (s *Session) SetZoom(z calib.Zoom) {
+	s.mu.Lock()
+	if z.IsFull() || s.mode == ModeComposite {
+		s.zoom = calib.Zoom{}
+	} else {
+		s.zoom = calib.NormalizeZoom(z)
+	}
+	s.mu.Unlock()
+	s.changed()
+}
+
+// Zoom returns the normalized zoom viewport (zero value when not zoomed).
+func (s *Session) Zoom() calib.Zoom {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.zoom
 }
 
 // Snapshot returns a copy of the current session state.
@@ -172,6 +263,25 @@ func (s *Session) Snapshot() Snapshot {
 		Mode:          s.mode,
 		MonitorIndex:  s.monitorIndex,
 		VideoMode:     s.videoMode,
-		Calib:         s.calib,
+		Calib:         s.calib.Clone(),
+		Zoom:          s.zoom,
+	}
+}
+
+// SetChangeHandler registers fn to run after every setter, outside the session lock, so
+// observers can push the new state to other viewers.
+func (s *Session) SetChangeHandler(fn func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onChange = fn
+}
+
+// changed runs the change handler, if any.
+func (s *Session) changed() {
+	s.mu.RLock()
+	fn := s.onChange
+	s.mu.RUnlock()
+	if fn != nil {
+		fn()
 	}
 }
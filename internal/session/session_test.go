@@ -1,6 +1,10 @@
 package session
 
-import "testing"
+import (
+	"testing"
+
+	"github.com/frudas24/deskslice/internal/calib"
+)
 
 // TestAuthenticate_Success verifies successful authentication.
 func TestAuthenticate_Success(t *testing.T) {
@@ -59,3 +63,94 @@ func TestSnapshot(t *testing.T) {
 		t.Fatalf("unexpected snapshot: %+v", snap)
 	}
 }
+
+// TestSetZoom_FullClears verifies a full viewport resets the zoom to the zero value.
+func TestSetZoom_FullClears(t *testing.T) {
+	s := New("secret")
+	s.SetZoom(calib.Zoom{X: 0.25, Y: 0.25, W: 0.5, H: 0.5})
+	if s.Zoom().IsFull() {
+		t.Fatalf("expected zoom to be set")
+	}
+	s.SetZoom(calib.Zoom{X: 0, Y: 0, W: 1, H: 1})
+	if s.Zoom() != (calib.Zoom{}) {
+		t.Fatalf("expected zero zoom, got %+v", s.Zoom())
+	}
+}
+
+// TestSetZoom_IgnoredInComposite verifies composite mode clears the zoom and refuses new ones.
+func TestSetZoom_IgnoredInComposite(t *testing.T) {
+	s := New("secret")
+	s.SetZoom(calib.Zoom{X: 0.25, Y: 0.25, W: 0.5, H: 0.5})
+	s.SetMode(ModeComposite)
+	if s.Zoom() != (calib.Zoom{}) {
+		t.Fatalf("expected composite mode to clear the zoom, got %+v", s.Zoom())
+	}
+	s.SetZoom(calib.Zoom{X: 0.25, Y: 0.25, W: 0.5, H: 0.5})
+	if s.Zoom() != (calib.Zoom{}) {
+		t.Fatalf("expected zoom to be ignored in composite mode, got %+v", s.Zoom())
+	}
+}
+
+// TestCropRect_ChatAndScroll verifies chat/scroll crops are made absolute via the plugin rect.
+func TestCropRect_ChatAndScroll(t *testing.T) {
+	c := calib.Calib{
+		PluginAbs: calib.Rect{X: 100, Y: 200, W: 300, H: 400},
+		ChatRel:   calib.Rect{X: 10, Y: 350, W: 280, H: 40},
+	}
+	r, ok := CropRect(ModeChat, c)
+	if !ok || r != (calib.Rect{X: 110, Y: 550, W: 280, H: 40}) {
+		t.Fatalf("unexpected chat crop: %+v ok=%t", r, ok)
+	}
+	r, ok = CropRect(ModeScroll, c)
+	if !ok || r != c.PluginAbs {
+		t.Fatalf("expected plugin fallback for uncalibrated scroll, got %+v ok=%t", r, ok)
+	}
+	if _, ok := CropRect(ModePresetup, c); ok {
+		t.Fatalf("expected presetup to be uncropped")
+	}
+}
+
+// TestSetVideoMode_AcceptsTerminal verifies the terminal video mode is accepted and unknown modes fall back to WebRTC.
+func TestSetVideoMode_AcceptsTerminal(t *testing.T) {
+	s := New("pw")
+	s.SetVideoMode(VideoTerminal)
+	if s.VideoMode() != VideoTerminal {
+		t.Fatalf("expected terminal, got %s", s.VideoMode())
+	}
+	s.SetVideoMode("bogus")
+	if s.VideoMode() != VideoWebRTC {
+		t.Fatalf("expected webrtc fallback, got %s", s.VideoMode())
+	}
+}
+
+// TestCheckPassword_DoesNotAuthenticate verifies password checks leave the session state untouched.
+func TestCheckPassword_DoesNotAuthenticate(t *testing.T) {
+	s := New("pw")
+	if s.CheckPassword("nope") || !s.CheckPassword("pw") {
+		t.Fatalf("unexpected password check results")
+	}
+	if s.IsAuthenticated() {
+		t.Fatalf("expected session to stay unauthenticated")
+	}
+}
+
+// TestSetChangeHandler_RunsAfterSetters verifies every setter reports a change and the
+// handler may read the session without deadlocking.
+func TestSetChangeHandler_RunsAfterSetters(t *testing.T) {
+	s := New("pw")
+	var modes []string
+	s.SetChangeHandler(func() { modes = append(modes, s.Snapshot().Mode) })
+	s.SetMode(ModeRun)
+	s.SetMonitor(2)
+	s.SetInputEnabled(false)
+	s.SetVideoMode(VideoWebRTC)
+	s.SetCalib(calib.Calib{})
+	s.SetZoom(calib.Zoom{})
+	if len(modes) != 6 || modes[0] != ModeRun {
+		t.Fatalf("unexpected change notifications: %v", modes)
+	}
+	s.Authenticate("pw")
+	if len(modes) != 6 {
+		t.Fatalf("authentication should not report a state change")
+	}
+}
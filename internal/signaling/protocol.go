@@ -5,7 +5,15 @@ import "github.com/pion/webrtc/v3"
 
 // Message is a websocket signaling payload.
 type Message struct {
-	T         string                   `json:"t"`
-	SDP       string                   `json:"sdp,omitempty"`
-	Candidate *webrtc.ICECandidateInit `json:"candidate,omitempty"`
+	T          string                   `json:"t"`
+	SDP        string                   `json:"sdp,omitempty"`
+	Candidate  *webrtc.ICECandidateInit `json:"candidate,omitempty"`
+	ICEServers []ICEServer              `json:"iceServers,omitempty"`
+}
+
+// ICEServer is a STUN/TURN server handed to the browser in the "config" message.
+type ICEServer struct {
+	URLs       []string `json:"urls"`
+	Username   string   `json:"username,omitempty"`
+	Credential string   `json:"credential,omitempty"`
 }
@@ -27,3 +27,20 @@ func TestProtocol_ICE(t *testing.T) {
 		t.Fatalf("unexpected message: %+v", msg)
 	}
 }
+
+// TestProtocol_ConfigICEServers verifies the config message encodes servers in RTCIceServer shape.
+func TestProtocol_ConfigICEServers(t *testing.T) {
+	msg := Message{T: "config", ICEServers: []ICEServer{
+		{URLs: []string{"stun:stun.example.com:3478"}},
+		{URLs: []string{"turn:10.8.0.1:3478?transport=udp"}, Username: "1700000000", Credential: "c2VjcmV0"},
+	}}
+	data, err := json.Marshal(msg)
+	if err != nil {
+		t.Fatalf("marshal failed: %v", err)
+	}
+	want := `{"t":"config","iceServers":[{"urls":["stun:stun.example.com:3478"]},` +
+		`{"urls":["turn:10.8.0.1:3478?transport=udp"],"username":"1700000000","credential":"c2VjcmV0"}]}`
+	if string(data) != want {
+		t.Fatalf("unexpected encoding:\n got %s\nwant %s", data, want)
+	}
+}
@@ -0,0 +1,204 @@
+// Package signaling defines signaling protocol messages for WebRTC.
+package signaling
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+
+	pub "github.com/frudas24/deskslice/internal/webrtc"
+	"github.com/pion/webrtc/v3"
+)
+
+// WHEPPath is the endpoint standard WHEP players POST their SDP offer to.
+const WHEPPath = "/whep"
+
+// maxOfferBytes caps the size of an SDP offer body.
+const maxOfferBytes = 64 << 10
+
+// WHEPServer implements the WebRTC-HTTP Egress Protocol on top of the shared publisher track.
+// Every session gets its own view-only peer, so players neither replace the UI viewer nor
+// each other, and they have no input channels. Offers are answered once ICE gathering completes, so trickle ICE (PATCH) is not supported.
+type WHEPServer struct {
+	mu         sync.Mutex
+	publisher  *pub.Publisher
+	authFn     func() bool
+	checkToken func(token string) bool
+	resources  map[string]*webrtc.PeerConnection
+}
+
+// NewWHEPServer creates a WHEP endpoint. Requests are accepted when authFn reports an authenticated
+// UI session or when checkToken accepts the "Authorization: Bearer" token.
+func NewWHEPServer(publisher *pub.Publisher, authFn func() bool, checkToken func(string) bool) *WHEPServer {
+	return &WHEPServer{
+		publisher:  publisher,
+		authFn:     authFn,
+		checkToken: checkToken,
+		resources:  make(map[string]*webrtc.PeerConnection),
+	}
+}
+
+// ServeHTTP handles session creation on WHEPPath and teardown on WHEPPath/{id}.
+func (s *WHEPServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	setWHEPHeaders(w)
+	if r.Method == http.MethodOptions {
+		w.Header().Set("Accept-Post", "application/sdp")
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if !s.authorized(r) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, WHEPPath), "/")
+	switch {
+	case id == "" && r.Method == http.MethodPost:
+		s.handleOffer(w, r)
+	case id != "" && r.Method == http.MethodDelete:
+		s.handleDelete(w, id)
+	case id != "" && r.Method == http.MethodPatch:
+		http.Error(w, "trickle ICE not supported", http.StatusMethodNotAllowed)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// authorized reports whether the request carries a UI session or a valid bearer token.
+func (s *WHEPServer) authorized(r *http.Request) bool {
+	if s.authFn != nil && s.authFn() {
+		return true
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && s.checkToken != nil && s.checkToken(strings.TrimSpace(token))
+}
+
+// handleOffer creates a peer for the offer and replies 201 with the answer and resource URL.
+func (s *WHEPServer) handleOffer(w http.ResponseWriter, r *http.Request) {
+	if ct := r.Header.Get("Content-Type"); !strings.HasPrefix(ct, "application/sdp") {
+		http.Error(w, "content type must be application/sdp", http.StatusUnsupportedMediaType)
+		return
+	}
+	offer, err := io.ReadAll(io.LimitReader(r.Body, maxOfferBytes))
+	if err != nil || len(offer) == 0 {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	peer, answer, err := s.answer(string(offer))
+	if err != nil {
+		log.Printf("whep: offer failed: %v", err)
+		status := http.StatusBadRequest
+		if errors.Is(err, pub.ErrCodecUnsupported) {
+			status = http.StatusNotAcceptable
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	id, err := newResourceID()
+	if err != nil {
+		_ = peer.Close()
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	s.track(id, peer)
+	log.Printf("whep: session %s from %s", id, r.RemoteAddr)
+
+	w.Header().Set("Content-Type", "application/sdp")
+	w.Header().Set("Location", WHEPPath+"/"+id)
+	w.WriteHeader(http.StatusCreated)
+	_, _ = io.WriteString(w, answer)
+}
+
+// answer negotiates a new view-only publisher peer for the offer and returns the complete answer SDP.
+func (s *WHEPServer) answer(offer string) (*webrtc.PeerConnection, string, error) {
+	peer, err := s.publisher.NewViewerPeer()
+	if err != nil {
+		return nil, "", err
+	}
+	if err := peer.SetRemoteDescription(webrtc.SessionDescription{Type: webrtc.SDPTypeOffer, SDP: offer}); err != nil {
+		_ = peer.Close()
+		return nil, "", err
+	}
+	// Players take the codec the UI viewer negotiated; switching it would freeze that viewer.
+	if _, err := s.publisher.AcceptViewerCodec(peer); err != nil {
+		_ = peer.Close()
+		return nil, "", err
+	}
+	answer, err := peer.CreateAnswer(nil)
+	if err != nil {
+		_ = peer.Close()
+		return nil, "", err
+	}
+	gatherComplete := webrtc.GatheringCompletePromise(peer)
+	if err := peer.SetLocalDescription(answer); err != nil {
+		_ = peer.Close()
+		return nil, "", err
+	}
+	<-gatherComplete
+	local := peer.LocalDescription()
+	if local == nil {
+		_ = peer.Close()
+		return nil, "", fmt.Errorf("missing local description")
+	}
+	return peer, local.SDP, nil
+}
+
+// track registers a session and forgets it once its peer connection ends. A failed peer is
+// closed so the publisher stops counting it.
+func (s *WHEPServer) track(id string, peer *webrtc.PeerConnection) {
+	s.mu.Lock()
+	s.resources[id] = peer
+	s.mu.Unlock()
+	peer.OnConnectionStateChange(func(state webrtc.PeerConnectionState) {
+		if state == webrtc.PeerConnectionStateClosed || state == webrtc.PeerConnectionStateFailed {
+			s.mu.Lock()
+			if s.resources[id] == peer {
+				delete(s.resources, id)
+			}
+			s.mu.Unlock()
+		}
+		if state == webrtc.PeerConnectionStateFailed {
+			go func() { _ = peer.Close() }()
+		}
+	})
+}
+
+// handleDelete tears down a WHEP session.
+func (s *WHEPServer) handleDelete(w http.ResponseWriter, id string) {
+	s.mu.Lock()
+	peer, ok := s.resources[id]
+	delete(s.resources, id)
+	s.mu.Unlock()
+	if !ok {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	_ = peer.Close()
+	log.Printf("whep: session %s closed", id)
+	w.WriteHeader(http.StatusOK)
+}
+
+// setWHEPHeaders allows players served from other origins to use the endpoint.
+func setWHEPHeaders(w http.ResponseWriter) {
+	h := w.Header()
+	h.Set("Access-Control-Allow-Origin", "*")
+	h.Set("Access-Control-Allow-Methods", "OPTIONS, POST, PATCH, DELETE")
+	h.Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+	h.Set("Access-Control-Expose-Headers", "Location")
+}
+
+// newResourceID returns a random identifier for a WHEP session URL.
+func newResourceID() (string, error) {
+	var b [12]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b[:]), nil
+}
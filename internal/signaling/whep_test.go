@@ -0,0 +1,271 @@
+package signaling
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	pub "github.com/frudas24/deskslice/internal/webrtc"
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v3"
+)
+
+// TestWHEP_RequiresAuth verifies offers without a session or bearer token are rejected.
+func TestWHEP_RequiresAuth(t *testing.T) {
+	srv := NewWHEPServer(nil, func() bool { return false }, func(token string) bool { return token == "pw" })
+	req := httptest.NewRequest(http.MethodPost, WHEPPath, strings.NewReader("v=0"))
+	req.Header.Set("Content-Type", "application/sdp")
+	req.Header.Set("Authorization", "Bearer wrong")
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %d", rec.Code)
+	}
+}
+
+// TestWHEP_OfferAnswerAndTeardown verifies a standard offer gets an SDP answer, a resource URL, and can be deleted,
+// all without closing the UI viewer's peer.
+func TestWHEP_OfferAnswerAndTeardown(t *testing.T) {
+	publisher, err := pub.NewPublisher(pub.ICEConfig{}, nil)
+	if err != nil {
+		t.Fatalf("new publisher: %v", err)
+	}
+	counts := make(chan int, 4)
+	publisher.SetViewerHandler(func(n int) { counts <- n })
+	phone, err := publisher.NewPeer()
+	if err != nil {
+		t.Fatalf("phone peer: %v", err)
+	}
+	defer publisher.ClosePeer()
+	srv := NewWHEPServer(publisher, nil, func(token string) bool { return token == "pw" })
+	httpSrv := httptest.NewServer(srv)
+	defer httpSrv.Close()
+
+	client, err := webrtc.NewPeerConnection(webrtc.Configuration{})
+	if err != nil {
+		t.Fatalf("client peer: %v", err)
+	}
+	defer client.Close()
+	if _, err := client.AddTransceiverFromKind(webrtc.RTPCodecTypeVideo, webrtc.RTPTransceiverInit{Direction: webrtc.RTPTransceiverDirectionRecvonly}); err != nil {
+		t.Fatalf("add transceiver: %v", err)
+	}
+	offer, err := client.CreateOffer(nil)
+	if err != nil {
+		t.Fatalf("create offer: %v", err)
+	}
+	gathered := webrtc.GatheringCompletePromise(client)
+	if err := client.SetLocalDescription(offer); err != nil {
+		t.Fatalf("set local: %v", err)
+	}
+	<-gathered
+
+	req, _ := http.NewRequest(http.MethodPost, httpSrv.URL+WHEPPath, strings.NewReader(client.LocalDescription().SDP))
+	req.Header.Set("Content-Type", "application/sdp")
+	req.Header.Set("Authorization", "Bearer pw")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("post offer: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", resp.StatusCode, body)
+	}
+	location := resp.Header.Get("Location")
+	if !strings.HasPrefix(location, WHEPPath+"/") || resp.Header.Get("Content-Type") != "application/sdp" {
+		t.Fatalf("unexpected headers: %v", resp.Header)
+	}
+	if err := client.SetRemoteDescription(webrtc.SessionDescription{Type: webrtc.SDPTypeAnswer, SDP: string(body)}); err != nil {
+		t.Fatalf("set remote answer: %v", err)
+	}
+	if n := <-counts; n != 1 || publisher.ViewerCount() != 1 {
+		t.Fatalf("expected one viewer peer, got %d", n)
+	}
+	if phone.ConnectionState() == webrtc.PeerConnectionStateClosed {
+		t.Fatalf("WHEP session closed the UI viewer's peer")
+	}
+
+	for _, want := range []int{http.StatusOK, http.StatusNotFound} {
+		del, _ := http.NewRequest(http.MethodDelete, httpSrv.URL+location, nil)
+		del.Header.Set("Authorization", "Bearer pw")
+		resp, err := http.DefaultClient.Do(del)
+		if err != nil {
+			t.Fatalf("delete: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Fatalf("expected %d, got %d", want, resp.StatusCode)
+		}
+	}
+	select {
+	case n := <-counts:
+		if n != 0 {
+			t.Fatalf("expected no viewer peers after delete, got %d", n)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("deleted session was not released")
+	}
+}
+
+// TestWHEP_PlayerCannotSwitchCodec verifies a player preferring VP8 is served the H264 track
+// the UI viewer negotiated, the UI track keeps receiving packets, and a player without H264
+// is refused.
+func TestWHEP_PlayerCannotSwitchCodec(t *testing.T) {
+	publisher, err := pub.NewPublisher(pub.ICEConfig{}, []string{pub.CodecH264, pub.CodecVP8})
+	if err != nil {
+		t.Fatalf("new publisher: %v", err)
+	}
+	switched := make(chan string, 1)
+	publisher.SetCodecHandler(func(codec string) { switched <- codec })
+	phone, err := publisher.NewPeer()
+	if err != nil {
+		t.Fatalf("phone peer: %v", err)
+	}
+	defer publisher.ClosePeer()
+	defer publisher.CloseViewers()
+
+	ui := clientPeer(t, webrtc.MimeTypeH264, webrtc.MimeTypeVP8)
+	defer ui.Close()
+	uiPackets := receivePackets(ui)
+	offer := gatherOffer(t, ui)
+	if err := phone.SetRemoteDescription(offer); err != nil {
+		t.Fatalf("phone remote offer: %v", err)
+	}
+	if codec, err := publisher.NegotiateCodec(phone); err != nil || codec != pub.CodecH264 {
+		t.Fatalf("ui negotiate: codec=%q err=%v", codec, err)
+	}
+	answer, err := phone.CreateAnswer(nil)
+	if err != nil {
+		t.Fatalf("phone answer: %v", err)
+	}
+	gathered := webrtc.GatheringCompletePromise(phone)
+	if err := phone.SetLocalDescription(answer); err != nil {
+		t.Fatalf("phone local answer: %v", err)
+	}
+	<-gathered
+	if err := ui.SetRemoteDescription(*phone.LocalDescription()); err != nil {
+		t.Fatalf("ui remote answer: %v", err)
+	}
+
+	srv := NewWHEPServer(publisher, nil, func(token string) bool { return token == "pw" })
+	httpSrv := httptest.NewServer(srv)
+	defer httpSrv.Close()
+
+	player := clientPeer(t, webrtc.MimeTypeVP8, webrtc.MimeTypeH264)
+	defer player.Close()
+	playerPackets := receivePackets(player)
+	status, body := postOffer(t, httpSrv.URL, gatherOffer(t, player))
+	if status != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", status, body)
+	}
+	if err := player.SetRemoteDescription(webrtc.SessionDescription{Type: webrtc.SDPTypeAnswer, SDP: body}); err != nil {
+		t.Fatalf("player remote answer: %v", err)
+	}
+
+	vp8Only := clientPeer(t, webrtc.MimeTypeVP8)
+	defer vp8Only.Close()
+	if status, body := postOffer(t, httpSrv.URL, gatherOffer(t, vp8Only)); status != http.StatusNotAcceptable {
+		t.Fatalf("expected 406 for a VP8-only player, got %d: %s", status, body)
+	}
+	select {
+	case codec := <-switched:
+		t.Fatalf("player switched the codec to %s", codec)
+	default:
+	}
+	if publisher.Codec() != pub.CodecH264 {
+		t.Fatalf("expected h264 to stay, got %s", publisher.Codec())
+	}
+
+	track, err := publisher.Track()
+	if err != nil {
+		t.Fatalf("track: %v", err)
+	}
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		ticker := time.NewTicker(20 * time.Millisecond)
+		defer ticker.Stop()
+		for seq := uint16(0); ; seq++ {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				_ = track.WriteRTP(&rtp.Packet{Header: rtp.Header{Version: 2, SequenceNumber: seq, Timestamp: uint32(seq) * 3000}, Payload: []byte{0x65, 0x88, 0x80}})
+			}
+		}
+	}()
+	for name, packets := range map[string]<-chan struct{}{"ui": uiPackets, "player": playerPackets} {
+		select {
+		case <-packets:
+		case <-time.After(10 * time.Second):
+			t.Fatalf("%s track received no packets", name)
+		}
+	}
+}
+
+// clientPeer creates a receive-only peer whose media engine offers the video codecs in order.
+func clientPeer(t *testing.T, mimeTypes ...string) *webrtc.PeerConnection {
+	t.Helper()
+	media := &webrtc.MediaEngine{}
+	for i, mime := range mimeTypes {
+		codec := webrtc.RTPCodecParameters{RTPCodecCapability: webrtc.RTPCodecCapability{MimeType: mime, ClockRate: 90000}, PayloadType: webrtc.PayloadType(96 + i)}
+		if mime == webrtc.MimeTypeH264 {
+			codec.SDPFmtpLine = "level-asymmetry-allowed=1;packetization-mode=1;profile-level-id=42e01f"
+		}
+		if err := media.RegisterCodec(codec, webrtc.RTPCodecTypeVideo); err != nil {
+			t.Fatalf("register codec: %v", err)
+		}
+	}
+	peer, err := webrtc.NewAPI(webrtc.WithMediaEngine(media)).NewPeerConnection(webrtc.Configuration{})
+	if err != nil {
+		t.Fatalf("client peer: %v", err)
+	}
+	if _, err := peer.AddTransceiverFromKind(webrtc.RTPCodecTypeVideo, webrtc.RTPTransceiverInit{Direction: webrtc.RTPTransceiverDirectionRecvonly}); err != nil {
+		t.Fatalf("add transceiver: %v", err)
+	}
+	return peer
+}
+
+// receivePackets signals once the peer's remote video track delivers its first packet.
+func receivePackets(peer *webrtc.PeerConnection) <-chan struct{} {
+	got := make(chan struct{})
+	peer.OnTrack(func(track *webrtc.TrackRemote, _ *webrtc.RTPReceiver) {
+		if _, _, err := track.ReadRTP(); err == nil {
+			close(got)
+		}
+	})
+	return got
+}
+
+// gatherOffer creates the peer's offer and returns it once ICE gathering completes.
+func gatherOffer(t *testing.T, peer *webrtc.PeerConnection) webrtc.SessionDescription {
+	t.Helper()
+	offer, err := peer.CreateOffer(nil)
+	if err != nil {
+		t.Fatalf("create offer: %v", err)
+	}
+	gathered := webrtc.GatheringCompletePromise(peer)
+	if err := peer.SetLocalDescription(offer); err != nil {
+		t.Fatalf("set local: %v", err)
+	}
+	<-gathered
+	return *peer.LocalDescription()
+}
+
+// postOffer sends an SDP offer to the WHEP endpoint and returns the status and body.
+func postOffer(t *testing.T, baseURL string, offer webrtc.SessionDescription) (int, string) {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodPost, baseURL+WHEPPath, strings.NewReader(offer.SDP))
+	req.Header.Set("Content-Type", "application/sdp")
+	req.Header.Set("Authorization", "Bearer pw")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("post offer: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(body)
+}
@@ -31,6 +31,8 @@ type Server struct {
 	publisher *pub.Publisher
 	policy    ViewerPolicy
 	authFn    func() bool
+	servers   func() []ICEServer
+	onReplace func(remote string)
 	conn      *websocket.Conn
 	peer      *webrtc.PeerConnection
 	pending   []webrtc.ICECandidateInit
@@ -50,6 +52,21 @@ func NewServer(publisher *pub.Publisher, policy ViewerPolicy, authFn func() bool
 	}
 }
 
+// SetICEServers sets the provider for ICE servers sent to each viewer before it builds its
+// peer connection. It is called per handshake so credentials can be minted per session.
+func (s *Server) SetICEServers(fn func() []ICEServer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.servers = fn
+}
+
+// SetReplaceHandler sets a callback run when a new viewer (at remote) replaces the active one.
+func (s *Server) SetReplaceHandler(fn func(remote string)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onReplace = fn
+}
+
 // ServeHTTP upgrades the request and starts the signaling loop.
 func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if s.authFn != nil && !s.authFn() {
@@ -64,19 +81,38 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := s.acceptConn(conn); err != nil {
+	replaced, err := s.acceptConn(conn)
+	if err != nil {
 		log.Printf("signaling: reject from %s: %v", r.RemoteAddr, err)
 		s.rejectConn(conn, err.Error())
 		return
 	}
+	if replaced {
+		s.mu.Lock()
+		onReplace := s.onReplace
+		s.mu.Unlock()
+		if onReplace != nil {
+			onReplace(r.RemoteAddr)
+		}
+	}
 	log.Printf("signaling: connected %s", r.RemoteAddr)
 	defer s.cleanupConn(conn)
 
+	if err := s.sendConfig(conn); err != nil {
+		log.Printf("signaling: send config failed: %v", err)
+		return
+	}
+
 	peer, err := s.publisher.NewPeer()
 	if err != nil {
 		log.Printf("signaling: new peer failed: %v", err)
 		return
 	}
+	if err := s.publisher.AttachControlChannels(peer); err != nil {
+		log.Printf("signaling: control channels failed: %v", err)
+		_ = peer.Close()
+		return
+	}
 	if err := s.attachPeer(conn, peer); err != nil {
 		log.Printf("signaling: attach peer failed: %v", err)
 		_ = peer.Close()
@@ -112,26 +148,45 @@ func (s *Server) NotifyRestart() {
 	if conn == nil {
 		return
 	}
+	// Refresh ICE servers first so the restarted peer gets unexpired credentials.
+	if err := s.sendConfig(conn); err != nil {
+		return
+	}
 	_ = s.sendTo(conn, Message{T: "restart"})
 }
 
-// acceptConn registers a new websocket connection or returns an error.
-func (s *Server) acceptConn(conn *websocket.Conn) error {
+// sendConfig sends the "config" handshake message carrying the current ICE servers.
+func (s *Server) sendConfig(conn *websocket.Conn) error {
+	s.mu.Lock()
+	servers := s.servers
+	s.mu.Unlock()
+	msg := Message{T: "config"}
+	if servers != nil {
+		msg.ICEServers = servers()
+	}
+	return s.sendTo(conn, msg)
+}
+
+// acceptConn registers a new websocket connection, reporting whether it replaced an
+// active one, or returns an error.
+func (s *Server) acceptConn(conn *websocket.Conn) (bool, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	replaced := false
 	if s.conn != nil {
 		switch s.policy {
 		case ViewerReplace:
 			_ = s.conn.Close()
 			s.conn = nil
 			s.peer = nil
+			replaced = true
 		default:
-			return fmt.Errorf("viewer already connected")
+			return false, fmt.Errorf("viewer already connected")
 		}
 	}
 	s.conn = conn
 	s.pending = nil
-	return nil
+	return replaced, nil
 }
 
 // rejectConn sends a policy violation close and closes the socket.
@@ -193,6 +248,9 @@ func (s *Server) handleOffer(conn *websocket.Conn, peer *webrtc.PeerConnection,
 		return err
 	}
 	s.drainICE(peer)
+	if _, err := s.publisher.NegotiateCodec(peer); err != nil {
+		return err
+	}
 	answer, err := peer.CreateAnswer(nil)
 	if err != nil {
 		return err
@@ -0,0 +1,34 @@
+// Package terminal streams a PTY-hosted command to the browser over WebSocket.
+package terminal
+
+import "strings"
+
+// keySequences maps named keys to the bytes an xterm-compatible terminal sends.
+var keySequences = map[string]string{
+	"enter":     "\r",
+	"tab":       "\t",
+	"backspace": "\x7f",
+	"escape":    "\x1b",
+	"esc":       "\x1b",
+	"up":        "\x1b[A",
+	"down":      "\x1b[B",
+	"right":     "\x1b[C",
+	"left":      "\x1b[D",
+	"home":      "\x1b[H",
+	"end":       "\x1b[F",
+	"delete":    "\x1b[3~",
+	"pageup":    "\x1b[5~",
+	"pagedown":  "\x1b[6~",
+}
+
+// KeySequence returns the input bytes for a named key such as "up", "esc" or "ctrl+c".
+func KeySequence(name string) ([]byte, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if seq, ok := keySequences[name]; ok {
+		return []byte(seq), true
+	}
+	if letter, ok := strings.CutPrefix(name, "ctrl+"); ok && len(letter) == 1 && letter[0] >= 'a' && letter[0] <= 'z' {
+		return []byte{letter[0] - 'a' + 1}, true
+	}
+	return nil, false
+}
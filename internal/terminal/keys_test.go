@@ -0,0 +1,25 @@
+package terminal
+
+import "testing"
+
+// TestKeySequence_NamedAndControlKeys verifies named keys and ctrl combos map to xterm input bytes.
+func TestKeySequence_NamedAndControlKeys(t *testing.T) {
+	cases := map[string]string{
+		"up":     "\x1b[A",
+		"Esc":    "\x1b",
+		"ctrl+c": "\x03",
+		"CTRL+D": "\x04",
+	}
+	for name, want := range cases {
+		got, ok := KeySequence(name)
+		if !ok || string(got) != want {
+			t.Fatalf("%s: expected %q, got %q ok=%t", name, want, got, ok)
+		}
+	}
+	if _, ok := KeySequence("ctrl+1"); ok {
+		t.Fatalf("expected ctrl+1 to be rejected")
+	}
+	if _, ok := KeySequence("f13"); ok {
+		t.Fatalf("expected unknown key to be rejected")
+	}
+}
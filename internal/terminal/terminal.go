@@ -0,0 +1,233 @@
+// Package terminal streams a PTY-hosted command to the browser over WebSocket.
+package terminal
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/frudas24/deskslice/internal/pty"
+	"github.com/gorilla/websocket"
+)
+
+// maxBacklog caps the output replayed to a newly connected viewer.
+const maxBacklog = 64 << 10
+
+// writeTimeout bounds one write to the viewer.
+const writeTimeout = 5 * time.Second
+
+// ErrNotRunning is returned when input arrives while no command is running.
+var ErrNotRunning = errors.New("terminal: command not running")
+
+// Server owns a PTY-hosted command and streams its raw output (xterm escape sequences)
+// to a single websocket viewer. Output is kept in a bounded backlog so reconnecting
+// viewers can repaint the screen.
+type Server struct {
+	mu       sync.Mutex
+	writeMu  sync.Mutex
+	upgrader websocket.Upgrader
+	command  []string
+	authFn   func() bool
+	pty      *pty.PTY
+	conn     *websocket.Conn
+	backlog  []byte
+	cols     int
+	rows     int
+}
+
+// clientMessage is a terminal websocket payload sent by the viewer.
+type clientMessage struct {
+	T    string `json:"t"`
+	Cols int    `json:"cols,omitempty"`
+	Rows int    `json:"rows,omitempty"`
+}
+
+// NewServer creates a terminal server for a whitespace-separated command line.
+func NewServer(command string, authFn func() bool) *Server {
+	return &Server{
+		command: strings.Fields(command),
+		authFn:  authFn,
+		cols:    pty.DefaultCols,
+		rows:    pty.DefaultRows,
+		upgrader: websocket.Upgrader{
+			ReadBufferSize:  4096,
+			WriteBufferSize: 4096,
+			CheckOrigin:     func(*http.Request) bool { return true },
+		},
+	}
+}
+
+// Start spawns the configured command unless it is already running.
+func (s *Server) Start() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pty != nil {
+		return nil
+	}
+	if len(s.command) == 0 {
+		return errors.New("terminal: no command configured")
+	}
+	p, err := pty.Start(s.command[0], s.command[1:], s.cols, s.rows)
+	if err != nil {
+		return err
+	}
+	s.pty = p
+	s.backlog = nil
+	go s.readLoop(p)
+	return nil
+}
+
+// Stop kills the running command, if any.
+func (s *Server) Stop() error {
+	s.mu.Lock()
+	p := s.pty
+	s.pty = nil
+	s.mu.Unlock()
+	if p == nil {
+		return nil
+	}
+	return p.Close()
+}
+
+// Running reports whether the command is alive.
+func (s *Server) Running() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.pty != nil
+}
+
+// WriteInput forwards keystrokes to the running command.
+func (s *Server) WriteInput(p []byte) error {
+	s.mu.Lock()
+	term := s.pty
+	s.mu.Unlock()
+	if term == nil {
+		return ErrNotRunning
+	}
+	_, err := term.Write(p)
+	return err
+}
+
+// Resize updates the terminal size used now and for future spawns.
+func (s *Server) Resize(cols, rows int) error {
+	if cols <= 0 || rows <= 0 {
+		return nil
+	}
+	s.mu.Lock()
+	s.cols, s.rows = cols, rows
+	term := s.pty
+	s.mu.Unlock()
+	if term == nil {
+		return nil
+	}
+	return term.Resize(cols, rows)
+}
+
+// ServeHTTP upgrades the connection, replays the backlog and streams live output.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.authFn != nil && !s.authFn() {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	s.acceptConn(conn)
+	defer s.cleanupConn(conn)
+
+	for {
+		var msg clientMessage
+		if err := conn.ReadJSON(&msg); err != nil {
+			return
+		}
+		if msg.T == "resize" {
+			if err := s.Resize(msg.Cols, msg.Rows); err != nil {
+				log.Printf("terminal: resize failed: %v", err)
+			}
+		}
+	}
+}
+
+// acceptConn replaces the active viewer and replays the backlog to the new one. The
+// replay holds writeMu, taken before s.mu is released, so output published meanwhile
+// reaches the viewer after the backlog; closing the old viewer first makes its pending
+// write fail instead of holding writeMu.
+func (s *Server) acceptConn(conn *websocket.Conn) {
+	s.mu.Lock()
+	if s.conn != nil {
+		_ = s.conn.Close()
+	}
+	s.conn = conn
+	backlog := append([]byte(nil), s.backlog...)
+	s.writeMu.Lock()
+	s.mu.Unlock()
+	defer s.writeMu.Unlock()
+	if len(backlog) > 0 {
+		s.writeLocked(conn, backlog)
+	}
+}
+
+// cleanupConn clears the active viewer when it disconnects.
+func (s *Server) cleanupConn(conn *websocket.Conn) {
+	s.mu.Lock()
+	if s.conn == conn {
+		s.conn = nil
+	}
+	s.mu.Unlock()
+	_ = conn.Close()
+}
+
+// readLoop copies command output into the backlog and to the active viewer until the command exits.
+func (s *Server) readLoop(p *pty.PTY) {
+	buf := make([]byte, 8192)
+	for {
+		n, err := p.Read(buf)
+		if n > 0 {
+			s.publish(buf[:n])
+		}
+		if err != nil {
+			break
+		}
+	}
+	s.mu.Lock()
+	if s.pty == p {
+		s.pty = nil
+		s.mu.Unlock()
+		_ = p.Close()
+		s.publish([]byte("\r\n[process exited]\r\n"))
+		return
+	}
+	s.mu.Unlock()
+}
+
+// publish appends output to the backlog and forwards it to the active viewer. The write
+// happens outside s.mu, so a slow viewer does not block the other methods.
+func (s *Server) publish(chunk []byte) {
+	s.mu.Lock()
+	s.backlog = append(s.backlog, chunk...)
+	if over := len(s.backlog) - maxBacklog; over > 0 {
+		s.backlog = append([]byte(nil), s.backlog[over:]...)
+	}
+	conn := s.conn
+	s.mu.Unlock()
+	if conn == nil {
+		return
+	}
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	s.writeLocked(conn, chunk)
+}
+
+// writeLocked writes one binary frame to a viewer. A viewer that cannot take it within
+// writeTimeout is dropped so it does not stall the PTY reader. The caller must hold writeMu.
+func (s *Server) writeLocked(conn *websocket.Conn, data []byte) {
+	_ = conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	if err := conn.WriteMessage(websocket.BinaryMessage, data); err != nil {
+		log.Printf("terminal: write failed: %v", err)
+		_ = conn.Close()
+	}
+}
@@ -0,0 +1,106 @@
+//go:build linux
+
+package terminal
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// TestServer_StreamsOutputAndForwardsInput verifies viewers receive PTY output and input reaches the command.
+func TestServer_StreamsOutputAndForwardsInput(t *testing.T) {
+	srv := NewServer("sh", nil)
+	// The script needs quoting that a whitespace-separated command line cannot express.
+	srv.command = []string{"sh", "-c", `stty -echo; printf ready; read line; printf "got:%s" "$line"`}
+	if err := srv.Start(); err != nil {
+		t.Skipf("pty unavailable: %v", err)
+	}
+	defer srv.Stop()
+
+	httpSrv := httptest.NewServer(srv)
+	defer httpSrv.Close()
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(httpSrv.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	defer conn.Close()
+
+	var out strings.Builder
+	sent := false
+	deadline := time.Now().Add(5 * time.Second)
+	for !strings.Contains(out.String(), "got:hello") {
+		_ = conn.SetReadDeadline(deadline)
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("read failed after %q: %v", out.String(), err)
+		}
+		out.Write(data)
+		if !sent && strings.Contains(out.String(), "ready") {
+			sent = true
+			if err := srv.WriteInput([]byte("hello\r")); err != nil {
+				t.Fatalf("write input failed: %v", err)
+			}
+		}
+	}
+}
+
+// TestServer_WriteInputRequiresRunningCommand verifies input is rejected when nothing is running.
+func TestServer_WriteInputRequiresRunningCommand(t *testing.T) {
+	srv := NewServer("", nil)
+	if err := srv.Start(); err == nil {
+		t.Fatalf("expected error for empty command")
+	}
+	if err := srv.WriteInput([]byte("x")); err != ErrNotRunning {
+		t.Fatalf("expected ErrNotRunning, got %v", err)
+	}
+}
+
+// TestServer_PublishDoesNotBlockWhileWriting verifies a viewer write in progress does not
+// hold the server lock, so the other methods stay responsive.
+func TestServer_PublishDoesNotBlockWhileWriting(t *testing.T) {
+	srv := NewServer("sh", nil)
+	httpSrv := httptest.NewServer(srv)
+	defer httpSrv.Close()
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(httpSrv.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	defer conn.Close()
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(5 * time.Millisecond) {
+		srv.mu.Lock()
+		connected := srv.conn != nil
+		srv.mu.Unlock()
+		if connected {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("viewer not registered")
+		}
+	}
+
+	// Holding writeMu stands in for a write stuck on a slow viewer.
+	srv.writeMu.Lock()
+	published := make(chan struct{})
+	go func() {
+		srv.publish([]byte("output"))
+		close(published)
+	}()
+	time.Sleep(50 * time.Millisecond) // let publish reach the write
+	done := make(chan struct{})
+	go func() {
+		_ = srv.Resize(100, 30)
+		_ = srv.Running()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("server methods blocked behind a viewer write")
+	}
+	srv.writeMu.Unlock()
+	<-published
+}
@@ -0,0 +1,232 @@
+// Package tiles streams preview frames over WebSocket as JPEG tiles that changed since the
+// previous frame, so a mostly static panel costs almost no bandwidth.
+package tiles
+
+import (
+	"encoding/binary"
+	"hash/maphash"
+	"net/http"
+	"sync"
+
+	"github.com/frudas24/deskslice/internal/mjpeg"
+	"github.com/gorilla/websocket"
+)
+
+// Size is the edge of a square tile in pixels; edge tiles are clipped to the frame.
+const Size = 64
+
+// Binary message types. Every message starts with its type and the frame width and height
+// as big-endian uint16s; a tile adds x, y, w, h (uint16) followed by the JPEG bytes.
+const (
+	// MsgTile carries one changed tile.
+	MsgTile byte = 1
+	// MsgFrameEnd marks the end of the tiles belonging to one frame.
+	MsgFrameEnd byte = 2
+)
+
+// sendQueue is how many frame batches may wait for a slow viewer before it is resynced.
+const sendQueue = 4
+
+// Server keeps per-tile hashes of the last published frame and sends viewers only the tiles
+// that changed. A viewer that falls behind is sent the full cached frame once it catches up.
+type Server struct {
+	mu       sync.Mutex
+	upgrader websocket.Upgrader
+	authFn   func() bool
+	seed     maphash.Seed
+	subs     map[*viewer]struct{}
+	w        int
+	h        int
+	hashes   []uint64
+	cache    [][]byte
+
+	// pubMu serializes Publish, the only writer of w, h, hashes and cache, so it can read
+	// them and encode without holding mu. scratch is only used under pubMu.
+	pubMu   sync.Mutex
+	scratch []byte
+}
+
+// viewer is one connected WebSocket client.
+type viewer struct {
+	conn   *websocket.Conn
+	send   chan [][]byte
+	resync bool
+}
+
+// NewServer returns a tile server; authFn gates the WebSocket upgrade when set.
+func NewServer(authFn func() bool) *Server {
+	return &Server{
+		authFn: authFn,
+		seed:   maphash.MakeSeed(),
+		subs:   make(map[*viewer]struct{}),
+		upgrader: websocket.Upgrader{
+			ReadBufferSize:  1024,
+			WriteBufferSize: 64 << 10,
+			CheckOrigin:     func(*http.Request) bool { return true },
+		},
+	}
+}
+
+// HasSubscribers reports whether any viewer is connected.
+func (s *Server) HasSubscribers() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.subs) > 0
+}
+
+// Publish diffs a packed RGB24 frame against the previous one and sends the changed tiles,
+// encoded at the given JPEG quality, to every viewer. Frames are ignored while nobody watches.
+// Hashing and encoding run without mu, so viewers joining or leaving and HasSubscribers
+// never wait for a frame to be encoded.
+func (s *Server) Publish(rgb []byte, w, h, quality int) {
+	s.pubMu.Lock()
+	defer s.pubMu.Unlock()
+	s.mu.Lock()
+	if len(s.subs) == 0 {
+		// Forget the cache so the next viewer starts from a freshly encoded frame.
+		s.w, s.h, s.hashes, s.cache = 0, 0, nil, nil
+		s.mu.Unlock()
+		return
+	}
+	s.mu.Unlock()
+
+	// Viewers read the cache under mu while this frame is encoded, so fill copies.
+	cols, rows := (w+Size-1)/Size, (h+Size-1)/Size
+	hashes, cache := make([]uint64, cols*rows), make([][]byte, cols*rows)
+	if w == s.w && h == s.h {
+		copy(hashes, s.hashes)
+		copy(cache, s.cache)
+	}
+	var batch [][]byte
+	for row := 0; row < rows; row++ {
+		for col := 0; col < cols; col++ {
+			x, y := col*Size, row*Size
+			tw, th := min(Size, w-x), min(Size, h-y)
+			s.scratch = cutTile(s.scratch, rgb, w, x, y, tw, th)
+			sum := maphash.Bytes(s.seed, s.scratch)
+			i := row*cols + col
+			if cache[i] != nil && hashes[i] == sum {
+				continue
+			}
+			hashes[i] = sum
+			cache[i] = tileMessage(w, h, x, y, tw, th, mjpeg.EncodeRGBToJPEG(s.scratch, tw, th, quality))
+			batch = append(batch, cache[i])
+		}
+	}
+	if len(batch) == 0 {
+		return
+	}
+	batch = append(batch, frameEndMessage(w, h))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.w, s.h, s.hashes, s.cache = w, h, hashes, cache
+	for v := range s.subs {
+		if v.resync {
+			s.enqueueFull(v)
+			continue
+		}
+		select {
+		case v.send <- batch:
+		default:
+			v.resync = true
+		}
+	}
+}
+
+// ServeHTTP upgrades the connection, sends the cached frame and streams tile updates.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.authFn != nil && !s.authFn() {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	v := &viewer{conn: conn, send: make(chan [][]byte, sendQueue)}
+	s.mu.Lock()
+	s.subs[v] = struct{}{}
+	s.enqueueFull(v)
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go s.writeLoop(v, done)
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			break
+		}
+	}
+	s.mu.Lock()
+	delete(s.subs, v)
+	s.mu.Unlock()
+	close(done)
+	_ = conn.Close()
+}
+
+// enqueueFull queues every cached tile for a viewer that joined or fell behind.
+func (s *Server) enqueueFull(v *viewer) {
+	var batch [][]byte
+	for _, msg := range s.cache {
+		if msg != nil {
+			batch = append(batch, msg)
+		}
+	}
+	if len(batch) == 0 {
+		v.resync = false
+		return
+	}
+	batch = append(batch, frameEndMessage(s.w, s.h))
+	select {
+	case v.send <- batch:
+		v.resync = false
+	default:
+		v.resync = true
+	}
+}
+
+// writeLoop writes queued batches to the viewer until it disconnects.
+func (s *Server) writeLoop(v *viewer, done <-chan struct{}) {
+	for {
+		select {
+		case <-done:
+			return
+		case batch := <-v.send:
+			for _, msg := range batch {
+				if err := v.conn.WriteMessage(websocket.BinaryMessage, msg); err != nil {
+					_ = v.conn.Close()
+					return
+				}
+			}
+		}
+	}
+}
+
+// cutTile copies a tile out of a packed RGB24 frame of the given width into dst.
+func cutTile(dst, rgb []byte, width, x, y, w, h int) []byte {
+	dst = dst[:0]
+	for row := y; row < y+h; row++ {
+		start := (row*width + x) * 3
+		dst = append(dst, rgb[start:start+w*3]...)
+	}
+	return dst
+}
+
+// tileMessage builds a MsgTile payload.
+func tileMessage(frameW, frameH, x, y, w, h int, jpg []byte) []byte {
+	msg := make([]byte, 13, 13+len(jpg))
+	msg[0] = MsgTile
+	for i, v := range []int{frameW, frameH, x, y, w, h} {
+		binary.BigEndian.PutUint16(msg[1+2*i:], uint16(v))
+	}
+	return append(msg, jpg...)
+}
+
+// frameEndMessage builds a MsgFrameEnd payload.
+func frameEndMessage(frameW, frameH int) []byte {
+	msg := make([]byte, 5)
+	msg[0] = MsgFrameEnd
+	binary.BigEndian.PutUint16(msg[1:], uint16(frameW))
+	binary.BigEndian.PutUint16(msg[3:], uint16(frameH))
+	return msg
+}
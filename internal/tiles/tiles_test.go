@@ -0,0 +1,139 @@
+package tiles
+
+import (
+	"encoding/binary"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// TestServer_SendsOnlyChangedTiles verifies a viewer gets the whole frame first, nothing for a
+// repeated frame, and only the changed tile afterwards.
+func TestServer_SendsOnlyChangedTiles(t *testing.T) {
+	srv := NewServer(nil)
+	httpSrv := httptest.NewServer(srv)
+	defer httpSrv.Close()
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(httpSrv.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	defer conn.Close()
+	waitSubscribed(t, srv)
+
+	const w, h = 2 * Size, Size
+	rgb := make([]byte, w*h*3)
+	srv.Publish(rgb, w, h, 80)
+	if got := readFrame(t, conn); len(got) != 2 || got[0] != [2]int{0, 0} || got[1] != [2]int{Size, 0} {
+		t.Fatalf("first frame tiles = %v, want both tiles", got)
+	}
+
+	srv.Publish(rgb, w, h, 80)
+	rgb[(10*w+Size+5)*3] = 0xFF
+	srv.Publish(rgb, w, h, 80)
+	if got := readFrame(t, conn); len(got) != 1 || got[0] != [2]int{Size, 0} {
+		t.Fatalf("delta frame tiles = %v, want only the right tile", got)
+	}
+}
+
+// TestServer_JoinDuringPublish verifies a viewer joining while frames are encoded still
+// starts from the whole frame.
+func TestServer_JoinDuringPublish(t *testing.T) {
+	srv := NewServer(nil)
+	httpSrv := httptest.NewServer(srv)
+	defer httpSrv.Close()
+	url := "ws" + strings.TrimPrefix(httpSrv.URL, "http")
+	first, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	defer first.Close()
+	waitSubscribed(t, srv)
+
+	const w, h = 2 * Size, Size
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		rgb := make([]byte, w*h*3)
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			rgb[0] = byte(i)
+			srv.Publish(rgb, w, h, 80)
+		}
+	}()
+	second, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	defer second.Close()
+	got := readFrame(t, second)
+	close(stop)
+	<-done
+	if len(got) != 2 {
+		t.Fatalf("joining viewer got tiles %v, want the whole frame", got)
+	}
+}
+
+// TestServer_RejectsUnauthorized verifies the upgrade is refused when authFn fails.
+func TestServer_RejectsUnauthorized(t *testing.T) {
+	srv := NewServer(func() bool { return false })
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ws/tiles", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %d", rec.Code)
+	}
+}
+
+// TestCutTile_ClipsRows verifies a tile is copied row by row from the packed frame.
+func TestCutTile_ClipsRows(t *testing.T) {
+	rgb := make([]byte, 4*2*3)
+	for i := range rgb {
+		rgb[i] = byte(i)
+	}
+	got := cutTile(nil, rgb, 4, 1, 0, 2, 2)
+	want := []byte{3, 4, 5, 6, 7, 8, 15, 16, 17, 18, 19, 20}
+	if string(got) != string(want) {
+		t.Fatalf("cutTile = %v, want %v", got, want)
+	}
+}
+
+// waitSubscribed blocks until the server has registered the test viewer.
+func waitSubscribed(t *testing.T, srv *Server) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !srv.HasSubscribers() {
+		if time.Now().After(deadline) {
+			t.Fatalf("viewer never subscribed")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// readFrame reads messages up to a frame end and returns the tile origins it carried.
+func readFrame(t *testing.T, conn *websocket.Conn) [][2]int {
+	t.Helper()
+	var origins [][2]int
+	for {
+		_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		_, msg, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("read failed: %v", err)
+		}
+		switch msg[0] {
+		case MsgFrameEnd:
+			return origins
+		case MsgTile:
+			origins = append(origins, [2]int{int(binary.BigEndian.Uint16(msg[5:])), int(binary.BigEndian.Uint16(msg[7:]))})
+		default:
+			t.Fatalf("unexpected message type %d", msg[0])
+		}
+	}
+}
@@ -0,0 +1,417 @@
+// Package timeline keeps a ring buffer of periodic panel snapshots on disk, each with a
+// low-res thumbnail, so a returning viewer can scroll back through what the panel showed.
+package timeline
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/frudas24/deskslice/internal/mjpeg"
+)
+
+// Reasons recorded for a sample.
+const (
+	// ReasonInterval marks a sample taken because the interval elapsed.
+	ReasonInterval = "interval"
+	// ReasonChange marks a sample taken because the picture changed a lot.
+	ReasonChange = "change"
+)
+
+const (
+	// ThumbWidth is the width thumbnails are scaled down to.
+	ThumbWidth = 320
+	// minChangeGap rate-limits change samples so a playing video cannot flush the ring.
+	minChangeGap = 5 * time.Second
+	// sigCols and sigRows size the luma grid compared to detect large changes.
+	sigCols, sigRows = 32, 18
+	// indexFile lists the retained entries inside the timeline directory.
+	indexFile = "index.json"
+	// fullQuality and thumbQuality are the JPEG qualities of stored images.
+	fullQuality, thumbQuality = 85, 70
+)
+
+// ErrNotFound is returned for an unknown entry id.
+var ErrNotFound = errors.New("timeline: entry not found")
+
+// Entry describes one stored sample.
+type Entry struct {
+	ID     int64     `json:"id"`
+	Time   time.Time `json:"time"`
+	Reason string    `json:"reason"`
+	Width  int       `json:"width"`
+	Height int       `json:"height"`
+}
+
+// Timeline samples frames into a bounded on-disk ring. Frames are offered with Observe
+// from the preview pipeline; when nothing was offered for a whole interval, the capture
+// function passed to Start is used instead.
+type Timeline struct {
+	mu        sync.Mutex
+	dir       string
+	max       int
+	interval  time.Duration
+	changePct int
+	entries   []Entry
+	lastID    int64
+	indexSeq  uint64
+	lastAt    time.Time
+	lastSig   []byte
+	saving    bool
+	cancel    context.CancelFunc
+
+	// indexMu serializes index writes; indexWritten is the indexSeq last persisted.
+	indexMu      sync.Mutex
+	indexWritten uint64
+}
+
+// New opens the timeline stored in dir, keeping at most size entries sampled every
+// interval. changePct (0 disables) is the mean luma change, in percent, that triggers an
+// extra sample. Entries whose files went missing are dropped.
+func New(dir string, size int, interval time.Duration, changePct int) (*Timeline, error) {
+	if size <= 0 || interval <= 0 {
+		return nil, fmt.Errorf("timeline: size and interval must be positive")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	t := &Timeline{dir: dir, max: size, interval: interval, changePct: changePct}
+	data, err := os.ReadFile(filepath.Join(dir, indexFile))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if len(data) > 0 {
+		var entries []Entry
+		if err := json.Unmarshal(data, &entries); err != nil {
+			return nil, fmt.Errorf("timeline: read index: %w", err)
+		}
+		for _, e := range entries {
+			if _, err := os.Stat(t.path(e.ID, false)); err == nil {
+				t.entries = append(t.entries, e)
+			}
+		}
+	}
+	if n := len(t.entries); n > 0 {
+		t.lastID = t.entries[n-1].ID
+	}
+	t.removeFiles(t.trimLocked())
+	return t, nil
+}
+
+// Start launches the fallback sampler, which captures a frame whenever the preview did not
+// offer one for a whole interval (for example while only WebRTC runs).
+func (t *Timeline) Start(capture func(context.Context) (image.Image, error)) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.mu.Lock()
+	t.cancel = cancel
+	t.mu.Unlock()
+	go t.run(ctx, capture)
+}
+
+// Close stops the fallback sampler without waiting for an in-flight capture.
+func (t *Timeline) Close() {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.cancel != nil {
+		t.cancel()
+		t.cancel = nil
+	}
+}
+
+// run captures a frame each interval in which nothing else was sampled.
+func (t *Timeline) run(ctx context.Context, capture func(context.Context) (image.Image, error)) {
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if !t.begin(now) {
+				continue
+			}
+			captureCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+			img, err := capture(captureCtx)
+			cancel()
+			if err != nil {
+				t.finish()
+				if ctx.Err() == nil {
+					log.Printf("timeline: capture failed: %v", err)
+				}
+				continue
+			}
+			t.save(img, ReasonInterval, now)
+		}
+	}
+}
+
+// begin claims the sampler for an interval sample, reporting false when one is not due.
+func (t *Timeline) begin(now time.Time) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.saving || now.Sub(t.lastAt) < t.interval {
+		return false
+	}
+	t.saving = true
+	t.lastAt = now
+	return true
+}
+
+// finish releases the sampler after a failed capture.
+func (t *Timeline) finish() {
+	t.mu.Lock()
+	t.saving = false
+	t.mu.Unlock()
+}
+
+// Observe offers a packed RGB24 frame. It is cheap when no sample is due; a due frame is
+// copied and stored in the background so the caller's buffer can be reused.
+func (t *Timeline) Observe(rgb []byte, w, h int) {
+	if t == nil {
+		return
+	}
+	now := time.Now()
+	t.mu.Lock()
+	if t.saving {
+		t.mu.Unlock()
+		return
+	}
+	reason := ""
+	if now.Sub(t.lastAt) >= t.interval {
+		reason = ReasonInterval
+	} else if t.changePct > 0 && t.lastSig != nil && now.Sub(t.lastAt) >= minChangeGap {
+		if changePercent(t.lastSig, rgbSignature(rgb, w, h)) >= t.changePct {
+			reason = ReasonChange
+		}
+	}
+	if reason == "" {
+		t.mu.Unlock()
+		return
+	}
+	t.saving = true
+	t.lastAt = now
+	t.mu.Unlock()
+	img := mjpeg.RGBToImage(rgb, w, h)
+	go t.save(img, reason, now)
+}
+
+// Add stores an image immediately, regardless of the interval.
+func (t *Timeline) Add(img image.Image, reason string) error {
+	t.mu.Lock()
+	t.saving = true
+	t.lastAt = time.Now()
+	at := t.lastAt
+	t.mu.Unlock()
+	return t.save(img, reason, at)
+}
+
+// save writes the full image and its thumbnail, appends the entry and trims the ring.
+// t.mu is held only to reserve the id and to update the entry list; encoding and file
+// writes run unlocked so Observe never waits on the disk.
+func (t *Timeline) save(img image.Image, reason string, at time.Time) error {
+	rgba := toRGBA(img)
+	b := rgba.Bounds()
+	thumbH := max(1, b.Dy()*ThumbWidth/max(1, b.Dx()))
+	thumb := rgba
+	if b.Dx() > ThumbWidth {
+		thumb = mjpeg.ScaleImage(rgba, ThumbWidth, thumbH)
+	}
+	sig := imageSignature(rgba)
+
+	t.mu.Lock()
+	id := max(at.UnixMilli(), t.lastID+1)
+	t.lastID = id
+	t.mu.Unlock()
+
+	if err := writeJPEG(t.path(id, false), rgba, fullQuality); err != nil {
+		t.finish()
+		log.Printf("timeline: %v", err)
+		return err
+	}
+	if err := writeJPEG(t.path(id, true), thumb, thumbQuality); err != nil {
+		_ = os.Remove(t.path(id, false))
+		t.finish()
+		log.Printf("timeline: %v", err)
+		return err
+	}
+
+	t.mu.Lock()
+	e := Entry{ID: id, Time: at, Reason: reason, Width: b.Dx(), Height: b.Dy()}
+	i := sort.Search(len(t.entries), func(i int) bool { return t.entries[i].ID > id })
+	t.entries = append(t.entries, Entry{})
+	copy(t.entries[i+1:], t.entries[i:])
+	t.entries[i] = e
+	t.lastSig = sig
+	t.saving = false
+	dropped := t.trimLocked()
+	t.indexSeq++
+	seq := t.indexSeq
+	data, err := json.Marshal(t.entries)
+	t.mu.Unlock()
+
+	t.removeFiles(dropped)
+	if err == nil {
+		err = t.writeIndex(seq, data)
+	}
+	if err != nil {
+		log.Printf("timeline: %v", err)
+		return err
+	}
+	return nil
+}
+
+// Entries returns the retained entries, oldest first.
+func (t *Timeline) Entries() []Entry {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]Entry(nil), t.entries...)
+}
+
+// Interval returns the sampling interval.
+func (t *Timeline) Interval() time.Duration {
+	return t.interval
+}
+
+// Path returns the JPEG file of an entry, or of its thumbnail.
+func (t *Timeline) Path(id int64, thumb bool) (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, e := range t.entries {
+		if e.ID == id {
+			return t.path(id, thumb), nil
+		}
+	}
+	return "", ErrNotFound
+}
+
+// path returns where an entry's image is stored.
+func (t *Timeline) path(id int64, thumb bool) string {
+	if thumb {
+		return filepath.Join(t.dir, fmt.Sprintf("%d_thumb.jpg", id))
+	}
+	return filepath.Join(t.dir, fmt.Sprintf("%d.jpg", id))
+}
+
+// trimLocked drops the oldest entries beyond the ring size and returns them so their
+// files can be removed after unlocking.
+func (t *Timeline) trimLocked() []Entry {
+	n := len(t.entries) - t.max
+	if n <= 0 {
+		return nil
+	}
+	dropped := append([]Entry(nil), t.entries[:n]...)
+	t.entries = t.entries[n:]
+	return dropped
+}
+
+// removeFiles deletes the images of dropped entries.
+func (t *Timeline) removeFiles(entries []Entry) {
+	for _, e := range entries {
+		_ = os.Remove(t.path(e.ID, false))
+		_ = os.Remove(t.path(e.ID, true))
+	}
+}
+
+// writeIndex persists an entry list snapshot, skipping it when a newer one was already
+// written by a concurrent save.
+func (t *Timeline) writeIndex(seq uint64, data []byte) error {
+	t.indexMu.Lock()
+	defer t.indexMu.Unlock()
+	if seq <= t.indexWritten {
+		return nil
+	}
+	t.indexWritten = seq
+	tmp := filepath.Join(t.dir, indexFile+".tmp")
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(t.dir, indexFile))
+}
+
+// writeJPEG encodes an image to a file.
+func writeJPEG(path string, img image.Image, quality int) error {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), 0o600)
+}
+
+// toRGBA returns img as an RGBA image anchored at the origin.
+func toRGBA(img image.Image) *image.RGBA {
+	if rgba, ok := img.(*image.RGBA); ok && rgba.Rect.Min == (image.Point{}) {
+		return rgba
+	}
+	b := img.Bounds()
+	out := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(out, out.Rect, img, b.Min, draw.Src)
+	return out
+}
+
+// rgbSignature samples a coarse luma grid from a packed RGB24 frame.
+func rgbSignature(rgb []byte, w, h int) []byte {
+	sig := make([]byte, 0, sigCols*sigRows)
+	for row := 0; row < sigRows; row++ {
+		y := (2*row + 1) * h / (2 * sigRows)
+		for col := 0; col < sigCols; col++ {
+			x := (2*col + 1) * w / (2 * sigCols)
+			i := (y*w + x) * 3
+			if i+2 >= len(rgb) {
+				sig = append(sig, 0)
+				continue
+			}
+			sig = append(sig, luma(rgb[i], rgb[i+1], rgb[i+2]))
+		}
+	}
+	return sig
+}
+
+// imageSignature samples the same luma grid as rgbSignature from an RGBA image.
+func imageSignature(img *image.RGBA) []byte {
+	b := img.Bounds()
+	sig := make([]byte, 0, sigCols*sigRows)
+	for row := 0; row < sigRows; row++ {
+		y := (2*row + 1) * b.Dy() / (2 * sigRows)
+		for col := 0; col < sigCols; col++ {
+			x := (2*col + 1) * b.Dx() / (2 * sigCols)
+			i := img.PixOffset(b.Min.X+x, b.Min.Y+y)
+			sig = append(sig, luma(img.Pix[i], img.Pix[i+1], img.Pix[i+2]))
+		}
+	}
+	return sig
+}
+
+// luma approximates BT.601 luma with integer weights.
+func luma(r, g, b byte) byte {
+	return byte((299*int(r) + 587*int(g) + 114*int(b)) / 1000)
+}
+
+// changePercent returns the mean absolute difference of two signatures in percent of full scale.
+func changePercent(a, b []byte) int {
+	if len(a) != len(b) || len(a) == 0 {
+		return 100
+	}
+	total := 0
+	for i := range a {
+		d := int(a[i]) - int(b[i])
+		if d < 0 {
+			d = -d
+		}
+		total += d
+	}
+	return total * 100 / (255 * len(a))
+}
@@ -0,0 +1,146 @@
+package timeline
+
+import (
+	"image"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+// solidImage returns a w x h image filled with one gray level.
+func solidImage(w, h int, level uint8) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = level, level, level, 255
+	}
+	return img
+}
+
+// TestTimeline_RingTrimsAndPersists verifies old entries and their files are dropped and the
+// index survives a reopen.
+func TestTimeline_RingTrimsAndPersists(t *testing.T) {
+	dir := t.TempDir()
+	tl, err := New(dir, 2, time.Minute, 0)
+	if err != nil {
+		t.Fatalf("new failed: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := tl.Add(solidImage(640, 360, uint8(i*50)), ReasonInterval); err != nil {
+			t.Fatalf("add %d failed: %v", i, err)
+		}
+	}
+	entries := tl.Entries()
+	if len(entries) != 2 || entries[0].ID >= entries[1].ID {
+		t.Fatalf("unexpected entries: %+v", entries)
+	}
+	if entries[0].Width != 640 || entries[0].Height != 360 {
+		t.Fatalf("unexpected size: %+v", entries[0])
+	}
+	files, _ := os.ReadDir(dir)
+	if len(files) != 5 { // two images, two thumbnails, the index
+		t.Fatalf("expected 5 files, got %d", len(files))
+	}
+
+	thumb, err := tl.Path(entries[1].ID, true)
+	if err != nil {
+		t.Fatalf("path failed: %v", err)
+	}
+	f, err := os.Open(thumb)
+	if err != nil {
+		t.Fatalf("open thumb: %v", err)
+	}
+	cfg, _, err := image.DecodeConfig(f)
+	_ = f.Close()
+	if err != nil || cfg.Width != ThumbWidth || cfg.Height != 180 {
+		t.Fatalf("thumbnail is %dx%d (%v), want %dx180", cfg.Width, cfg.Height, err, ThumbWidth)
+	}
+
+	reopened, err := New(dir, 2, time.Minute, 0)
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	if got := reopened.Entries(); len(got) != 2 || got[1].ID != entries[1].ID {
+		t.Fatalf("reopened entries = %+v", got)
+	}
+	if _, err := reopened.Path(12345, false); err != ErrNotFound {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+// TestTimeline_ObserveSamplesOncePerInterval verifies the first offered frame is stored and
+// the following ones are ignored until the interval elapses.
+func TestTimeline_ObserveSamplesOncePerInterval(t *testing.T) {
+	tl, err := New(t.TempDir(), 10, time.Hour, 0)
+	if err != nil {
+		t.Fatalf("new failed: %v", err)
+	}
+	rgb := make([]byte, 64*32*3)
+	for i := 0; i < 5; i++ {
+		tl.Observe(rgb, 64, 32)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for len(tl.Entries()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if got := tl.Entries(); len(got) != 1 || got[0].Reason != ReasonInterval {
+		t.Fatalf("expected one interval sample, got %+v", got)
+	}
+}
+
+// TestTimeline_ConcurrentSavesStayOrdered verifies saves writing their files in parallel
+// still leave a sorted, trimmed ring and an index that matches it.
+func TestTimeline_ConcurrentSavesStayOrdered(t *testing.T) {
+	dir := t.TempDir()
+	tl, err := New(dir, 4, time.Minute, 0)
+	if err != nil {
+		t.Fatalf("new failed: %v", err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := tl.Add(solidImage(64, 32, uint8(i*30)), ReasonChange); err != nil {
+				t.Errorf("add %d failed: %v", i, err)
+			}
+		}()
+	}
+	wg.Wait()
+	entries := tl.Entries()
+	if len(entries) != 4 {
+		t.Fatalf("expected 4 entries, got %d", len(entries))
+	}
+	for i := 1; i < len(entries); i++ {
+		if entries[i-1].ID >= entries[i].ID {
+			t.Fatalf("entries out of order: %+v", entries)
+		}
+	}
+	reopened, err := New(dir, 4, time.Minute, 0)
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	if got := reopened.Entries(); len(got) != 4 || got[3].ID != entries[3].ID {
+		t.Fatalf("reopened entries = %+v, want %+v", got, entries)
+	}
+}
+
+// TestChangePercent verifies signatures of different pictures differ by the expected amount.
+func TestChangePercent(t *testing.T) {
+	black := imageSignature(solidImage(64, 36, 0))
+	white := imageSignature(solidImage(64, 36, 255))
+	if got := changePercent(black, black); got != 0 {
+		t.Fatalf("identical signatures differ by %d%%", got)
+	}
+	if got := changePercent(black, white); got != 100 {
+		t.Fatalf("black vs white = %d%%, want 100", got)
+	}
+	rgb := make([]byte, 64*36*3)
+	for i := range rgb {
+		rgb[i] = 255
+	}
+	if got := changePercent(white, rgbSignature(rgb, 64, 36)); got != 0 {
+		t.Fatalf("rgb and image signatures of the same picture differ by %d%%", got)
+	}
+}
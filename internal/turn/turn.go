@@ -0,0 +1,123 @@
+// Package turn runs an embedded TURN relay with short-lived credentials.
+package turn
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/pion/turn/v2"
+)
+
+const (
+	// Realm is the TURN realm advertised by the embedded relay.
+	Realm = "deskslice"
+	// CredentialTTL bounds how long issued credentials stay valid.
+	CredentialTTL = time.Hour
+)
+
+// Config describes where the relay listens and which address it advertises.
+type Config struct {
+	// Port is used for both UDP and TCP listeners.
+	Port int
+	// PublicIP is the address clients reach the relay on (e.g. the VPN IP).
+	PublicIP string
+}
+
+// Credentials are time-windowed TURN credentials for one signaling session.
+type Credentials struct {
+	URLs       []string
+	Username   string
+	Credential string
+}
+
+// Server is a running embedded TURN relay.
+type Server struct {
+	server *turn.Server
+	secret string
+	urls   []string
+}
+
+// Start binds the relay on cfg.Port (UDP and TCP) and begins serving allocations.
+func Start(cfg Config) (*Server, error) {
+	if cfg.Port <= 0 || cfg.Port > 65535 {
+		return nil, fmt.Errorf("turn port out of range: %d", cfg.Port)
+	}
+	publicIP := net.ParseIP(cfg.PublicIP)
+	if publicIP == nil {
+		return nil, fmt.Errorf("turn public ip invalid: %q", cfg.PublicIP)
+	}
+
+	secret, err := newSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	addr := net.JoinHostPort("0.0.0.0", strconv.Itoa(cfg.Port))
+	udpConn, err := net.ListenPacket("udp4", addr)
+	if err != nil {
+		return nil, fmt.Errorf("turn udp listen: %w", err)
+	}
+	tcpListener, err := net.Listen("tcp4", addr)
+	if err != nil {
+		_ = udpConn.Close()
+		return nil, fmt.Errorf("turn tcp listen: %w", err)
+	}
+
+	relay := &turn.RelayAddressGeneratorStatic{RelayAddress: publicIP, Address: "0.0.0.0"}
+	server, err := turn.NewServer(turn.ServerConfig{
+		Realm:             Realm,
+		AuthHandler:       turn.NewLongTermAuthHandler(secret, nil),
+		PacketConnConfigs: []turn.PacketConnConfig{{PacketConn: udpConn, RelayAddressGenerator: relay}},
+		ListenerConfigs:   []turn.ListenerConfig{{Listener: tcpListener, RelayAddressGenerator: relay}},
+	})
+	if err != nil {
+		_ = udpConn.Close()
+		_ = tcpListener.Close()
+		return nil, fmt.Errorf("turn server: %w", err)
+	}
+
+	host := net.JoinHostPort(publicIP.String(), strconv.Itoa(cfg.Port))
+	return &Server{
+		server: server,
+		secret: secret,
+		urls: []string{
+			"turn:" + host + "?transport=udp",
+			"turn:" + host + "?transport=tcp",
+		},
+	}, nil
+}
+
+// Credentials issues a fresh username/password pair valid for CredentialTTL.
+func (s *Server) Credentials() (Credentials, error) {
+	if s == nil {
+		return Credentials{}, errors.New("turn server not running")
+	}
+	username, password, err := turn.GenerateLongTermCredentials(s.secret, CredentialTTL)
+	if err != nil {
+		return Credentials{}, err
+	}
+	urls := append([]string(nil), s.urls...)
+	return Credentials{URLs: urls, Username: username, Credential: password}, nil
+}
+
+// Close stops the relay and releases its sockets.
+func (s *Server) Close() error {
+	if s == nil {
+		return nil
+	}
+	return s.server.Close()
+}
+
+// newSecret returns a random shared secret used to sign credentials for this process.
+func newSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("turn secret: %w", err)
+	}
+	return base64.RawStdEncoding.EncodeToString(buf), nil
+}
@@ -0,0 +1,89 @@
+package turn
+
+import (
+	"net"
+	"strconv"
+	"testing"
+
+	"github.com/pion/turn/v2"
+)
+
+// TestServer_IssuedCredentialsAllocateRelay verifies a client can allocate a relay with issued credentials.
+func TestServer_IssuedCredentialsAllocateRelay(t *testing.T) {
+	srv, port := startTestServer(t)
+	creds, err := srv.Credentials()
+	if err != nil {
+		t.Fatalf("credentials: %v", err)
+	}
+	if len(creds.URLs) != 2 || creds.URLs[0] != "turn:127.0.0.1:"+strconv.Itoa(port)+"?transport=udp" {
+		t.Fatalf("unexpected urls: %v", creds.URLs)
+	}
+
+	relay, err := allocate(t, port, creds.Username, creds.Credential)
+	if err != nil {
+		t.Fatalf("allocate: %v", err)
+	}
+	defer relay.Close()
+	if ip := relay.LocalAddr().(*net.UDPAddr).IP; !ip.Equal(net.IPv4(127, 0, 0, 1)) {
+		t.Fatalf("expected relay on public ip, got %v", ip)
+	}
+}
+
+// TestServer_RejectsForgedCredentials verifies allocations fail without a valid signature.
+func TestServer_RejectsForgedCredentials(t *testing.T) {
+	srv, port := startTestServer(t)
+	creds, err := srv.Credentials()
+	if err != nil {
+		t.Fatalf("credentials: %v", err)
+	}
+	if relay, err := allocate(t, port, creds.Username, "forged"); err == nil {
+		_ = relay.Close()
+		t.Fatalf("expected allocation with forged password to fail")
+	}
+}
+
+// startTestServer starts a relay on a free loopback port and closes it with the test.
+func startTestServer(t *testing.T) (*Server, int) {
+	t.Helper()
+	probe, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("probe port: %v", err)
+	}
+	port := probe.LocalAddr().(*net.UDPAddr).Port
+	_ = probe.Close()
+
+	srv, err := Start(Config{Port: port, PublicIP: "127.0.0.1"})
+	if err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	t.Cleanup(func() { _ = srv.Close() })
+	return srv, port
+}
+
+// allocate requests a UDP relay allocation from the test server.
+func allocate(t *testing.T, port int, username, password string) (net.PacketConn, error) {
+	t.Helper()
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("client socket: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	addr := net.JoinHostPort("127.0.0.1", strconv.Itoa(port))
+	client, err := turn.NewClient(&turn.ClientConfig{
+		STUNServerAddr: addr,
+		TURNServerAddr: addr,
+		Conn:           conn,
+		Username:       username,
+		Password:       password,
+		Realm:          Realm,
+	})
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	t.Cleanup(client.Close)
+	if err := client.Listen(); err != nil {
+		t.Fatalf("client listen: %v", err)
+	}
+	return client.Allocate()
+}
@@ -38,6 +38,50 @@ export async function getMonitors() {
   return res.json();
 }
 
+export async function getHotspots() {
+  const res = await fetch("/api/hotspots");
+  if (!res.ok) {
+    const err = new Error("hotspot fetch failed");
+    err.status = res.status;
+    throw err;
+  }
+  return res.json();
+}
+
+export async function getTimeline() {
+  const res = await fetch("/api/timeline");
+  if (!res.ok) {
+    const err = new Error("timeline fetch failed");
+    err.status = res.status;
+    throw err;
+  }
+  return res.json();
+}
+
+export async function getPush() {
+  const res = await fetch("/api/push");
+  if (!res.ok) {
+    const err = new Error("push fetch failed");
+    err.status = res.status;
+    throw err;
+  }
+  return res.json();
+}
+
+export async function pushAction(action, payload) {
+  const res = await fetch(`/api/push/${action}`, {
+    method: "POST",
+    headers: { "Content-Type": "application/json" },
+    body: JSON.stringify(payload || {}),
+  });
+  if (!res.ok) {
+    const err = new Error(`push ${action} failed`);
+    err.status = res.status;
+    throw err;
+  }
+  return res.json().catch(() => ({}));
+}
+
 export async function updateConfig(payload) {
   const res = await fetch("/api/config", {
     method: "POST",
@@ -51,3 +95,22 @@ export async function updateConfig(payload) {
   }
   return res.json().catch(() => ({}));
 }
+
+// openStateStream follows /api/state/stream, merging each diff into the last full state
+// and passing the result to onState. EventSource reconnects on its own and the server
+// starts every connection with a full state.
+export function openStateStream(onState) {
+  const source = new EventSource("/api/state/stream");
+  let state = null;
+  source.addEventListener("state", (event) => {
+    state = JSON.parse(event.data);
+    onState(state);
+  });
+  source.addEventListener("diff", (event) => {
+    if (!state) return;
+    const diff = JSON.parse(event.data);
+    state = { ...state, ...diff };
+    onState(state);
+  });
+  return source;
+}
@@ -295,7 +295,8 @@ export class Calibrator {
 
   payloadForStep(step, rect) {
     if (!rect || !step) return null;
-    if (step === "plugin") {
+    // Plugin and composite layout tiles are monitor-relative; chat/scroll are plugin-relative.
+    if (step === "plugin" || step === "layout") {
       return { ...rect };
     }
     if (this.rects.plugin) {
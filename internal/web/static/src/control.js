@@ -1,8 +1,67 @@
+// Pointer moves tolerate loss and reordering, so they use the unreliable channel when available.
+const FAST_TYPES = new Set(["move", "relMove"]);
+// Pings are answered over the websocket, so they travel over it too to measure a single path.
+const WS_ONLY_TYPES = new Set(["ping"]);
+const PING_INTERVAL_MS = 2000;
+const MAX_PENDING_PROBES = 50;
+
 export class ControlClient {
   constructor(url) {
     this.url = url;
     this.ws = null;
     this.ready = false;
+    this.fastChannel = null;
+    this.reliableChannel = null;
+    this.measureLatency = false;
+    this.pingTimer = null;
+    this.probeSeq = 0;
+    this.pendingProbes = new Map();
+    this.onActivity = null;
+  }
+
+  setMeasureLatency(enabled) {
+    this.measureLatency = enabled;
+    window.clearInterval(this.pingTimer);
+    this.pingTimer = null;
+    this.pendingProbes.clear();
+    if (enabled) {
+      this.pingTimer = window.setInterval(() => this.send({ t: "ping", ts: performance.now() }), PING_INTERVAL_MS);
+    }
+  }
+
+  markProbe(message) {
+    if (!this.measureLatency) return message;
+    if (this.pendingProbes.size >= MAX_PENDING_PROBES) {
+      this.pendingProbes.clear();
+    }
+    const probe = ++this.probeSeq;
+    this.pendingProbes.set(probe, performance.now());
+    return { ...message, probe };
+  }
+
+  handleEvent(raw) {
+    let msg;
+    try {
+      msg = JSON.parse(raw);
+    } catch (_) {
+      return;
+    }
+    if (msg.t === "pong" && msg.ts) {
+      this.send({ t: "latency", rttMs: performance.now() - msg.ts });
+    }
+    if (msg.t === "probe" && this.pendingProbes.has(msg.probe)) {
+      const sentAt = this.pendingProbes.get(msg.probe);
+      this.pendingProbes.delete(msg.probe);
+      this.send({ t: "latency", inputMs: performance.now() - sentAt });
+    }
+    if (msg.t === "activity") {
+      this.onActivity?.(msg);
+    }
+  }
+
+  setDataChannels(fast, reliable) {
+    this.fastChannel = fast;
+    this.reliableChannel = reliable;
   }
 
   connect() {
@@ -15,21 +74,33 @@ export class ControlClient {
       this.ws.onerror = (err) => {
         reject(err);
       };
+      this.ws.onmessage = (event) => {
+        this.handleEvent(event.data);
+      };
       this.ws.onclose = () => {
         this.ready = false;
+        window.clearInterval(this.pingTimer);
+        this.pingTimer = null;
       };
     });
   }
 
   send(message) {
+    const payload = JSON.stringify(message);
+    const channel = FAST_TYPES.has(message.t) ? this.fastChannel : this.reliableChannel;
+    if (!WS_ONLY_TYPES.has(message.t) && channel?.readyState === "open") {
+      channel.send(payload);
+      return;
+    }
     if (!this.ws || !this.ready) {
       return;
     }
-    this.ws.send(JSON.stringify(message));
+    this.ws.send(payload);
   }
 
   sendPointer(type, id, x, y) {
-    this.send({ t: type, id, x, y });
+    const message = { t: type, id, x, y };
+    this.send(type === "down" ? this.markProbe(message) : message);
   }
 
   setMode(mode) {
@@ -40,6 +111,14 @@ export class ControlClient {
     this.send({ t: "setVideo", video });
   }
 
+  setLayer(layer) {
+    this.send({ t: "setLayer", layer });
+  }
+
+  setZoom(zoom) {
+    this.send({ t: "setZoom", zoom: zoom || null });
+  }
+
   setMonitor(idx) {
     this.send({ t: "setMonitor", idx });
   }
@@ -56,6 +135,10 @@ export class ControlClient {
     this.send({ t: "type", text });
   }
 
+  sendKey(key) {
+    this.send({ t: "key", key });
+  }
+
   sendEnter() {
     this.send({ t: "enter" });
   }
@@ -68,6 +151,26 @@ export class ControlClient {
     this.send({ t: "calibRect", step, rect });
   }
 
+  sendRegion(name, behavior, rect) {
+    this.send({ t: "calibRect", step: "region", name, behavior, rect });
+  }
+
+  sendHotspot(name, rect) {
+    this.send({ t: "calibRect", step: "hotspot", name, rect });
+  }
+
+  hotspot(name) {
+    this.send({ t: "hotspot", name });
+  }
+
+  removeHotspot(name) {
+    this.send({ t: "removeHotspot", name });
+  }
+
+  clearLayout() {
+    this.send({ t: "clearLayout" });
+  }
+
   sendWheel(x, y, wheelX, wheelY) {
     this.send({ t: "wheel", x, y, wheelX, wheelY });
   }
@@ -77,7 +180,7 @@ export class ControlClient {
   }
 
   sendClick() {
-    this.send({ t: "click" });
+    this.send(this.markProbe({ t: "click" }));
   }
 }
 
@@ -1,7 +1,9 @@
-import { login, logout, getState, getMonitors, updateConfig } from "./api.js";
+import { login, logout, getState, openStateStream, getMonitors, getHotspots, getTimeline, getPush, pushAction, updateConfig } from "./api.js";
 import { ControlClient } from "./control.js";
 import { WebRTCClient } from "./webrtc.js";
 import { Calibrator } from "./calib.js";
+import { TerminalView } from "./terminal.js";
+import { TileView } from "./tiles.js";
 import { bindFullscreen } from "./fullscreen.js";
 import { bindScrollPad } from "./scrollpad.js";
 import { bindPanZoom } from "./panzoom.js";
@@ -10,6 +12,7 @@ import { webRTCContentRect } from "./calib_webrtc.js";
 const app = document.querySelector(".app");
 const statusDot = document.getElementById("status-dot");
 const statusText = document.getElementById("status-text");
+const activityBadge = document.getElementById("activity-badge");
 const hintText = document.getElementById("hint-text");
 const loginForm = document.getElementById("login-form");
 const passwordInput = document.getElementById("password");
@@ -17,8 +20,14 @@ const loginHint = document.getElementById("login-hint");
 const controls = document.getElementById("controls");
 const modePresetupBtn = document.getElementById("mode-presetup");
 const modeRunBtn = document.getElementById("mode-run");
+const modeChatBtn = document.getElementById("mode-chat");
+const modeScrollBtn = document.getElementById("mode-scroll");
+const modeCompositeBtn = document.getElementById("mode-composite");
 const videoWebRTCBtn = document.getElementById("video-webrtc");
 const videoMJPEGBtn = document.getElementById("video-mjpeg");
+const videoTerminalBtn = document.getElementById("video-terminal");
+const terminalEl = document.getElementById("terminal");
+const terminalKeys = document.getElementById("terminal-keys");
 const monitorSelect = document.getElementById("monitor");
 const restartBtn = document.getElementById("restart-presetup");
 const logoutBtn = document.getElementById("logout");
@@ -26,6 +35,24 @@ const inputToggle = document.getElementById("input-enabled");
 const setPluginBtn = document.getElementById("set-plugin");
 const setChatBtn = document.getElementById("set-chat");
 const setScrollBtn = document.getElementById("set-scroll");
+const addTileBtn = document.getElementById("add-tile");
+const clearTilesBtn = document.getElementById("clear-tiles");
+const regionNameInput = document.getElementById("region-name");
+const regionBehaviorSelect = document.getElementById("region-behavior");
+const addRegionBtn = document.getElementById("add-region");
+const hotspotNameInput = document.getElementById("hotspot-name");
+const addHotspotBtn = document.getElementById("add-hotspot");
+const removeHotspotBtn = document.getElementById("remove-hotspot");
+const hotspotBar = document.getElementById("hotspot-bar");
+const timelineSection = document.getElementById("timeline-section");
+const timelineRefreshBtn = document.getElementById("timeline-refresh");
+const timelineStrip = document.getElementById("timeline-strip");
+const timelineHint = document.getElementById("timeline-hint");
+const pushSection = document.getElementById("push-section");
+const pushEnableBtn = document.getElementById("push-enable");
+const pushDisableBtn = document.getElementById("push-disable");
+const pushTestBtn = document.getElementById("push-test");
+const pushHint = document.getElementById("push-hint");
 const saveCalibBtn = document.getElementById("save-calib");
 const debugOverlaysToggle = document.getElementById("debug-overlays");
 const editCalibToggle = document.getElementById("edit-calib-rects");
@@ -47,6 +74,9 @@ const perfCrisp = document.getElementById("perf-crisp");
 const perfReset = document.getElementById("perf-reset");
 const perfHint = document.getElementById("perf-hint");
 const statsLine = document.getElementById("stats-line");
+const measureLatencyToggle = document.getElementById("measure-latency");
+const videoCodecSelect = document.getElementById("video-codec");
+const videoLayerSelect = document.getElementById("video-layer");
 const typeBox = document.getElementById("typebox");
 const sendTextBtn = document.getElementById("send-text");
 const sendEnterBtn = document.getElementById("send-enter");
@@ -75,12 +105,21 @@ const scrollToggleBtn = document.getElementById("toggle-scroll");
 
 let controlClient = null;
 let webrtcClient = null;
+let terminalView = null;
+let tileView = null;
+let tilesEnabled = false;
+let lastLatency = null;
+let lastCodec = "";
+let lastFeedback = null;
+let lastLayer = "";
+const baseTitle = document.title;
 let calibrator = null;
 let aspectPollTimer = null;
 let lastWrapAspect = "";
 let videoMode = "mjpeg";
 let fullscreen = null;
 let expectedMedia = null;
+let currentCrop = null;
 let cachedMonitors = null;
 let currentMode = "presetup";
 let currentMonitorIndex = 1;
@@ -104,6 +143,7 @@ let bootstrapping = false;
 let mjpegFPS = null;
 let mjpegLastFrameAt = null;
 let statsTimer = null;
+let stateStream = null;
 
 document.addEventListener("fullscreenchange", () => {
   updateWrapAspectRatio();
@@ -173,6 +213,18 @@ logoutBtn?.addEventListener("click", async () => {
   location.reload();
 });
 
+timelineRefreshBtn?.addEventListener("click", () => refreshTimeline());
+pushEnableBtn?.addEventListener("click", () => void enablePush());
+pushDisableBtn?.addEventListener("click", () => void disablePush());
+pushTestBtn?.addEventListener("click", async () => {
+  try {
+    const res = await pushAction("test");
+    pushHint.textContent = `Test sent to ${res.sent || 0} device(s).`;
+  } catch (_) {
+    pushHint.textContent = "Test failed.";
+  }
+});
+
 restartBtn.addEventListener("click", () => {
   controlClient?.restartPresetup();
   startAspectRatioPoll();
@@ -191,18 +243,34 @@ modePresetupBtn.addEventListener("click", () => {
   }
 });
 
-modeRunBtn.addEventListener("click", () => {
-  controlClient?.setMode("run");
-  currentMode = "run";
+modeRunBtn.addEventListener("click", () => selectCroppedMode("run"));
+modeChatBtn.addEventListener("click", () => selectCroppedMode("chat"));
+modeScrollBtn.addEventListener("click", () => selectCroppedMode("scroll"));
+modeCompositeBtn.addEventListener("click", () => selectCroppedMode("composite"));
+
+function selectCroppedMode(mode) {
+  controlClient?.setMode(mode);
+  currentMode = mode;
+  currentCrop = null;
+  updateModeButtons(mode);
   syncModeClass();
   updateExpectedMedia();
   syncCalibEditAvailability();
-  calibrator?.setMode?.(currentMode);
+  calibrator?.setMode?.(runLikeMode(currentMode));
   startAspectRatioPoll();
   if (document.body.classList.contains("is-fullscreen")) {
     window.setTimeout(() => applySavedScaleOrReset(), 150);
   }
-});
+}
+
+// Chat/scroll-only and composite modes behave like Run for input purposes; they just stream a different crop.
+function isCroppedMode(mode) {
+  return mode === "run" || mode === "chat" || mode === "scroll" || mode === "composite";
+}
+
+function runLikeMode(mode) {
+  return isCroppedMode(mode) ? "run" : "presetup";
+}
 
 videoWebRTCBtn.addEventListener("click", () => {
   setVideoMode("webrtc");
@@ -212,6 +280,15 @@ videoMJPEGBtn.addEventListener("click", () => {
   setVideoMode("mjpeg");
 });
 
+videoTerminalBtn.addEventListener("click", () => {
+  setVideoMode("terminal");
+});
+
+terminalKeys.addEventListener("click", (event) => {
+  const key = event.target.closest("[data-key]")?.dataset.key;
+  if (key) controlClient?.sendKey(key);
+});
+
 monitorSelect.addEventListener("change", () => {
   const idx = Number.parseInt(monitorSelect.value, 10);
   if (!Number.isNaN(idx)) {
@@ -229,6 +306,28 @@ inputToggle.addEventListener("change", () => {
 setPluginBtn.addEventListener("click", () => calibrator?.startStep("plugin"));
 setChatBtn.addEventListener("click", () => calibrator?.startStep("chat"));
 setScrollBtn.addEventListener("click", () => calibrator?.startStep("scroll"));
+addTileBtn.addEventListener("click", () => calibrator?.startStep("layout"));
+clearTilesBtn.addEventListener("click", () => controlClient?.clearLayout());
+addRegionBtn.addEventListener("click", () => {
+  if (!regionNameInput.value.trim()) {
+    calibHint.textContent = "Enter a region name first";
+    return;
+  }
+  calibrator?.startStep("region");
+});
+addHotspotBtn.addEventListener("click", () => {
+  if (!hotspotNameInput.value.trim()) {
+    calibHint.textContent = "Enter a hotspot name first";
+    return;
+  }
+  calibrator?.startStep("hotspot");
+});
+removeHotspotBtn.addEventListener("click", () => {
+  const name = hotspotNameInput.value.trim();
+  if (!name) return;
+  controlClient?.removeHotspot(name);
+  setTimeout(refreshHotspots, 200);
+});
 saveCalibBtn.addEventListener("click", () => calibrator?.save());
 
 sendTextBtn.addEventListener("click", () => {
@@ -271,6 +370,21 @@ scrollToggleBtn?.addEventListener("click", () => {
   saveUIPrefs();
 });
 
+videoCodecSelect?.addEventListener("change", () => {
+  saveCodecPref();
+  if (videoMode === "webrtc") {
+    startWebRTCOrFallback();
+  }
+});
+
+videoLayerSelect?.addEventListener("change", () => {
+  controlClient?.setLayer(videoLayerSelect.value);
+});
+
+measureLatencyToggle?.addEventListener("change", () => {
+  controlClient?.setMeasureLatency(measureLatencyToggle.checked);
+});
+
 debugOverlaysToggle?.addEventListener("change", () => {
   debugOverlays = Boolean(debugOverlaysToggle.checked);
   saveDebugPrefs();
@@ -341,6 +455,7 @@ async function bootstrap() {
     applyState(state);
     loadScalePrefs();
     loadDebugPrefs();
+    loadCodecPref();
     loadPostFXPrefs();
     applyUIPrefs();
     syncPointerToggle();
@@ -348,9 +463,21 @@ async function bootstrap() {
     syncFXUI();
 
     controlClient = new ControlClient(buildWsUrl("/ws/control"));
+    controlClient.onActivity = (msg) => showActivity(msg.state, true);
     await controlClient.connect();
+    controlClient.setMeasureLatency(Boolean(measureLatencyToggle?.checked));
+    ensureStateStream();
 
     calibrator = new Calibrator(video, overlay, (step, rect) => {
+      if (step === "region") {
+        controlClient?.sendRegion(regionNameInput.value.trim(), regionBehaviorSelect.value, rect);
+        return;
+      }
+      if (step === "hotspot") {
+        controlClient?.sendHotspot(hotspotNameInput.value.trim(), rect);
+        setTimeout(refreshHotspots, 200);
+        return;
+      }
       controlClient?.sendCalib(step, rect);
       if (step === "plugin") {
         currentCalibData = currentCalibData || {};
@@ -361,6 +488,9 @@ async function bootstrap() {
     }, (text) => {
       calibHint.textContent = text;
     }, mjpegImg);
+    refreshHotspots();
+    refreshTimeline();
+    void refreshPush();
     calibrator.setSelectionListener?.((step) => {
       if (!calibEditTarget) return;
       calibEditTarget.value = step;
@@ -375,7 +505,7 @@ async function bootstrap() {
       canvas: scrollpad,
       getPoint: (event) => normalizedPoint(event),
       getMetrics: () => overlayMetrics(),
-      getContext: () => ({ mode: currentMode, inputEnabled: inputToggle.checked, pointerEnabled, mouseMode, scrollModeEnabled, scroll: scrollOverlay }),
+      getContext: () => ({ mode: runLikeMode(currentMode), inputEnabled: inputToggle.checked, pointerEnabled, mouseMode, scrollModeEnabled, scroll: scrollOverlay }),
       sendPointer: (type, id, x, y) => controlClient?.sendPointer(type, id, x, y),
       sendWheel: (x, y, wheelX, wheelY) => controlClient?.sendWheel(x, y, wheelX, wheelY),
       sendRelMove: (dx, dy) => controlClient?.sendRelMove(dx, dy),
@@ -405,7 +535,9 @@ async function bootstrap() {
       onTap: () => fullscreen?.showControls?.(),
     });
 
-    if (videoMode === "mjpeg") {
+    if (videoMode === "terminal") {
+      await startTerminal();
+    } else if (videoMode === "mjpeg") {
       startMJPEG();
       setStatus("mjpeg");
     } else {
@@ -424,16 +556,156 @@ function applyState(state) {
   syncModeClass();
   currentMonitorIndex = state.monitor || 1;
   currentCalibData = state.calibData || null;
+  currentCrop = state.crop || null;
   inputToggle.checked = Boolean(state.inputEnabled);
   videoMode = state.videoMode || "mjpeg";
+  tilesEnabled = Boolean(state.tiles);
+  if (timelineSection) timelineSection.hidden = !state.timeline;
+  if (pushSection) pushSection.hidden = !state.push;
+  showActivity(state.activity?.state, false);
   scrollOverlay = { ...scrollOverlay, ...(state.scroll || {}) };
   updateVideoButtons(videoMode);
   expectedMedia = computeExpectedMedia(currentMode, currentMonitorIndex, currentCalibData, cachedMonitors);
   syncCalibEditAvailability();
-  calibrator?.setMode?.(currentMode);
+  calibrator?.setMode?.(runLikeMode(currentMode));
   calibrator?.setCalibData?.(currentCalibData);
   calibrator?.setExpectedSize?.(expectedMedia);
-  hintText.textContent = state.mode === "run" ? "Run mode active." : "Presetup mode active.";
+  hintText.textContent = isCroppedMode(state.mode) ? `Run mode active (${state.mode} crop).` : "Presetup mode active.";
+}
+
+function ensureStateStream() {
+  if (stateStream && stateStream.readyState !== EventSource.CLOSED) return;
+  stateStream = openStateStream(applyRemoteState);
+}
+
+// applyRemoteState follows changes pushed by the server, including those made from other
+// tabs or devices. Local clicks already updated the page, so their echo changes nothing.
+function applyRemoteState(state) {
+  const prevVideo = videoMode;
+  const prevMode = currentMode;
+  const prevMonitor = currentMonitorIndex;
+  applyState(state);
+  if (monitorSelect.value !== String(currentMonitorIndex)) {
+    monitorSelect.value = String(currentMonitorIndex);
+  }
+  if (videoMode !== prevVideo) {
+    void switchVideo(videoMode);
+  } else if (currentMode !== prevMode || currentMonitorIndex !== prevMonitor) {
+    startAspectRatioPoll();
+  }
+}
+
+async function refreshHotspots() {
+  let hotspots = [];
+  try {
+    hotspots = await getHotspots();
+  } catch (_) {
+    // keep the bar empty when hotspots are unavailable
+  }
+  hotspotBar.replaceChildren(...hotspots.map((h) => {
+    const btn = document.createElement("button");
+    btn.type = "button";
+    btn.className = "btn";
+    btn.textContent = h.name;
+    btn.addEventListener("click", () => controlClient?.hotspot(h.name));
+    return btn;
+  }));
+}
+
+async function refreshTimeline() {
+  if (!timelineStrip || timelineSection?.hidden) return;
+  let data = { entries: [] };
+  try {
+    data = await getTimeline();
+  } catch (_) {
+    timelineHint.textContent = "Timeline unavailable.";
+    return;
+  }
+  const entries = data.entries || [];
+  // The server lists oldest first; show the newest on the left.
+  timelineStrip.replaceChildren(...entries.slice().reverse().map((e) => {
+    const link = document.createElement("a");
+    link.href = `/api/timeline/${e.id}.jpg`;
+    link.target = "_blank";
+    link.rel = "noopener";
+    const img = document.createElement("img");
+    img.loading = "lazy";
+    img.alt = e.reason;
+    img.src = `/api/timeline/${e.id}.jpg?thumb=1`;
+    const label = document.createElement("span");
+    label.textContent = new Date(e.time).toLocaleTimeString();
+    link.append(img, label);
+    return link;
+  }));
+  timelineHint.textContent = entries.length
+    ? `${entries.length} samples, every ${data.intervalSec}s and on large changes.`
+    : "No samples yet.";
+}
+
+// pushRegistration returns the service worker registration, or null where Web Push is
+// unavailable (plain http on a LAN address, old browsers).
+async function pushRegistration() {
+  if (!window.isSecureContext || !("serviceWorker" in navigator) || !("PushManager" in window)) {
+    pushHint.textContent = "Notifications need HTTPS (or localhost) and a browser with Web Push.";
+    return null;
+  }
+  return navigator.serviceWorker.register("/sw.js");
+}
+
+async function refreshPush() {
+  if (!pushHint || pushSection?.hidden) return;
+  try {
+    const info = await getPush();
+    const reg = await pushRegistration();
+    if (!reg) return;
+    const sub = await reg.pushManager.getSubscription();
+    pushHint.textContent = sub
+      ? `This device is subscribed (${(info.events || []).join(", ") || "no triggers"}).`
+      : `${info.subscriptions || 0} device(s) subscribed.`;
+  } catch (_) {
+    pushHint.textContent = "Notifications unavailable.";
+  }
+}
+
+async function enablePush() {
+  try {
+    const reg = await pushRegistration();
+    if (!reg) return;
+    if ((await Notification.requestPermission()) !== "granted") {
+      pushHint.textContent = "Notification permission denied.";
+      return;
+    }
+    const { publicKey } = await getPush();
+    const sub = (await reg.pushManager.getSubscription()) || (await reg.pushManager.subscribe({
+      userVisibleOnly: true,
+      applicationServerKey: base64UrlToBytes(publicKey),
+    }));
+    await pushAction("subscribe", sub.toJSON());
+  } catch (err) {
+    pushHint.textContent = `Subscribe failed: ${err.message}`;
+    return;
+  }
+  await refreshPush();
+}
+
+async function disablePush() {
+  try {
+    const reg = await pushRegistration();
+    const sub = await reg?.pushManager.getSubscription();
+    if (sub) {
+      await pushAction("unsubscribe", { endpoint: sub.endpoint });
+      await sub.unsubscribe();
+    }
+  } catch (_) {
+    pushHint.textContent = "Unsubscribe failed.";
+    return;
+  }
+  await refreshPush();
+}
+
+function base64UrlToBytes(value) {
+  const base64 = value.replace(/-/g, "+").replace(/_/g, "/").padEnd(Math.ceil(value.length / 4) * 4, "=");
+  return Uint8Array.from(atob(base64), (c) => c.charCodeAt(0));
 }
 
 function syncCalibEditAvailability() {
@@ -482,9 +754,16 @@ async function resumeConnections() {
 
     if (!controlClient || !controlClient.ready) {
       controlClient = new ControlClient(buildWsUrl("/ws/control"));
+      controlClient.onActivity = (msg) => showActivity(msg.state, true);
       await controlClient.connect();
+      controlClient.setMeasureLatency(Boolean(measureLatencyToggle?.checked));
     }
+    ensureStateStream();
 
+    if (videoMode === "terminal") {
+      await startTerminal();
+      return;
+    }
     if (videoMode === "mjpeg") {
       refreshMJPEG();
       setStatus("mjpeg");
@@ -501,21 +780,24 @@ async function resumeConnections() {
 }
 
 function updateModeButtons(mode) {
-  const isRun = mode === "run";
-  modeRunBtn.classList.toggle("active", isRun);
-  modePresetupBtn.classList.toggle("active", !isRun);
+  modeRunBtn.classList.toggle("active", mode === "run");
+  modeChatBtn.classList.toggle("active", mode === "chat");
+  modeScrollBtn.classList.toggle("active", mode === "scroll");
+  modeCompositeBtn.classList.toggle("active", mode === "composite");
+  modePresetupBtn.classList.toggle("active", !isCroppedMode(mode));
 }
 
 function syncModeClass() {
-  const isRun = currentMode === "run";
+  const isRun = isCroppedMode(currentMode);
   document.body.classList.toggle("mode-run", isRun);
   document.body.classList.toggle("mode-presetup", !isRun);
 }
 
 function updateVideoButtons(mode) {
-  const useMJPEG = mode === "mjpeg";
-  videoMJPEGBtn.classList.toggle("active", useMJPEG);
-  videoWebRTCBtn.classList.toggle("active", !useMJPEG);
+  videoMJPEGBtn.classList.toggle("active", mode === "mjpeg");
+  videoTerminalBtn.classList.toggle("active", mode === "terminal");
+  videoWebRTCBtn.classList.toggle("active", mode === "webrtc");
+  terminalKeys.hidden = mode !== "terminal";
 }
 
 function populateMonitors(monitors, activeIndex) {
@@ -547,13 +829,39 @@ function normalizedPoint(event) {
 
 function setStatus(state) {
   statusText.textContent = state;
-  statusDot.style.background = state === "streaming" || state === "mjpeg" ? "#2a6f6d" : "#b2472f";
+  statusDot.style.background = state === "streaming" || state === "mjpeg" || state === "terminal" ? "#2a6f6d" : "#b2472f";
   updatePreviewVisibility();
 }
 
+// showActivity updates the working/idle badge; a live transition to idle also marks the tab
+// title and vibrates so a backgrounded viewer notices the agent finished.
+function showActivity(state, live) {
+  const known = state === "active" || state === "idle";
+  if (activityBadge) {
+    activityBadge.hidden = !known;
+    activityBadge.dataset.state = known ? state : "";
+    activityBadge.textContent = state === "active" ? "working" : "idle";
+  }
+  if (state === "active") {
+    document.title = baseTitle;
+  }
+  if (live && state === "idle") {
+    if (document.visibilityState === "hidden") {
+      document.title = `Idle - ${baseTitle}`;
+    }
+    navigator.vibrate?.(200);
+  }
+}
+
 function updatePreviewVisibility() {
   if (!mjpegImg) return;
   document.body.classList.toggle("video-webrtc", videoMode === "webrtc");
+  document.body.classList.toggle("video-terminal", videoMode === "terminal");
+  if (videoMode === "terminal") {
+    mjpegImg.style.display = "none";
+    video.style.display = "none";
+    return;
+  }
   if (videoMode === "mjpeg") {
     mjpegImg.style.display = "block";
     video.style.display = "none";
@@ -568,7 +876,7 @@ function updatePreviewVisibility() {
 }
 
 function updateWrapAspectRatio() {
-  if (!videoWrap) return;
+  if (!videoWrap || videoMode === "terminal") return;
   const bounds = videoWrap.getBoundingClientRect();
   const size = mediaSize(bounds);
   if (!size.width || !size.height) return;
@@ -629,13 +937,28 @@ async function setVideoMode(next) {
     updatePreviewVisibility();
     return;
   }
+  controlClient?.setVideoMode(next);
+  await switchVideo(next);
+}
+
+// switchVideo starts the client side of a video mode the server already runs.
+async function switchVideo(next) {
   videoMode = next;
   updateVideoButtons(videoMode);
-  controlClient?.setVideoMode(videoMode);
   if (document.body.classList.contains("is-fullscreen")) {
     applySavedScaleOrReset();
   }
 
+  if (videoMode !== "terminal") {
+    stopTerminal();
+  }
+  if (videoMode === "terminal") {
+    webrtcClient?.close();
+    webrtcClient = null;
+    stopMJPEG();
+    await startTerminal();
+    return;
+  }
   if (videoMode === "mjpeg") {
     webrtcClient?.close();
     webrtcClient = null;
@@ -648,10 +971,22 @@ async function setVideoMode(next) {
   await startWebRTCOrFallback();
 }
 
+async function startTerminal() {
+  terminalView = terminalView || new TerminalView(terminalEl, (data) => controlClient?.sendType(data));
+  await terminalView.open(buildWsUrl("/ws/terminal"));
+  videoWrap.style.aspectRatio = "";
+  lastWrapAspect = "";
+  setStatus("terminal");
+}
+
+function stopTerminal() {
+  terminalView?.close();
+}
+
 function startMJPEG() {
   if (!mjpegImg) return;
   mjpegImg.style.display = "block";
-  mjpegImg.src = `/mjpeg/desktop?ts=${Date.now()}`;
+  openMJPEGSource();
   mjpegImg.addEventListener("error", () => {
     mjpegImg.style.display = "none";
   }, { once: true });
@@ -662,13 +997,35 @@ function startMJPEG() {
 function refreshMJPEG() {
   if (!mjpegImg || videoMode !== "mjpeg") return;
   mjpegImg.style.display = "block";
-  mjpegImg.src = `/mjpeg/desktop?ts=${Date.now()}`;
+  openMJPEGSource();
   applyPostFX();
   startAspectRatioPoll();
 }
 
+function openMJPEGSource() {
+  if (!tilesEnabled) {
+    tileView?.close();
+    mjpegImg.src = `/mjpeg/desktop?${mjpegVariantQuery()}ts=${Date.now()}`;
+    return;
+  }
+  tileView = tileView || new TileView(mjpegImg);
+  tileView.open(buildWsUrl("/ws/tiles"));
+}
+
+function mjpegVariantQuery() {
+  // Page parameters mjpegInterval/mjpegQuality/mjpegScale pick this viewer's own stream variant.
+  const page = new URLSearchParams(window.location.search);
+  const query = new URLSearchParams();
+  for (const [param, name] of [["mjpegInterval", "interval"], ["mjpegQuality", "quality"], ["mjpegScale", "scale"]]) {
+    if (page.has(param)) query.set(name, page.get(param));
+  }
+  const str = query.toString();
+  return str ? `${str}&` : "";
+}
+
 function stopMJPEG() {
   if (!mjpegImg) return;
+  tileView?.close();
   mjpegImg.src = "";
   mjpegImg.style.display = "none";
 }
@@ -689,7 +1046,8 @@ function noteMJPEGFrame() {
 async function startWebRTCOrFallback() {
   if (!video) return;
   webrtcClient?.close();
-  webrtcClient = new WebRTCClient(video, setStatus);
+  webrtcClient = new WebRTCClient(video, setStatus, (fast, reliable) => controlClient?.setDataChannels(fast, reliable));
+  webrtcClient.setCodec(videoCodecSelect?.value || "");
   try {
     await webrtcClient.connect(buildWsUrl("/ws/signal"));
     hintText.textContent = "WebRTC connecting...";
@@ -721,7 +1079,7 @@ function contentRect(bounds) {
   if (document.body.classList.contains("is-fullscreen")) {
     // Fullscreen Run uses object-fit: fill to maximize usable area on mobile.
     // Presetup keeps aspect ratio (contain) so users can place calibration rectangles accurately.
-    if (currentMode === "run" && videoMode !== "webrtc") {
+    if (isCroppedMode(currentMode) && videoMode !== "webrtc") {
       base = { x: 0, y: 0, width: bounds.width, height: bounds.height };
     }
   }
@@ -999,6 +1357,28 @@ function saveDebugPrefs() {
   }
 }
 
+function codecStorageKey() {
+  return `deskslice:codec:${location.host}`;
+}
+
+function loadCodecPref() {
+  if (!videoCodecSelect) return;
+  try {
+    const raw = window.localStorage.getItem(codecStorageKey()) || "";
+    videoCodecSelect.value = Array.from(videoCodecSelect.options).some((o) => o.value === raw) ? raw : "";
+  } catch (_) {
+    videoCodecSelect.value = "";
+  }
+}
+
+function saveCodecPref() {
+  try {
+    window.localStorage.setItem(codecStorageKey(), videoCodecSelect?.value || "");
+  } catch (_) {
+    // ignore
+  }
+}
+
 function postFXStorageKey() {
   return `deskslice:postFX:${location.host}`;
 }
@@ -1107,25 +1487,47 @@ function startStatsLoop() {
     if (app.dataset.auth !== "true") return;
     const start = performance.now();
     try {
-      await getState();
+      const state = await getState();
       const rtt = Math.round(performance.now() - start);
-      updateStatsLine(rtt);
+      lastCodec = state.codec || "";
+      lastFeedback = state.feedback || null;
+      lastLayer = state.layer ? `${state.layer} (${state.layerMode})` : "";
+      updateStatsLine(rtt, state.latency);
     } catch (_) {
       updateStatsLine();
     }
   }, 2000);
 }
 
-function updateStatsLine(rttMs) {
+function updateStatsLine(rttMs, latency) {
   if (!statsLine) return;
+  if (latency !== undefined) {
+    lastLatency = latency;
+  }
   const parts = [];
   parts.push(`video=${videoMode}`);
+  if (videoMode === "webrtc" && lastCodec) {
+    parts.push(`codec=${lastCodec}`);
+  }
+  if (videoMode === "webrtc" && lastLayer) {
+    parts.push(`layer=${lastLayer}`);
+  }
+  if (videoMode === "webrtc" && lastFeedback) {
+    parts.push(`nack=${lastFeedback.nacks} pli=${lastFeedback.plis + lastFeedback.firs}`);
+  }
   if (videoMode === "mjpeg" && mjpegFPS !== null) {
     parts.push(`fps~${mjpegFPS.toFixed(1)}`);
   }
   if (Number.isFinite(rttMs)) {
     parts.push(`api~${rttMs}ms`);
   }
+  if (measureLatencyToggle?.checked && lastLatency) {
+    const labels = { rttMs: "rtt", inputMs: "input", encodeMs: "enc" };
+    Object.entries(labels).forEach(([key, label]) => {
+      const s = lastLatency[key];
+      if (s?.count) parts.push(`${label} p50/p90 ${Math.round(s.p50)}/${Math.round(s.p90)}ms`);
+    });
+  }
   statsLine.textContent = parts.length ? parts.join(" · ") : "—";
 }
 
@@ -1146,6 +1548,9 @@ function computeExpectedMedia(mode, monitorIndex, calib, monitors) {
   }
   const monitor = monitors.find((m) => (m.Index ?? m.index) === monitorIndex);
   if (!monitor) return null;
+  if (isCroppedMode(mode) && currentCrop?.W && currentCrop?.H) {
+    return { width: currentCrop.W, height: currentCrop.H };
+  }
   if (mode === "run" && calib?.PluginAbs?.W && calib?.PluginAbs?.H) {
     return { width: calib.PluginAbs.W, height: calib.PluginAbs.H };
   }
@@ -1176,6 +1581,7 @@ function clampInt(value, min, max) {
 
 document.addEventListener("visibilitychange", () => {
   if (document.visibilityState === "visible") {
+    document.title = baseTitle;
     void resumeConnections();
   }
 });
@@ -0,0 +1,112 @@
+const XTERM_VERSION = "5.5.0";
+const FIT_VERSION = "0.10.0";
+const CELL_W = 8;
+const CELL_H = 17;
+
+// Matches CSI/OSC escape sequences so the plain-text fallback can drop them.
+const ESCAPES = /\x1b\[[0-?]*[ -/]*[@-~]|\x1b\][^\x07\x1b]*(?:\x07|\x1b\\)|\x1b[@-Z\\-_]/g;
+
+async function loadXterm() {
+  const base = "https://cdn.jsdelivr.net/npm";
+  if (!document.getElementById("xterm-css")) {
+    const link = document.createElement("link");
+    link.id = "xterm-css";
+    link.rel = "stylesheet";
+    link.href = `${base}/@xterm/xterm@${XTERM_VERSION}/css/xterm.css`;
+    document.head.appendChild(link);
+  }
+  const [xterm, fit] = await Promise.all([
+    import(`${base}/@xterm/xterm@${XTERM_VERSION}/+esm`),
+    import(`${base}/@xterm/addon-fit@${FIT_VERSION}/+esm`),
+  ]);
+  return { Terminal: xterm.Terminal, FitAddon: fit.FitAddon };
+}
+
+export class TerminalView {
+  constructor(container, onInput) {
+    this.container = container;
+    this.onInput = onInput;
+    this.ws = null;
+    this.term = null;
+    this.fit = null;
+    this.pre = null;
+    this.decoder = new TextDecoder();
+    this.resizeObserver = null;
+  }
+
+  async open(url) {
+    this.close();
+    this.container.hidden = false;
+    await this.mountRenderer();
+    this.ws = new WebSocket(url);
+    this.ws.binaryType = "arraybuffer";
+    this.ws.onopen = () => this.sendSize();
+    this.ws.onmessage = (event) => this.write(event.data);
+    this.resizeObserver = new ResizeObserver(() => {
+      this.fit?.fit();
+      this.sendSize();
+    });
+    this.resizeObserver.observe(this.container);
+  }
+
+  async mountRenderer() {
+    try {
+      const { Terminal, FitAddon } = await loadXterm();
+      this.term = new Terminal({ convertEol: false, fontSize: 13, cursorBlink: true });
+      this.fit = new FitAddon();
+      this.term.loadAddon(this.fit);
+      this.term.open(this.container);
+      this.fit.fit();
+      this.term.onData((data) => this.onInput?.(data));
+    } catch (_) {
+      // xterm.js unavailable (offline): fall back to plain text without escape sequences.
+      this.term = null;
+      this.fit = null;
+      this.pre = document.createElement("pre");
+      this.pre.className = "terminal-plain";
+      this.container.appendChild(this.pre);
+    }
+  }
+
+  write(data) {
+    const bytes = new Uint8Array(data);
+    if (this.term) {
+      this.term.write(bytes);
+      return;
+    }
+    if (!this.pre) return;
+    const text = this.decoder.decode(bytes, { stream: true }).replace(ESCAPES, "").replace(/\r(?!\n)/g, "");
+    this.pre.textContent = (this.pre.textContent + text).slice(-65536);
+    this.pre.scrollTop = this.pre.scrollHeight;
+  }
+
+  size() {
+    if (this.term) {
+      return { cols: this.term.cols, rows: this.term.rows };
+    }
+    const bounds = this.container.getBoundingClientRect();
+    return {
+      cols: Math.max(20, Math.floor(bounds.width / CELL_W)),
+      rows: Math.max(5, Math.floor(bounds.height / CELL_H)),
+    };
+  }
+
+  sendSize() {
+    if (!this.ws || this.ws.readyState !== WebSocket.OPEN) return;
+    const { cols, rows } = this.size();
+    this.ws.send(JSON.stringify({ t: "resize", cols, rows }));
+  }
+
+  close() {
+    this.resizeObserver?.disconnect();
+    this.resizeObserver = null;
+    this.ws?.close();
+    this.ws = null;
+    this.term?.dispose();
+    this.term = null;
+    this.fit = null;
+    this.pre = null;
+    this.container.replaceChildren();
+    this.container.hidden = true;
+  }
+}
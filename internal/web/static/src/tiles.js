@@ -0,0 +1,85 @@
+// Message types sent by /ws/tiles (see internal/tiles).
+const MSG_TILE = 1;
+const MSG_FRAME_END = 2;
+
+// TileView assembles changed JPEG tiles on an offscreen canvas and shows each finished frame
+// in the existing preview <img>, so sizing, post FX and fullscreen behave like plain MJPEG.
+export class TileView {
+  constructor(img) {
+    this.img = img;
+    this.ws = null;
+    this.canvas = document.createElement("canvas");
+    this.ctx = this.canvas.getContext("2d");
+    this.queue = Promise.resolve();
+    this.dirty = false;
+    this.url = "";
+  }
+
+  open(url) {
+    this.close();
+    this.ws = new WebSocket(url);
+    this.ws.binaryType = "arraybuffer";
+    this.ws.onmessage = (event) => this.handle(event.data);
+  }
+
+  close() {
+    if (this.ws) {
+      this.ws.onmessage = null;
+      this.ws.close();
+      this.ws = null;
+    }
+    this.queue = Promise.resolve();
+    this.dirty = false;
+    if (this.url) {
+      URL.revokeObjectURL(this.url);
+      this.url = "";
+    }
+  }
+
+  handle(data) {
+    const view = new DataView(data);
+    const type = view.getUint8(0);
+    const frameW = view.getUint16(1);
+    const frameH = view.getUint16(3);
+    // Decode asynchronously but draw in arrival order so newer tiles always win.
+    if (type === MSG_TILE) {
+      const x = view.getUint16(5);
+      const y = view.getUint16(7);
+      const blob = new Blob([new Uint8Array(data, 13)], { type: "image/jpeg" });
+      const decoded = createImageBitmap(blob);
+      this.queue = this.queue.then(async () => {
+        const bitmap = await decoded;
+        this.resize(frameW, frameH);
+        this.ctx.drawImage(bitmap, x, y);
+        bitmap.close?.();
+        this.dirty = true;
+      }).catch(() => {});
+      return;
+    }
+    if (type === MSG_FRAME_END) {
+      this.queue = this.queue.then(() => this.present(frameW, frameH)).catch(() => {});
+    }
+  }
+
+  resize(width, height) {
+    if (this.canvas.width === width && this.canvas.height === height) return;
+    this.canvas.width = width;
+    this.canvas.height = height;
+  }
+
+  present(width, height) {
+    if (!this.dirty || this.canvas.width !== width || this.canvas.height !== height) return;
+    this.dirty = false;
+    return new Promise((resolve) => {
+      this.canvas.toBlob((blob) => {
+        if (blob && this.ws) {
+          const previous = this.url;
+          this.url = URL.createObjectURL(blob);
+          this.img.src = this.url;
+          if (previous) URL.revokeObjectURL(previous);
+        }
+        resolve();
+      }, "image/jpeg", 0.95);
+    });
+  }
+}
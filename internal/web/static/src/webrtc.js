@@ -1,10 +1,20 @@
+// Control channels are negotiated out-of-band; labels/IDs must match internal/webrtc/datachannel.go.
+const CONTROL_CHANNELS = {
+  fast: { label: "control-fast", id: 1, ordered: false, maxRetransmits: 0 },
+  reliable: { label: "control", id: 2 },
+};
+
 export class WebRTCClient {
-  constructor(video, setStatus) {
+  constructor(video, setStatus, onControlChannels) {
     this.video = video;
     this.setStatus = setStatus;
+    this.onControlChannels = onControlChannels;
     this.ws = null;
     this.pc = null;
     this.url = null;
+    this.iceServers = [];
+    this.codec = "";
+    this.ready = null;
     this.restartTimer = null;
     this.closing = false;
   }
@@ -12,16 +22,12 @@ export class WebRTCClient {
   connect(url) {
     this.url = url;
     return new Promise((resolve, reject) => {
+      this.ready = { resolve, reject };
       this.ws = new WebSocket(url);
-      this.ws.onopen = async () => {
-        try {
-          this.closing = false;
-          this.setStatus("connecting");
-          await this.startPeer();
-          resolve();
-        } catch (err) {
-          reject(err);
-        }
+      this.ws.onopen = () => {
+        // The peer is built once the server's "config" message delivers ICE servers.
+        this.closing = false;
+        this.setStatus("connecting");
       };
       this.ws.onmessage = (event) => {
         this.handleMessage(event.data);
@@ -36,6 +42,10 @@ export class WebRTCClient {
         if (!this.closing) {
           this.setStatus("offline");
         }
+        if (this.ready) {
+          this.ready.reject(new Error("signaling closed before config"));
+          this.ready = null;
+        }
       };
     });
   }
@@ -47,6 +57,19 @@ export class WebRTCClient {
     } catch (_) {
       return;
     }
+    if (msg.t === "config") {
+      this.iceServers = Array.isArray(msg.iceServers) ? msg.iceServers : [];
+      if (!this.pc) {
+        const ready = this.ready;
+        this.ready = null;
+        try {
+          await this.startPeer();
+          if (ready) ready.resolve();
+        } catch (err) {
+          if (ready) ready.reject(err);
+        }
+      }
+    }
     if (msg.t === "answer") {
       if (this.pc) {
         await this.pc.setRemoteDescription({ type: "answer", sdp: msg.sdp });
@@ -62,8 +85,10 @@ export class WebRTCClient {
 
   async startPeer() {
     this.setStatus("connecting");
-    this.pc = new RTCPeerConnection();
-    this.pc.addTransceiver("video", { direction: "recvonly" });
+    this.pc = new RTCPeerConnection({ iceServers: this.iceServers });
+    const transceiver = this.pc.addTransceiver("video", { direction: "recvonly" });
+    this.restrictCodec(transceiver);
+    this.openControlChannels();
     this.pc.ontrack = (event) => {
       let stream = null;
       if (event.streams && event.streams[0]) {
@@ -92,6 +117,39 @@ export class WebRTCClient {
     }
   }
 
+  setCodec(codec) {
+    this.codec = codec || "";
+  }
+
+  // Limit the offer to the chosen codec (plus RTX/FEC helpers) so the host must encode it.
+  restrictCodec(transceiver) {
+    if (!this.codec || !transceiver?.setCodecPreferences || !RTCRtpReceiver.getCapabilities) return;
+    const caps = RTCRtpReceiver.getCapabilities("video")?.codecs || [];
+    const wanted = `video/${this.codec}`.toLowerCase();
+    const helpers = ["video/rtx", "video/red", "video/ulpfec", "video/flexfec-03"];
+    const chosen = caps.filter((c) => c.mimeType.toLowerCase() === wanted);
+    if (!chosen.length) return;
+    const extras = caps.filter((c) => helpers.includes(c.mimeType.toLowerCase()));
+    try {
+      transceiver.setCodecPreferences([...chosen, ...extras]);
+    } catch (_) {
+      // Keep the browser's default offer.
+    }
+  }
+
+  openControlChannels() {
+    if (!this.onControlChannels) return;
+    const open = (spec) => {
+      const { label, ...init } = spec;
+      return this.pc.createDataChannel(label, { ...init, negotiated: true });
+    };
+    try {
+      this.onControlChannels(open(CONTROL_CHANNELS.fast), open(CONTROL_CHANNELS.reliable));
+    } catch (_) {
+      // DataChannels unavailable: control keeps using the websocket.
+    }
+  }
+
   scheduleRestart() {
     if (this.restartTimer) return;
     this.restartTimer = setTimeout(async () => {
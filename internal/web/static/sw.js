@@ -0,0 +1,27 @@
+// Service worker that shows Web Push notifications sent by the DeskSlice server.
+self.addEventListener("push", (event) => {
+  let msg = {};
+  try {
+    msg = event.data ? event.data.json() : {};
+  } catch (_) {
+    msg = { body: event.data?.text() };
+  }
+  event.waitUntil(
+    self.registration.showNotification(msg.title || "DeskSlice", {
+      body: msg.body || "",
+      tag: msg.tag || undefined,
+      renotify: Boolean(msg.tag),
+      vibrate: [200, 100, 200],
+    }),
+  );
+});
+
+self.addEventListener("notificationclick", (event) => {
+  event.notification.close();
+  event.waitUntil(
+    self.clients.matchAll({ type: "window", includeUncontrolled: true }).then((clients) => {
+      const open = clients.find((client) => "focus" in client);
+      return open ? open.focus() : self.clients.openWindow("/");
+    }),
+  );
+});
@@ -0,0 +1,278 @@
+// Package webhook delivers bus events as HMAC-signed JSON POSTs to configured URLs, with
+// retries and an in-memory delivery log.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/frudas24/deskslice/internal/events"
+)
+
+const (
+	// queueSize bounds the events waiting for one URL; more are logged as dropped.
+	queueSize = 64
+	// logSize is how many deliveries the log keeps.
+	logSize = 200
+	// busBuffer is the bus subscription buffer.
+	busBuffer = 256
+)
+
+// Headers set on every delivery.
+const (
+	HeaderEvent     = "X-DeskSlice-Event"
+	HeaderDelivery  = "X-DeskSlice-Delivery"
+	HeaderTimestamp = "X-DeskSlice-Timestamp"
+	// HeaderSignature carries "sha256=" and the hex HMAC of "<timestamp>.<body>".
+	HeaderSignature = "X-DeskSlice-Signature"
+)
+
+// Config selects where and how events are delivered.
+type Config struct {
+	URLs []string
+	// Secret keys the HMAC signature; deliveries are unsigned when it is empty.
+	Secret string
+	// Events limits deliveries to these types; empty means all.
+	Events []string
+	// Retries is how many times a failed delivery is retried.
+	Retries int
+	// Backoff is the wait before the first retry; it doubles for each further one.
+	Backoff time.Duration
+	// Timeout bounds each attempt.
+	Timeout time.Duration
+}
+
+// Delivery is one entry of the delivery log.
+type Delivery struct {
+	ID         int64     `json:"id"`
+	EventID    int64     `json:"eventId"`
+	Event      string    `json:"event"`
+	URL        string    `json:"url"`
+	Time       time.Time `json:"time"`
+	Attempts   int       `json:"attempts"`
+	Status     int       `json:"status,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	OK         bool      `json:"ok"`
+	DurationMs int64     `json:"durationMs"`
+}
+
+// Dispatcher posts events to every URL, each through its own queue so a slow receiver
+// does not hold up the others.
+type Dispatcher struct {
+	mu     sync.Mutex
+	cfg    Config
+	client *http.Client
+	queues map[string]chan events.Event
+	log    []Delivery
+	nextID int64
+	cancel context.CancelFunc
+}
+
+// New returns a dispatcher; Start connects it to a bus.
+func New(cfg Config) *Dispatcher {
+	if cfg.Backoff <= 0 {
+		cfg.Backoff = time.Second
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	return &Dispatcher{cfg: cfg, client: &http.Client{}}
+}
+
+// SetClient replaces the HTTP client used for deliveries.
+func (d *Dispatcher) SetClient(c *http.Client) {
+	d.mu.Lock()
+	d.client = c
+	d.mu.Unlock()
+}
+
+// Start subscribes to the bus and delivers its events until Close.
+func (d *Dispatcher) Start(bus *events.Bus) {
+	ctx, cancel := context.WithCancel(context.Background())
+	ch, unsubscribe := bus.Subscribe(busBuffer)
+	d.mu.Lock()
+	d.cancel = cancel
+	d.queues = make(map[string]chan events.Event, len(d.cfg.URLs))
+	for _, url := range d.cfg.URLs {
+		q := make(chan events.Event, queueSize)
+		d.queues[url] = q
+		go d.worker(ctx, url, q)
+	}
+	d.mu.Unlock()
+	go func() {
+		defer unsubscribe()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case ev := <-ch:
+				d.enqueue(ev)
+			}
+		}
+	}()
+}
+
+// Close stops delivering; queued events are abandoned.
+func (d *Dispatcher) Close() {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.cancel != nil {
+		d.cancel()
+		d.cancel = nil
+	}
+}
+
+// Deliveries returns the delivery log, oldest first.
+func (d *Dispatcher) Deliveries() []Delivery {
+	if d == nil {
+		return nil
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]Delivery(nil), d.log...)
+}
+
+// enqueue hands an event to every URL queue, unless it is filtered out.
+func (d *Dispatcher) enqueue(ev events.Event) {
+	if len(d.cfg.Events) > 0 && !slices.Contains(d.cfg.Events, ev.Type) {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for url, q := range d.queues {
+		select {
+		case q <- ev:
+		default:
+			d.recordLocked(Delivery{EventID: ev.ID, Event: ev.Type, URL: url, Time: time.Now(), Error: "queue full, dropped"})
+		}
+	}
+}
+
+// worker delivers one URL's events in order.
+func (d *Dispatcher) worker(ctx context.Context, url string, q <-chan events.Event) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case ev := <-q:
+			d.deliver(ctx, url, ev)
+		}
+	}
+}
+
+// deliver posts an event, retrying network errors, 429 and 5xx answers with exponential
+// backoff, and logs the outcome.
+func (d *Dispatcher) deliver(ctx context.Context, url string, ev events.Event) {
+	body, err := json.Marshal(ev)
+	if err != nil {
+		log.Printf("webhook: encode %s: %v", ev.Type, err)
+		return
+	}
+	d.mu.Lock()
+	d.nextID++
+	entry := Delivery{ID: d.nextID, EventID: ev.ID, Event: ev.Type, URL: url, Time: time.Now()}
+	d.mu.Unlock()
+
+	start := time.Now()
+	backoff := d.cfg.Backoff
+	for {
+		entry.Attempts++
+		status, err := d.post(ctx, url, entry.ID, ev.Type, body)
+		entry.Status, entry.Error = status, ""
+		if err != nil {
+			entry.Error = err.Error()
+		} else if status >= 300 {
+			entry.Error = http.StatusText(status)
+		}
+		entry.OK = entry.Error == ""
+		retryable := err != nil || status == http.StatusTooManyRequests || status >= 500
+		if entry.OK || !retryable || entry.Attempts > d.cfg.Retries {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			entry.Error = "canceled: " + entry.Error
+			d.finish(entry, start)
+			return
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+	d.finish(entry, start)
+}
+
+// finish stamps the duration and logs a delivery.
+func (d *Dispatcher) finish(entry Delivery, start time.Time) {
+	entry.DurationMs = time.Since(start).Milliseconds()
+	if !entry.OK {
+		log.Printf("webhook: %s to %s failed after %d attempt(s): %s", entry.Event, entry.URL, entry.Attempts, entry.Error)
+	}
+	d.mu.Lock()
+	d.recordLocked(entry)
+	d.mu.Unlock()
+}
+
+// recordLocked appends to the bounded delivery log.
+func (d *Dispatcher) recordLocked(entry Delivery) {
+	if entry.ID == 0 {
+		d.nextID++
+		entry.ID = d.nextID
+	}
+	d.log = append(d.log, entry)
+	if len(d.log) > logSize {
+		d.log = append([]Delivery(nil), d.log[len(d.log)-logSize:]...)
+	}
+}
+
+// post makes one delivery attempt and returns the response status.
+func (d *Dispatcher) post(ctx context.Context, url string, id int64, typ string, body []byte) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, d.cfg.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	ts := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "DeskSlice-Webhook")
+	req.Header.Set(HeaderEvent, typ)
+	req.Header.Set(HeaderDelivery, strconv.FormatInt(id, 10))
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(ts, 10))
+	if d.cfg.Secret != "" {
+		req.Header.Set(HeaderSignature, Sign(d.cfg.Secret, ts, body))
+	}
+	d.mu.Lock()
+	client := d.client
+	d.mu.Unlock()
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	return resp.StatusCode, nil
+}
+
+// Sign returns the signature header value for a body sent at the given Unix time.
+// Receivers recompute it and reject stale timestamps to stop replays.
+func Sign(secret string, ts int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", ts)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
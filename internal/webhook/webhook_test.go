@@ -0,0 +1,86 @@
+package webhook
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/frudas24/deskslice/internal/events"
+)
+
+// waitDeliveries polls the log until it holds n entries.
+func waitDeliveries(t *testing.T, d *Dispatcher, n int) []Delivery {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if got := d.Deliveries(); len(got) >= n {
+			return got
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("expected %d deliveries, got %+v", n, d.Deliveries())
+	return nil
+}
+
+// TestDispatcher_SignsAndRetries verifies a signed delivery is retried after a 5xx and logged once.
+func TestDispatcher_SignsAndRetries(t *testing.T) {
+	var calls atomic.Int32
+	badSig := make(chan string, 4)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		ts, _ := strconv.ParseInt(r.Header.Get(HeaderTimestamp), 10, 64)
+		if got := r.Header.Get(HeaderSignature); got != Sign("s3cret", ts, body) || r.Header.Get(HeaderEvent) != events.CalibSaved {
+			badSig <- got
+		}
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	bus := events.NewBus()
+	d := New(Config{URLs: []string{receiver.URL}, Secret: "s3cret", Retries: 2, Backoff: 10 * time.Millisecond})
+	d.Start(bus)
+	defer d.Close()
+	bus.Publish(events.CalibSaved, nil)
+
+	got := waitDeliveries(t, d, 1)
+	if !got[0].OK || got[0].Attempts != 2 || got[0].Status != http.StatusNoContent || got[0].Event != events.CalibSaved {
+		t.Fatalf("unexpected delivery %+v", got[0])
+	}
+	select {
+	case sig := <-badSig:
+		t.Fatalf("bad signature or event header: %q", sig)
+	default:
+	}
+}
+
+// TestDispatcher_FiltersAndStopsOnClientErrors verifies unselected events are skipped and
+// a 4xx answer is not retried.
+func TestDispatcher_FiltersAndStopsOnClientErrors(t *testing.T) {
+	var calls atomic.Int32
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer receiver.Close()
+
+	bus := events.NewBus()
+	d := New(Config{URLs: []string{receiver.URL}, Events: []string{events.ModeChanged}, Retries: 3, Backoff: 10 * time.Millisecond})
+	d.Start(bus)
+	defer d.Close()
+	bus.Publish(events.InputToggled, map[string]any{"enabled": true})
+	bus.Publish(events.ModeChanged, map[string]any{"mode": "run"})
+
+	got := waitDeliveries(t, d, 1)
+	time.Sleep(50 * time.Millisecond)
+	if len(d.Deliveries()) != 1 || got[0].OK || got[0].Attempts != 1 || got[0].Event != events.ModeChanged || calls.Load() != 1 {
+		t.Fatalf("unexpected deliveries %+v after %d calls", d.Deliveries(), calls.Load())
+	}
+}
@@ -0,0 +1,155 @@
+// Package webrtc provides the WebRTC publisher pipeline.
+package webrtc
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/pion/webrtc/v3"
+)
+
+// Codec names accepted in the publisher's preference list (and passed on to ffmpeg).
+const (
+	CodecH264 = "h264"
+	CodecVP8  = "vp8"
+	CodecVP9  = "vp9"
+	CodecAV1  = "av1"
+)
+
+// codecCapabilities maps codec names to the capability used for the outgoing track.
+var codecCapabilities = map[string]webrtc.RTPCodecCapability{
+	CodecH264: {MimeType: webrtc.MimeTypeH264},
+	CodecVP8:  {MimeType: webrtc.MimeTypeVP8},
+	CodecVP9:  {MimeType: webrtc.MimeTypeVP9, SDPFmtpLine: "profile-id=0"},
+	CodecAV1:  {MimeType: webrtc.MimeTypeAV1},
+}
+
+// ErrCodecUnsupported is returned when a view-only peer's offer cannot decode the codec
+// the publisher is streaming.
+var ErrCodecUnsupported = errors.New("offer does not support the current codec")
+
+// codecOrder is the fallback order for codecs missing from the preference list.
+var codecOrder = []string{CodecH264, CodecVP8, CodecVP9, CodecAV1}
+
+// NormalizeCodecs lowercases the preference list and drops unknown and duplicate names.
+// Supported codecs that are not listed are appended (H264 first), so an offer that only
+// carries one codec, e.g. because the client restricted it, can still be served.
+func NormalizeCodecs(codecs []string) []string {
+	out := make([]string, 0, len(codecOrder))
+	seen := make(map[string]bool, len(codecOrder))
+	names := append(append([]string(nil), codecs...), codecOrder...)
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if _, ok := codecCapabilities[name]; !ok || seen[name] {
+			continue
+		}
+		seen[name] = true
+		out = append(out, name)
+	}
+	return out
+}
+
+// pickCodec returns the first preferred codec present in the negotiated parameters.
+func pickCodec(prefs []string, negotiated []webrtc.RTPCodecParameters) (string, bool) {
+	for _, name := range prefs {
+		mime := codecCapabilities[name].MimeType
+		for _, c := range negotiated {
+			if strings.EqualFold(c.MimeType, mime) {
+				return name, true
+			}
+		}
+	}
+	return "", false
+}
+
+// SetCodecHandler registers a callback invoked when negotiation switches the track codec,
+// so the encoder can be restarted with matching settings.
+func (p *Publisher) SetCodecHandler(fn func(codec string)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.codecHandler = fn
+}
+
+// Codec returns the codec of the current video track ("" for a nil publisher).
+func (p *Publisher) Codec() string {
+	if p == nil {
+		return ""
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.codec
+}
+
+// NegotiateCodec picks the preferred codec supported by the remote offer and swaps the
+// peer's video track to it. Call it after SetRemoteDescription and before CreateAnswer.
+func (p *Publisher) NegotiateCodec(peer *webrtc.PeerConnection) (string, error) {
+	sender, err := videoSender(peer)
+	if err != nil {
+		return "", err
+	}
+
+	p.mu.Lock()
+	codec, ok := pickCodec(p.codecs, sender.GetParameters().Codecs)
+	if !ok {
+		p.mu.Unlock()
+		return "", errors.New("offer supports none of the configured codecs")
+	}
+	changed := codec != p.codec
+	p.codec = codec
+	track, err := p.ensureTrack()
+	handler := p.codecHandler
+	p.mu.Unlock()
+	if err != nil {
+		return "", err
+	}
+
+	if err := sender.ReplaceTrack(track); err != nil {
+		return "", err
+	}
+	if changed && handler != nil {
+		handler(codec)
+	}
+	return codec, nil
+}
+
+// AcceptViewerCodec checks that a view-only peer's offer supports the codec currently
+// streamed and attaches that codec's track. Unlike NegotiateCodec it never switches the
+// codec: that would rebind the shared sink to a track the UI viewer is not sending, and
+// freeze it. It returns ErrCodecUnsupported when the offer lacks the current codec.
+func (p *Publisher) AcceptViewerCodec(peer *webrtc.PeerConnection) (string, error) {
+	sender, err := videoSender(peer)
+	if err != nil {
+		return "", err
+	}
+
+	p.mu.Lock()
+	codec := p.codec
+	track, err := p.ensureTrack()
+	p.mu.Unlock()
+	if err != nil {
+		return "", err
+	}
+	if _, ok := pickCodec([]string{codec}, sender.GetParameters().Codecs); !ok {
+		return "", fmt.Errorf("%w (%s)", ErrCodecUnsupported, codec)
+	}
+	if sender.Track() != track {
+		if err := sender.ReplaceTrack(track); err != nil {
+			return "", err
+		}
+	}
+	return codec, nil
+}
+
+// videoSender returns the peer's video sender.
+func videoSender(peer *webrtc.PeerConnection) (*webrtc.RTPSender, error) {
+	if peer == nil {
+		return nil, errors.New("peer is nil")
+	}
+	for _, s := range peer.GetSenders() {
+		if track := s.Track(); track != nil && track.Kind() == webrtc.RTPCodecTypeVideo {
+			return s, nil
+		}
+	}
+	return nil, errors.New("peer has no video sender")
+}
@@ -0,0 +1,77 @@
+package webrtc
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pion/webrtc/v3"
+)
+
+// TestNormalizeCodecs_DedupesAndAppendsFallbacks verifies unknown names are dropped and unlisted codecs follow, H264 first.
+func TestNormalizeCodecs_DedupesAndAppendsFallbacks(t *testing.T) {
+	got := NormalizeCodecs([]string{" VP9", "vp8", "hevc", "vp9"})
+	want := []string{CodecVP9, CodecVP8, CodecH264, CodecAV1}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+}
+
+// TestNegotiateCodec_PicksPreferredCodecFromOffer verifies the track switches to the best codec the offer supports.
+func TestNegotiateCodec_PicksPreferredCodecFromOffer(t *testing.T) {
+	pub, err := NewPublisher(ICEConfig{}, []string{CodecAV1, CodecVP8})
+	if err != nil {
+		t.Fatalf("new publisher: %v", err)
+	}
+	var switched string
+	pub.SetCodecHandler(func(codec string) { switched = codec })
+	server, err := pub.NewPeer()
+	if err != nil {
+		t.Fatalf("new peer: %v", err)
+	}
+	defer pub.ClosePeer()
+
+	// The client only decodes VP8 and H264, so AV1 cannot be picked.
+	media := &webrtc.MediaEngine{}
+	for _, c := range []webrtc.RTPCodecParameters{
+		{RTPCodecCapability: webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeH264, ClockRate: 90000}, PayloadType: 102},
+		{RTPCodecCapability: webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeVP8, ClockRate: 90000}, PayloadType: 96},
+	} {
+		if err := media.RegisterCodec(c, webrtc.RTPCodecTypeVideo); err != nil {
+			t.Fatalf("register codec: %v", err)
+		}
+	}
+	client, err := webrtc.NewAPI(webrtc.WithMediaEngine(media)).NewPeerConnection(webrtc.Configuration{})
+	if err != nil {
+		t.Fatalf("client peer: %v", err)
+	}
+	defer client.Close()
+	if _, err := client.AddTransceiverFromKind(webrtc.RTPCodecTypeVideo, webrtc.RTPTransceiverInit{Direction: webrtc.RTPTransceiverDirectionRecvonly}); err != nil {
+		t.Fatalf("add transceiver: %v", err)
+	}
+	offer, err := client.CreateOffer(nil)
+	if err != nil {
+		t.Fatalf("create offer: %v", err)
+	}
+	if err := server.SetRemoteDescription(offer); err != nil {
+		t.Fatalf("set remote offer: %v", err)
+	}
+
+	codec, err := pub.NegotiateCodec(server)
+	if err != nil {
+		t.Fatalf("negotiate: %v", err)
+	}
+	if codec != CodecVP8 || pub.Codec() != CodecVP8 || switched != CodecVP8 {
+		t.Fatalf("expected vp8, got codec=%q current=%q switched=%q", codec, pub.Codec(), switched)
+	}
+	track, err := pub.Track()
+	if err != nil {
+		t.Fatalf("track: %v", err)
+	}
+	if !strings.EqualFold(track.Codec().MimeType, webrtc.MimeTypeVP8) {
+		t.Fatalf("expected VP8 track, got %s", track.Codec().MimeType)
+	}
+	if got := server.GetSenders()[0].Track(); got != track {
+		t.Fatalf("sender still carries the previous track")
+	}
+}
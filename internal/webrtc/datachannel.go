@@ -0,0 +1,85 @@
+// Package webrtc provides the WebRTC publisher pipeline.
+package webrtc
+
+import (
+	"log"
+
+	"github.com/pion/webrtc/v3"
+)
+
+// Control data channels are negotiated out-of-band: the web client creates channels with the same
+// labels and IDs before its offer, so no extra signaling round trip is needed. Peers that do not
+// create them simply never open the channels.
+const (
+	// ControlFastLabel carries pointer moves: unordered and without retransmissions.
+	ControlFastLabel = "control-fast"
+	// ControlFastID is the SCTP stream ID of the fast control channel.
+	ControlFastID uint16 = 1
+	// ControlReliableLabel carries clicks, typing and everything else: ordered and reliable.
+	ControlReliableLabel = "control"
+	// ControlReliableID is the SCTP stream ID of the reliable control channel.
+	ControlReliableID uint16 = 2
+	// controlQueueSize bounds messages waiting for the control handler; more are dropped.
+	controlQueueSize = 256
+)
+
+// SetControlHandler registers the callback receiving control payloads from peer data channels.
+func (p *Publisher) SetControlHandler(fn func(data []byte)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.controlHandler = fn
+}
+
+// AttachControlChannels creates the negotiated control channels on the UI viewer's peer.
+// Only the signaling path calls it: WHEP players are view-only and never get input channels.
+func (p *Publisher) AttachControlChannels(peer *webrtc.PeerConnection) error {
+	p.controlOnce.Do(func() {
+		p.controlQueue = make(chan []byte, controlQueueSize)
+		go p.runControl(p.controlQueue)
+	})
+	negotiated := true
+	unordered := false
+	noRetransmits := uint16(0)
+	channels := []struct {
+		label string
+		init  webrtc.DataChannelInit
+	}{
+		{ControlFastLabel, webrtc.DataChannelInit{Negotiated: &negotiated, ID: ptrUint16(ControlFastID), Ordered: &unordered, MaxRetransmits: &noRetransmits}},
+		{ControlReliableLabel, webrtc.DataChannelInit{Negotiated: &negotiated, ID: ptrUint16(ControlReliableID)}},
+	}
+	for _, ch := range channels {
+		init := ch.init
+		dc, err := peer.CreateDataChannel(ch.label, &init)
+		if err != nil {
+			return err
+		}
+		label := ch.label
+		dc.OnMessage(func(msg webrtc.DataChannelMessage) {
+			select {
+			case p.controlQueue <- append([]byte(nil), msg.Data...):
+			default:
+				log.Printf("webrtc: control queue full, dropping %s message", label)
+			}
+		})
+	}
+	return nil
+}
+
+// runControl hands queued control messages to the handler. It runs on its own goroutine
+// because the handler may restart the pipeline or close the peer, which must not happen on
+// that peer's SCTP read loop.
+func (p *Publisher) runControl(queue <-chan []byte) {
+	for data := range queue {
+		p.mu.Lock()
+		handler := p.controlHandler
+		p.mu.Unlock()
+		if handler != nil {
+			handler(data)
+		}
+	}
+}
+
+// ptrUint16 returns a pointer to v.
+func ptrUint16(v uint16) *uint16 {
+	return &v
+}
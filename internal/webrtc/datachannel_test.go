@@ -0,0 +1,141 @@
+package webrtc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pion/webrtc/v3"
+)
+
+// TestNewPeer_NegotiatedControlChannelDeliversMessages verifies client messages on the negotiated channel reach the control handler.
+func TestNewPeer_NegotiatedControlChannelDeliversMessages(t *testing.T) {
+	pub, err := NewPublisher(ICEConfig{}, nil)
+	if err != nil {
+		t.Fatalf("new publisher: %v", err)
+	}
+	got := make(chan string, 1)
+	pub.SetControlHandler(func(data []byte) { got <- string(data) })
+	server, err := pub.NewPeer()
+	if err != nil {
+		t.Fatalf("new peer: %v", err)
+	}
+	defer pub.ClosePeer()
+	if err := pub.AttachControlChannels(server); err != nil {
+		t.Fatalf("attach channels: %v", err)
+	}
+
+	client := clickingClient(t, server)
+	defer client.Close()
+
+	select {
+	case msg := <-got:
+		if msg != `{"t":"click"}` {
+			t.Fatalf("unexpected message: %q", msg)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatalf("timed out waiting for data channel message")
+	}
+}
+
+// TestControlHandler_MayClosePeer verifies the handler runs off the peer's SCTP loop, so it
+// can close the peer the message arrived on.
+func TestControlHandler_MayClosePeer(t *testing.T) {
+	pub, err := NewPublisher(ICEConfig{}, nil)
+	if err != nil {
+		t.Fatalf("new publisher: %v", err)
+	}
+	closed := make(chan struct{}, 1)
+	pub.SetControlHandler(func([]byte) {
+		pub.ClosePeer()
+		closed <- struct{}{}
+	})
+	server, err := pub.NewPeer()
+	if err != nil {
+		t.Fatalf("new peer: %v", err)
+	}
+	if err := pub.AttachControlChannels(server); err != nil {
+		t.Fatalf("attach channels: %v", err)
+	}
+	client := clickingClient(t, server)
+	defer client.Close()
+
+	select {
+	case <-closed:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("handler closing the peer did not return")
+	}
+}
+
+// TestNewPeer_HasNoControlChannels verifies peers without AttachControlChannels (WHEP
+// players) cannot deliver input.
+func TestNewPeer_HasNoControlChannels(t *testing.T) {
+	pub, err := NewPublisher(ICEConfig{}, nil)
+	if err != nil {
+		t.Fatalf("new publisher: %v", err)
+	}
+	got := make(chan string, 1)
+	pub.SetControlHandler(func(data []byte) { got <- string(data) })
+	server, err := pub.NewPeer()
+	if err != nil {
+		t.Fatalf("new peer: %v", err)
+	}
+	defer pub.ClosePeer()
+	client := clickingClient(t, server)
+	defer client.Close()
+
+	select {
+	case msg := <-got:
+		t.Fatalf("view-only peer delivered %q", msg)
+	case <-time.After(time.Second):
+	}
+}
+
+// clickingClient connects a browser-like peer to server that sends a click on the
+// negotiated reliable channel as soon as it opens.
+func clickingClient(t *testing.T, server *webrtc.PeerConnection) *webrtc.PeerConnection {
+	t.Helper()
+	client, err := webrtc.NewPeerConnection(webrtc.Configuration{})
+	if err != nil {
+		t.Fatalf("client peer: %v", err)
+	}
+	if _, err := client.AddTransceiverFromKind(webrtc.RTPCodecTypeVideo, webrtc.RTPTransceiverInit{Direction: webrtc.RTPTransceiverDirectionRecvonly}); err != nil {
+		t.Fatalf("add transceiver: %v", err)
+	}
+	negotiated := true
+	dc, err := client.CreateDataChannel(ControlReliableLabel, &webrtc.DataChannelInit{Negotiated: &negotiated, ID: ptrUint16(ControlReliableID)})
+	if err != nil {
+		t.Fatalf("create channel: %v", err)
+	}
+	dc.OnOpen(func() { _ = dc.SendText(`{"t":"click"}`) })
+	exchange(t, client, server)
+	return client
+}
+
+// exchange performs a non-trickle offer/answer between an offering and an answering peer.
+func exchange(t *testing.T, offerer, answerer *webrtc.PeerConnection) {
+	t.Helper()
+	offer, err := offerer.CreateOffer(nil)
+	if err != nil {
+		t.Fatalf("create offer: %v", err)
+	}
+	gathered := webrtc.GatheringCompletePromise(offerer)
+	if err := offerer.SetLocalDescription(offer); err != nil {
+		t.Fatalf("set local offer: %v", err)
+	}
+	<-gathered
+	if err := answerer.SetRemoteDescription(*offerer.LocalDescription()); err != nil {
+		t.Fatalf("set remote offer: %v", err)
+	}
+	answer, err := answerer.CreateAnswer(nil)
+	if err != nil {
+		t.Fatalf("create answer: %v", err)
+	}
+	gathered = webrtc.GatheringCompletePromise(answerer)
+	if err := answerer.SetLocalDescription(answer); err != nil {
+		t.Fatalf("set local answer: %v", err)
+	}
+	<-gathered
+	if err := offerer.SetRemoteDescription(*answerer.LocalDescription()); err != nil {
+		t.Fatalf("set remote answer: %v", err)
+	}
+}
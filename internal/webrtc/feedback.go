@@ -0,0 +1,142 @@
+// Package webrtc provides the WebRTC publisher pipeline.
+package webrtc
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pion/rtcp"
+)
+
+const (
+	// nackHistorySize is the number of sent packets kept for retransmission (power of two).
+	nackHistorySize = 2048
+	// keyframeTimeout is how long a PLI/FIR may wait for the encoder's next keyframe before
+	// the recovery handler is asked to restart it.
+	keyframeTimeout = 2 * time.Second
+	// recoveryCooldown bounds how often a keyframe request may restart the encoder.
+	recoveryCooldown = 10 * time.Second
+)
+
+// FeedbackStats counts receiver feedback handled by the publisher. Keyframes counts the
+// requests satisfied by the encoder's regular GOP; Restarts the ones that needed a restart.
+type FeedbackStats struct {
+	NACKs     uint64 `json:"nacks"`
+	PLIs      uint64 `json:"plis"`
+	FIRs      uint64 `json:"firs"`
+	Keyframes uint64 `json:"keyframes"`
+	Restarts  uint64 `json:"restarts"`
+}
+
+// feedback tracks NACK/PLI/FIR counters and pending keyframe requests. The encoder emits
+// keyframes on a fixed GOP, so a request normally resolves on its own within a second.
+type feedback struct {
+	mu          sync.Mutex
+	stats       FeedbackStats
+	pending     bool
+	lastRestart time.Time
+	handler     func()
+	timer       *time.Timer
+	timeout     time.Duration
+}
+
+// setHandler registers the callback used when a keyframe request times out.
+func (f *feedback) setHandler(fn func()) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.handler = fn
+}
+
+// snapshot returns a copy of the counters.
+func (f *feedback) snapshot() FeedbackStats {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.stats
+}
+
+// handleRTCP counts NACKed packets and turns PLI/FIR into keyframe requests.
+// Retransmissions themselves are served by the NACK responder interceptor.
+func (f *feedback) handleRTCP(pkts []rtcp.Packet) {
+	for _, pkt := range pkts {
+		switch p := pkt.(type) {
+		case *rtcp.TransportLayerNack:
+			var n uint64
+			for _, pair := range p.Nacks {
+				n += uint64(len(pair.PacketList()))
+			}
+			f.mu.Lock()
+			f.stats.NACKs += n
+			f.mu.Unlock()
+		case *rtcp.PictureLossIndication:
+			f.requestKeyframe(&f.stats.PLIs)
+		case *rtcp.FullIntraRequest:
+			f.requestKeyframe(&f.stats.FIRs)
+		}
+	}
+}
+
+// requestKeyframe bumps a counter and arms the keyframe watchdog if none is pending.
+func (f *feedback) requestKeyframe(counter *uint64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	*counter++
+	if f.pending {
+		return
+	}
+	f.pending = true
+	timeout := f.timeout
+	if timeout <= 0 {
+		timeout = keyframeTimeout
+	}
+	f.timer = time.AfterFunc(timeout, f.expire)
+}
+
+// observeKeyframe records a forwarded keyframe, satisfying any pending request.
+func (f *feedback) observeKeyframe() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.pending {
+		return
+	}
+	f.pending = false
+	f.stats.Keyframes++
+	if f.timer != nil {
+		f.timer.Stop()
+		f.timer = nil
+	}
+}
+
+// expire runs when no keyframe followed a request in time and triggers a controlled restart.
+func (f *feedback) expire() {
+	f.mu.Lock()
+	if !f.pending {
+		f.mu.Unlock()
+		return
+	}
+	f.pending = false
+	f.timer = nil
+	handler := f.handler
+	now := time.Now()
+	if handler == nil || (!f.lastRestart.IsZero() && now.Sub(f.lastRestart) < recoveryCooldown) {
+		f.mu.Unlock()
+		return
+	}
+	f.lastRestart = now
+	f.stats.Restarts++
+	f.mu.Unlock()
+	handler()
+}
+
+// SetRecoveryHandler registers the callback that restarts the encoder when a PLI/FIR is not
+// followed by a keyframe in time.
+func (p *Publisher) SetRecoveryHandler(fn func()) {
+	p.feedback.setHandler(fn)
+}
+
+// FeedbackStats returns the NACK/PLI/FIR counters (zero for a nil publisher).
+func (p *Publisher) FeedbackStats() FeedbackStats {
+	if p == nil {
+		return FeedbackStats{}
+	}
+	return p.feedback.snapshot()
+}
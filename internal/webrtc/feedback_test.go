@@ -0,0 +1,63 @@
+package webrtc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pion/rtcp"
+)
+
+// TestFeedback_CountsNACKedPackets verifies every sequence number in a NACK is counted.
+func TestFeedback_CountsNACKedPackets(t *testing.T) {
+	var f feedback
+	f.handleRTCP([]rtcp.Packet{&rtcp.TransportLayerNack{
+		Nacks: []rtcp.NackPair{{PacketID: 100, LostPackets: 0b101}},
+	}})
+	if got := f.snapshot().NACKs; got != 3 {
+		t.Fatalf("expected 3 nacked packets, got %d", got)
+	}
+}
+
+// TestFeedback_KeyframeSatisfiesPLI verifies a keyframe after a PLI cancels the restart.
+func TestFeedback_KeyframeSatisfiesPLI(t *testing.T) {
+	f := feedback{timeout: 20 * time.Millisecond}
+	restarted := make(chan struct{}, 1)
+	f.setHandler(func() { restarted <- struct{}{} })
+
+	f.handleRTCP([]rtcp.Packet{&rtcp.PictureLossIndication{}, &rtcp.FullIntraRequest{}})
+	f.observeKeyframe()
+
+	select {
+	case <-restarted:
+		t.Fatalf("unexpected encoder restart")
+	case <-time.After(60 * time.Millisecond):
+	}
+	stats := f.snapshot()
+	if stats.PLIs != 1 || stats.FIRs != 1 || stats.Keyframes != 1 || stats.Restarts != 0 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+}
+
+// TestFeedback_MissingKeyframeRestartsOnce verifies a timed-out PLI restarts the encoder, rate limited by the cooldown.
+func TestFeedback_MissingKeyframeRestartsOnce(t *testing.T) {
+	f := feedback{timeout: 10 * time.Millisecond}
+	restarted := make(chan struct{}, 2)
+	f.setHandler(func() { restarted <- struct{}{} })
+
+	f.handleRTCP([]rtcp.Packet{&rtcp.PictureLossIndication{}})
+	select {
+	case <-restarted:
+	case <-time.After(time.Second):
+		t.Fatalf("expected encoder restart")
+	}
+
+	f.handleRTCP([]rtcp.Packet{&rtcp.PictureLossIndication{}})
+	select {
+	case <-restarted:
+		t.Fatalf("restart should be suppressed during the cooldown")
+	case <-time.After(50 * time.Millisecond):
+	}
+	if got := f.snapshot().Restarts; got != 1 {
+		t.Fatalf("expected 1 restart, got %d", got)
+	}
+}
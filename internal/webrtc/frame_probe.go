@@ -0,0 +1,75 @@
+// Package webrtc provides the WebRTC publisher pipeline.
+package webrtc
+
+import (
+	"sync"
+	"time"
+)
+
+// frameProbes tracks callers waiting for the first frame started after an instant to be forwarded.
+type frameProbes struct {
+	mu      sync.Mutex
+	waiters []*frameWaiter
+}
+
+// frameWaiter is a pending probe. It arms on the first packet of a new frame read after the
+// requested instant and fires once that frame's last (marker) packet has been written.
+type frameWaiter struct {
+	after time.Time
+	armed bool
+	ch    chan time.Time
+}
+
+// wait registers a probe and returns a channel receiving the send time of the matching frame.
+func (f *frameProbes) wait(after time.Time) <-chan time.Time {
+	w := &frameWaiter{after: after, ch: make(chan time.Time, 1)}
+	f.mu.Lock()
+	f.waiters = append(f.waiters, w)
+	f.mu.Unlock()
+	return w.ch
+}
+
+// cancel drops a probe that is no longer awaited.
+func (f *frameProbes) cancel(ch <-chan time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i, w := range f.waiters {
+		if w.ch == ch {
+			f.waiters = append(f.waiters[:i:i], f.waiters[i+1:]...)
+			return
+		}
+	}
+}
+
+// observe is called for every forwarded packet.
+func (f *frameProbes) observe(frameStart, marker bool, now time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.waiters) == 0 {
+		return
+	}
+	kept := f.waiters[:0]
+	for _, w := range f.waiters {
+		if frameStart && !w.armed && !now.Before(w.after) {
+			w.armed = true
+		}
+		if marker && w.armed {
+			w.ch <- now
+			continue
+		}
+		kept = append(kept, w)
+	}
+	f.waiters = kept
+}
+
+// NextFrameSent returns a channel receiving the time at which the first video frame started
+// after the given instant finished leaving the RTP forward loop. Callers should give up after a
+// timeout (e.g. in MJPEG mode no frames are forwarded) and call CancelFrameProbe.
+func (p *Publisher) NextFrameSent(after time.Time) <-chan time.Time {
+	return p.probes.wait(after)
+}
+
+// CancelFrameProbe drops a pending NextFrameSent probe.
+func (p *Publisher) CancelFrameProbe(ch <-chan time.Time) {
+	p.probes.cancel(ch)
+}
@@ -0,0 +1,47 @@
+package webrtc
+
+import (
+	"testing"
+	"time"
+)
+
+// TestFrameProbes_FiresOnFirstFrameStartedAfterInstant verifies frames already in flight are skipped.
+func TestFrameProbes_FiresOnFirstFrameStartedAfterInstant(t *testing.T) {
+	var probes frameProbes
+	t0 := time.Unix(100, 0)
+	ch := probes.wait(t0)
+
+	// A frame that started before the probe completes after it: not the matching frame.
+	probes.observe(true, false, t0.Add(-time.Millisecond))
+	probes.observe(false, true, t0.Add(time.Millisecond))
+	select {
+	case <-ch:
+		t.Fatalf("probe fired on a frame started before the input")
+	default:
+	}
+
+	probes.observe(true, false, t0.Add(10*time.Millisecond))
+	probes.observe(false, false, t0.Add(11*time.Millisecond))
+	probes.observe(false, true, t0.Add(12*time.Millisecond))
+	select {
+	case at := <-ch:
+		if !at.Equal(t0.Add(12 * time.Millisecond)) {
+			t.Fatalf("unexpected send time: %v", at)
+		}
+	default:
+		t.Fatalf("expected probe to fire")
+	}
+	if len(probes.waiters) != 0 {
+		t.Fatalf("expected waiter to be removed")
+	}
+}
+
+// TestFrameProbes_Cancel verifies cancelled probes are dropped.
+func TestFrameProbes_Cancel(t *testing.T) {
+	var probes frameProbes
+	ch := probes.wait(time.Now())
+	probes.cancel(ch)
+	if len(probes.waiters) != 0 {
+		t.Fatalf("expected waiter to be removed")
+	}
+}
@@ -0,0 +1,97 @@
+// Package webrtc provides the WebRTC publisher pipeline.
+package webrtc
+
+import (
+	"fmt"
+	"io"
+	"net"
+
+	"github.com/pion/webrtc/v3"
+)
+
+// iceTCPReadBuffer is the number of packets buffered per ICE-TCP connection.
+const iceTCPReadBuffer = 8
+
+// ICEServer is a STUN or TURN server used when gathering candidates.
+type ICEServer struct {
+	URLs       []string
+	Username   string
+	Credential string
+}
+
+// ICEConfig tunes candidate gathering for every peer the publisher creates.
+// The zero value keeps pion's defaults (host candidates on random UDP ports).
+type ICEConfig struct {
+	Servers    []ICEServer
+	UDPPortMin uint16
+	UDPPortMax uint16
+	UDPMuxPort int
+	NAT1To1IPs []string
+	TCPPort    int
+}
+
+// iceServers converts the configured servers into pion's representation.
+func (c ICEConfig) iceServers() []webrtc.ICEServer {
+	if len(c.Servers) == 0 {
+		return nil
+	}
+	out := make([]webrtc.ICEServer, 0, len(c.Servers))
+	for _, s := range c.Servers {
+		server := webrtc.ICEServer{URLs: s.URLs, Username: s.Username}
+		if s.Credential != "" {
+			server.Credential = s.Credential
+			server.CredentialType = webrtc.ICECredentialTypePassword
+		}
+		out = append(out, server)
+	}
+	return out
+}
+
+// settingEngine builds a SettingEngine for the config. The returned closers own the
+// muxed UDP/TCP sockets and must be closed when the publisher shuts down.
+func (c ICEConfig) settingEngine() (webrtc.SettingEngine, []io.Closer, error) {
+	var (
+		se      webrtc.SettingEngine
+		closers []io.Closer
+	)
+	fail := func(err error) (webrtc.SettingEngine, []io.Closer, error) {
+		for _, closer := range closers {
+			_ = closer.Close()
+		}
+		return webrtc.SettingEngine{}, nil, err
+	}
+
+	if c.UDPPortMin != 0 || c.UDPPortMax != 0 {
+		if err := se.SetEphemeralUDPPortRange(c.UDPPortMin, c.UDPPortMax); err != nil {
+			return fail(fmt.Errorf("ice udp port range: %w", err))
+		}
+	}
+	if len(c.NAT1To1IPs) > 0 {
+		se.SetNAT1To1IPs(c.NAT1To1IPs, webrtc.ICECandidateTypeHost)
+	}
+	if c.UDPMuxPort > 0 {
+		conn, err := net.ListenUDP("udp", &net.UDPAddr{Port: c.UDPMuxPort})
+		if err != nil {
+			return fail(fmt.Errorf("ice udp mux: %w", err))
+		}
+		mux := webrtc.NewICEUDPMux(nil, conn)
+		closers = append(closers, mux)
+		se.SetICEUDPMux(mux)
+	}
+	if c.TCPPort > 0 {
+		listener, err := net.ListenTCP("tcp", &net.TCPAddr{Port: c.TCPPort})
+		if err != nil {
+			return fail(fmt.Errorf("ice tcp: %w", err))
+		}
+		mux := webrtc.NewICETCPMux(nil, listener, iceTCPReadBuffer)
+		closers = append(closers, mux)
+		se.SetICETCPMux(mux)
+		se.SetNetworkTypes([]webrtc.NetworkType{
+			webrtc.NetworkTypeUDP4,
+			webrtc.NetworkTypeUDP6,
+			webrtc.NetworkTypeTCP4,
+			webrtc.NetworkTypeTCP6,
+		})
+	}
+	return se, closers, nil
+}
@@ -0,0 +1,53 @@
+package webrtc
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/pion/webrtc/v3"
+)
+
+// TestNewPublisher_MuxedPortAndNAT1To1Candidates verifies host candidates advertise the NAT 1:1 IP on the muxed UDP port.
+func TestNewPublisher_MuxedPortAndNAT1To1Candidates(t *testing.T) {
+	probe, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("probe port: %v", err)
+	}
+	port := probe.LocalAddr().(*net.UDPAddr).Port
+	_ = probe.Close()
+
+	pub, err := NewPublisher(ICEConfig{UDPMuxPort: port, NAT1To1IPs: []string{"203.0.113.10"}}, nil)
+	if err != nil {
+		t.Fatalf("new publisher: %v", err)
+	}
+	defer pub.CloseICE()
+	server, err := pub.NewPeer()
+	if err != nil {
+		t.Fatalf("new peer: %v", err)
+	}
+	defer pub.ClosePeer()
+
+	client, err := webrtc.NewPeerConnection(webrtc.Configuration{})
+	if err != nil {
+		t.Fatalf("client peer: %v", err)
+	}
+	defer client.Close()
+	if _, err := client.AddTransceiverFromKind(webrtc.RTPCodecTypeVideo, webrtc.RTPTransceiverInit{Direction: webrtc.RTPTransceiverDirectionRecvonly}); err != nil {
+		t.Fatalf("add transceiver: %v", err)
+	}
+	exchange(t, client, server)
+
+	want := fmt.Sprintf("203.0.113.10 %d typ host", port)
+	if sdp := server.LocalDescription().SDP; !strings.Contains(sdp, want) {
+		t.Fatalf("expected candidate %q in answer:\n%s", want, sdp)
+	}
+}
+
+// TestNewPublisher_RejectsInvertedPortRange verifies an invalid ephemeral UDP range fails early.
+func TestNewPublisher_RejectsInvertedPortRange(t *testing.T) {
+	if _, err := NewPublisher(ICEConfig{UDPPortMin: 50100, UDPPortMax: 50000}, nil); err == nil {
+		t.Fatalf("expected error for inverted port range")
+	}
+}
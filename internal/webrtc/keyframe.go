@@ -0,0 +1,83 @@
+// Package webrtc provides the WebRTC publisher pipeline.
+package webrtc
+
+import (
+	"encoding/binary"
+	"strings"
+
+	"github.com/pion/webrtc/v3"
+)
+
+// isKeyframePacket reports whether an RTP payload starts (or carries) a keyframe for the
+// codec. It only inspects payload descriptors, so it is cheap enough for every packet.
+func isKeyframePacket(mime string, payload []byte) bool {
+	if len(payload) == 0 {
+		return false
+	}
+	switch {
+	case strings.EqualFold(mime, webrtc.MimeTypeH264):
+		return isH264Keyframe(payload)
+	case strings.EqualFold(mime, webrtc.MimeTypeVP8):
+		return isVP8Keyframe(payload)
+	case strings.EqualFold(mime, webrtc.MimeTypeVP9):
+		// B (start of frame) set and P (inter-picture predicted) clear.
+		return payload[0]&0x08 != 0 && payload[0]&0x40 == 0
+	case strings.EqualFold(mime, webrtc.MimeTypeAV1):
+		// N: first packet of a new coded video sequence.
+		return payload[0]&0x08 != 0
+	default:
+		return false
+	}
+}
+
+// isH264Keyframe looks for an IDR slice or SPS in single NAL, STAP-A and FU-A packets.
+func isH264Keyframe(payload []byte) bool {
+	isKey := func(nalType byte) bool { return nalType == 5 || nalType == 7 }
+	switch nalType := payload[0] & 0x1F; nalType {
+	case 24: // STAP-A
+		for offset := 1; offset+2 < len(payload); {
+			size := int(binary.BigEndian.Uint16(payload[offset:]))
+			if isKey(payload[offset+2] & 0x1F) {
+				return true
+			}
+			offset += 2 + size
+		}
+		return false
+	case 28: // FU-A
+		return len(payload) > 1 && payload[1]&0x80 != 0 && isKey(payload[1]&0x1F)
+	default:
+		return isKey(nalType)
+	}
+}
+
+// isVP8Keyframe parses the VP8 payload descriptor and checks the frame header P bit.
+func isVP8Keyframe(payload []byte) bool {
+	// Keyframes are only signaled in the first packet of partition 0 (S=1, PID=0).
+	if payload[0]&0x10 == 0 || payload[0]&0x07 != 0 {
+		return false
+	}
+	offset := 1
+	if payload[0]&0x80 != 0 {
+		if len(payload) <= offset {
+			return false
+		}
+		ext := payload[offset]
+		offset++
+		if ext&0x80 != 0 { // PictureID, 7 or 15 bits
+			if len(payload) <= offset {
+				return false
+			}
+			if payload[offset]&0x80 != 0 {
+				offset++
+			}
+			offset++
+		}
+		if ext&0x40 != 0 { // TL0PICIDX
+			offset++
+		}
+		if ext&0x30 != 0 { // TID/KEYIDX
+			offset++
+		}
+	}
+	return len(payload) > offset && payload[offset]&0x01 == 0
+}
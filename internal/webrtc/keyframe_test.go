@@ -0,0 +1,54 @@
+package webrtc
+
+import (
+	"testing"
+
+	"github.com/pion/webrtc/v3"
+)
+
+// TestIsKeyframePacket_H264 verifies IDR/SPS detection in single NAL, STAP-A and FU-A packets.
+func TestIsKeyframePacket_H264(t *testing.T) {
+	cases := []struct {
+		name    string
+		payload []byte
+		want    bool
+	}{
+		{"idr", []byte{0x65, 0x88}, true},
+		{"non-idr slice", []byte{0x41, 0x9a}, false},
+		{"stap-a sps+pps", []byte{0x78, 0x00, 0x02, 0x67, 0x42, 0x00, 0x02, 0x68, 0xce}, true},
+		{"fu-a idr start", []byte{0x7c, 0x85, 0x00}, true},
+		{"fu-a idr middle", []byte{0x7c, 0x05, 0x00}, false},
+	}
+	for _, tc := range cases {
+		if got := isKeyframePacket(webrtc.MimeTypeH264, tc.payload); got != tc.want {
+			t.Fatalf("%s: expected %t, got %t", tc.name, tc.want, got)
+		}
+	}
+}
+
+// TestIsKeyframePacket_VP8 verifies the P bit is read after an extended payload descriptor.
+func TestIsKeyframePacket_VP8(t *testing.T) {
+	// X=1, S=1, PID=0; I=1 with a 15-bit picture ID; then a keyframe header (P=0).
+	key := []byte{0x90, 0x80, 0x81, 0x23, 0x10}
+	if !isKeyframePacket(webrtc.MimeTypeVP8, key) {
+		t.Fatalf("expected keyframe")
+	}
+	inter := []byte{0x90, 0x80, 0x81, 0x23, 0x11}
+	if isKeyframePacket(webrtc.MimeTypeVP8, inter) {
+		t.Fatalf("expected inter frame")
+	}
+	continuation := []byte{0x80, 0x80, 0x81, 0x23, 0x10}
+	if isKeyframePacket(webrtc.MimeTypeVP8, continuation) {
+		t.Fatalf("expected non-start packet to be ignored")
+	}
+}
+
+// TestIsKeyframePacket_VP9AndAV1 verifies the descriptor bits used for VP9 and AV1.
+func TestIsKeyframePacket_VP9AndAV1(t *testing.T) {
+	if !isKeyframePacket(webrtc.MimeTypeVP9, []byte{0x08}) || isKeyframePacket(webrtc.MimeTypeVP9, []byte{0x48}) {
+		t.Fatalf("unexpected VP9 detection")
+	}
+	if !isKeyframePacket(webrtc.MimeTypeAV1, []byte{0x08}) || isKeyframePacket(webrtc.MimeTypeAV1, []byte{0x10}) {
+		t.Fatalf("unexpected AV1 detection")
+	}
+}
@@ -3,53 +3,107 @@ package webrtc
 
 import (
 	"fmt"
+	"io"
+	"strings"
 	"sync"
 
 	"github.com/pion/interceptor"
+	"github.com/pion/interceptor/pkg/nack"
+	"github.com/pion/rtcp"
 	"github.com/pion/webrtc/v3"
 )
 
 // Publisher manages the WebRTC peer connection and video track.
 type Publisher struct {
-	mu    sync.Mutex
-	api   *webrtc.API
-	peer  *webrtc.PeerConnection
-	track *webrtc.TrackLocalStaticRTP
+	mu     sync.Mutex
+	api    *webrtc.API
+	peer   *webrtc.PeerConnection
+	codecs []string
+	codec  string
+	tracks map[string]*webrtc.TrackLocalStaticRTP
+
+	// viewers are extra view-only peers (WHEP players) fed from the same track.
+	viewers       map[*webrtc.PeerConnection]struct{}
+	viewerHandler func(count int)
+
+	iceServers []webrtc.ICEServer
+	iceClosers []io.Closer
 
 	rtpListener *rtpListener
+	lowListener *rtpListener
+	sink        rtpSink
+	layers      layerSelector
+
+	controlHandler func(data []byte)
+	controlQueue   chan []byte
+	controlOnce    sync.Once
+	codecHandler   func(codec string)
+	probes         frameProbes
+	feedback       feedback
 
 	writeMu     sync.RWMutex
 	writeParams rtpWriteParams
 }
 
-// NewPublisher initializes a WebRTC publisher with default codecs/interceptors.
-func NewPublisher() (*Publisher, error) {
+// NewPublisher initializes a WebRTC publisher with default codecs/interceptors and the
+// given ICE settings. Codecs lists the preferred video codecs (see NormalizeCodecs); the
+// first one is used until a peer's offer is negotiated.
+func NewPublisher(ice ICEConfig, codecs []string) (*Publisher, error) {
 	media := &webrtc.MediaEngine{}
 	if err := media.RegisterDefaultCodecs(); err != nil {
 		return nil, fmt.Errorf("register codecs: %w", err)
 	}
 
+	// Same set as webrtc.RegisterDefaultInterceptors, minus the NACK generator (we only send)
+	// and with a deeper retransmission history. The default codecs already advertise
+	// nack/pli/fir feedback.
 	interceptors := &interceptor.Registry{}
-	if err := webrtc.RegisterDefaultInterceptors(media, interceptors); err != nil {
+	responder, err := nack.NewResponderInterceptor(nack.ResponderSize(nackHistorySize))
+	if err != nil {
+		return nil, fmt.Errorf("register interceptors: %w", err)
+	}
+	interceptors.Add(responder)
+	if err := webrtc.ConfigureRTCPReports(interceptors); err != nil {
+		return nil, fmt.Errorf("register interceptors: %w", err)
+	}
+	if err := webrtc.ConfigureTWCCSender(media, interceptors); err != nil {
 		return nil, fmt.Errorf("register interceptors: %w", err)
 	}
 
+	settings, closers, err := ice.settingEngine()
+	if err != nil {
+		return nil, err
+	}
+
 	api := webrtc.NewAPI(
 		webrtc.WithMediaEngine(media),
 		webrtc.WithInterceptorRegistry(interceptors),
+		webrtc.WithSettingEngine(settings),
 	)
 
-	return &Publisher{api: api}, nil
+	codecs = NormalizeCodecs(codecs)
+	p := &Publisher{
+		api:        api,
+		codecs:     codecs,
+		codec:      codecs[0],
+		tracks:     make(map[string]*webrtc.TrackLocalStaticRTP),
+		viewers:    make(map[*webrtc.PeerConnection]struct{}),
+		iceServers: ice.iceServers(),
+		iceClosers: closers,
+		layers:     newLayerSelector(),
+	}
+	p.sink = rtpSink{params: p.getWriteParams, probes: &p.probes, feedback: &p.feedback, layers: &p.layers}
+	return p, nil
 }
 
-// Track returns the H264 RTP track, creating it if needed.
+// Track returns the RTP track for the current codec, creating it if needed.
 func (p *Publisher) Track() (*webrtc.TrackLocalStaticRTP, error) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 	return p.ensureTrack()
 }
 
-// NewPeer creates a new peer connection and attaches the video track.
+// NewPeer creates a new peer connection with the video track, replacing the previous one.
 func (p *Publisher) NewPeer() (*webrtc.PeerConnection, error) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
@@ -58,8 +112,81 @@ func (p *Publisher) NewPeer() (*webrtc.PeerConnection, error) {
 		_ = p.peer.Close()
 		p.peer = nil
 	}
+	peer, err := p.newPeerLocked(nil, true)
+	if err != nil {
+		return nil, err
+	}
+	p.peer = peer
+	return peer, nil
+}
+
+// NewViewerPeer creates an extra view-only peer fed from the same track. Unlike NewPeer it
+// leaves the UI viewer's peer alone; the peer is forgotten once it closes.
+func (p *Publisher) NewViewerPeer() (*webrtc.PeerConnection, error) {
+	p.mu.Lock()
+	peer, err := p.newPeerLocked(p.dropViewer, false)
+	if err != nil {
+		p.mu.Unlock()
+		return nil, err
+	}
+	p.viewers[peer] = struct{}{}
+	count, handler := len(p.viewers), p.viewerHandler
+	p.mu.Unlock()
+	if handler != nil {
+		handler(count)
+	}
+	return peer, nil
+}
+
+// SetViewerHandler registers a callback run with the number of view-only peers whenever
+// one is added or has closed.
+func (p *Publisher) SetViewerHandler(fn func(count int)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.viewerHandler = fn
+}
+
+// ViewerCount returns how many view-only peers are open.
+func (p *Publisher) ViewerCount() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.viewers)
+}
+
+// CloseViewers closes every view-only peer.
+func (p *Publisher) CloseViewers() {
+	p.mu.Lock()
+	peers := make([]*webrtc.PeerConnection, 0, len(p.viewers))
+	for peer := range p.viewers {
+		peers = append(peers, peer)
+	}
+	p.mu.Unlock()
+	for _, peer := range peers {
+		_ = peer.Close()
+	}
+}
+
+// dropViewer forgets a closed view-only peer.
+func (p *Publisher) dropViewer(peer *webrtc.PeerConnection) {
+	p.mu.Lock()
+	if _, ok := p.viewers[peer]; !ok {
+		p.mu.Unlock()
+		return
+	}
+	delete(p.viewers, peer)
+	count, handler := len(p.viewers), p.viewerHandler
+	p.mu.Unlock()
+	if handler != nil {
+		handler(count)
+	}
+}
 
-	peer, err := p.api.NewPeerConnection(webrtc.Configuration{})
+// newPeerLocked creates a peer with the video track and reads its RTCP feedback until the
+// peer closes, then runs onClose with it. Only the UI viewer's peer sets adaptLayers, so
+// a lossy view-only player cannot push everyone down to the low layer. The caller must
+// hold p.mu.
+func (p *Publisher) newPeerLocked(onClose func(*webrtc.PeerConnection), adaptLayers bool) (*webrtc.PeerConnection, error) {
+	peer, err := p.api.NewPeerConnection(webrtc.Configuration{ICEServers: p.iceServers})
 	if err != nil {
 		return nil, err
 	}
@@ -77,19 +204,30 @@ func (p *Publisher) NewPeer() (*webrtc.PeerConnection, error) {
 	}
 
 	go func() {
-		buf := make([]byte, 1500)
 		for {
-			if _, _, rtcpErr := sender.Read(buf); rtcpErr != nil {
+			pkts, _, rtcpErr := sender.ReadRTCP()
+			if rtcpErr != nil {
+				if onClose != nil {
+					onClose(peer)
+				}
 				return
 			}
+			p.handlePeerRTCP(pkts, adaptLayers)
 		}
 	}()
-
-	p.peer = peer
 	return peer, nil
 }
 
-// ClosePeer closes the current peer connection.
+// handlePeerRTCP routes a peer's RTCP: keyframe requests and NACK counts from every peer,
+// receiver loss only when the peer drives the layer choice.
+func (p *Publisher) handlePeerRTCP(pkts []rtcp.Packet, adaptLayers bool) {
+	p.feedback.handleRTCP(pkts)
+	if adaptLayers {
+		p.layers.handleRTCP(pkts)
+	}
+}
+
+// ClosePeer closes the UI viewer's peer connection; view-only peers stay open.
 func (p *Publisher) ClosePeer() {
 	p.mu.Lock()
 	defer p.mu.Unlock()
@@ -146,13 +284,20 @@ func (p *Publisher) StartForwarding() error {
 	}
 
 	p.mu.Lock()
-	listener := p.rtpListener
+	listener, low := p.rtpListener, p.lowListener
 	p.mu.Unlock()
 
 	if listener == nil || track == nil {
 		return fmt.Errorf("rtp listener or track not ready")
 	}
-	return listener.start(track, p.getWriteParams)
+	p.sink.bind(track)
+	p.layers.setLowAvailable(low != nil)
+	if low != nil {
+		if err := low.start(&p.sink, LayerLow); err != nil {
+			return err
+		}
+	}
+	return listener.start(&p.sink, LayerHigh)
 }
 
 // StopForwarding stops RTP forwarding without closing the listener.
@@ -162,9 +307,12 @@ func (p *Publisher) StopForwarding() {
 	if p.rtpListener != nil {
 		p.rtpListener.stop()
 	}
+	if p.lowListener != nil {
+		p.lowListener.stop()
+	}
 }
 
-// CloseRTP closes the UDP listener used for RTP ingest.
+// CloseRTP closes the UDP listeners used for RTP ingest.
 func (p *Publisher) CloseRTP() {
 	p.mu.Lock()
 	defer p.mu.Unlock()
@@ -172,6 +320,20 @@ func (p *Publisher) CloseRTP() {
 		p.rtpListener.close()
 		p.rtpListener = nil
 	}
+	if p.lowListener != nil {
+		p.lowListener.close()
+		p.lowListener = nil
+	}
+}
+
+// CloseICE releases the shared ICE UDP/TCP sockets.
+func (p *Publisher) CloseICE() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, closer := range p.iceClosers {
+		_ = closer.Close()
+	}
+	p.iceClosers = nil
 }
 
 // UpdateWriteParamsFromPeer updates RTP header expectations from the active peer connection.
@@ -179,6 +341,7 @@ func (p *Publisher) UpdateWriteParamsFromPeer(peer *webrtc.PeerConnection) {
 	if peer == nil {
 		return
 	}
+	mime := codecCapabilities[p.Codec()].MimeType
 	var pt uint8
 	for _, sender := range peer.GetSenders() {
 		track := sender.Track()
@@ -187,7 +350,7 @@ func (p *Publisher) UpdateWriteParamsFromPeer(peer *webrtc.PeerConnection) {
 		}
 		params := sender.GetParameters()
 		for _, codec := range params.Codecs {
-			if codec.MimeType == webrtc.MimeTypeH264 && codec.PayloadType != 0 {
+			if strings.EqualFold(codec.MimeType, mime) && codec.PayloadType != 0 {
 				pt = uint8(codec.PayloadType)
 				break
 			}
@@ -208,19 +371,19 @@ func (p *Publisher) getWriteParams() rtpWriteParams {
 	return p.writeParams
 }
 
-// ensureTrack initializes the track if it does not already exist.
+// ensureTrack initializes the track for the current codec if it does not already exist.
 func (p *Publisher) ensureTrack() (*webrtc.TrackLocalStaticRTP, error) {
-	if p.track != nil {
-		return p.track, nil
+	if track := p.tracks[p.codec]; track != nil {
+		return track, nil
 	}
 	track, err := webrtc.NewTrackLocalStaticRTP(
-		webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeH264},
+		codecCapabilities[p.codec],
 		"video",
 		"deskslice",
 	)
 	if err != nil {
 		return nil, err
 	}
-	p.track = track
+	p.tracks[p.codec] = track
 	return track, nil
 }
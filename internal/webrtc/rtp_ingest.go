@@ -20,11 +20,8 @@ type rtpListener struct {
 	cancel  context.CancelFunc
 	running bool
 
-	packetCount    int
-	firstLogged    bool
-	writeErrLogged bool
-
-	rewrite rtpRewriter
+	packetCount int
+	firstLogged bool
 }
 
 // newRTPListener binds a UDP port for RTP ingestion.
@@ -51,8 +48,8 @@ func (l *rtpListener) port() int {
 	return addr.Port
 }
 
-// start begins forwarding RTP packets into the provided track.
-func (l *rtpListener) start(track *webrtc.TrackLocalStaticRTP, params func() rtpWriteParams) error {
+// start begins forwarding RTP packets of the given layer into the sink.
+func (l *rtpListener) start(sink *rtpSink, layer string) error {
 	l.mu.Lock()
 	defer l.mu.Unlock()
 	if l.conn == nil {
@@ -65,8 +62,7 @@ func (l *rtpListener) start(track *webrtc.TrackLocalStaticRTP, params func() rtp
 	l.running = true
 	l.packetCount = 0
 	l.firstLogged = false
-	l.writeErrLogged = false
-	go l.loop(track, params)
+	go l.loop(sink, layer)
 	return nil
 }
 
@@ -89,8 +85,8 @@ func (l *rtpListener) close() {
 	}
 }
 
-// loop reads RTP packets and forwards them to the track.
-func (l *rtpListener) loop(track *webrtc.TrackLocalStaticRTP, params func() rtpWriteParams) {
+// loop reads RTP packets and hands them to the sink.
+func (l *rtpListener) loop(sink *rtpSink, layer string) {
 	buf := make([]byte, 1600)
 	lastLog := time.Now()
 	for {
@@ -110,24 +106,80 @@ func (l *rtpListener) loop(track *webrtc.TrackLocalStaticRTP, params func() rtpW
 		}
 		l.packetCount++
 		if debugRTPEnabled() && !l.firstLogged {
-			log.Printf("rtp: first packet ssrc=%d pt=%d seq=%d ts=%d", pkt.SSRC, pkt.PayloadType, pkt.SequenceNumber, pkt.Timestamp)
+			log.Printf("rtp: first %s packet ssrc=%d pt=%d seq=%d ts=%d", layer, pkt.SSRC, pkt.PayloadType, pkt.SequenceNumber, pkt.Timestamp)
 			l.firstLogged = true
 		}
 		if debugRTPEnabled() && time.Since(lastLog) > 5*time.Second {
-			log.Printf("rtp: packets=%d", l.packetCount)
+			log.Printf("rtp: %s packets=%d", layer, l.packetCount)
 			lastLog = time.Now()
 		}
+		sink.write(layer, &pkt)
+	}
+}
 
-		writeParams := rtpWriteParams{}
-		if params != nil {
-			writeParams = params()
-		}
-		l.rewrite.Apply(&pkt, writeParams)
+// rtpSink writes the packets of the active layer into the track as one continuous stream.
+// It lives as long as the publisher so sequence numbers and timestamps survive restarts.
+type rtpSink struct {
+	mu       sync.Mutex
+	track    *webrtc.TrackLocalStaticRTP
+	mime     string
+	params   func() rtpWriteParams
+	probes   *frameProbes
+	feedback *feedback
+	layers   *layerSelector
 
-		if err := track.WriteRTP(&pkt); err != nil && !l.writeErrLogged {
-			log.Printf("rtp: write failed: %v", err)
-			l.writeErrLogged = true
-		}
+	rewrite        rtpRewriter
+	lastInTS       uint32
+	haveTS         bool
+	writeErrLogged bool
+}
+
+// bind points the sink at the current track.
+func (s *rtpSink) bind(track *webrtc.TrackLocalStaticRTP) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.track = track
+	s.mime = track.Codec().MimeType
+	s.haveTS = false
+	s.writeErrLogged = false
+}
+
+// write forwards a packet when its layer is active, switching layers on a keyframe.
+func (s *rtpSink) write(layer string, pkt *rtp.Packet) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.track == nil {
+		return
+	}
+	keyframe := isKeyframePacket(s.mime, pkt.Payload)
+	forward, switched := s.layers.accept(layer, keyframe)
+	if !forward {
+		return
+	}
+	if switched {
+		// Layers come from separate RTP muxers with unrelated timestamp bases.
+		s.rewrite.Rebase()
+		s.haveTS = false
+	}
+
+	frameStart := !s.haveTS || pkt.Timestamp != s.lastInTS
+	s.lastInTS, s.haveTS = pkt.Timestamp, true
+
+	writeParams := rtpWriteParams{}
+	if s.params != nil {
+		writeParams = s.params()
+	}
+	s.rewrite.Apply(pkt, writeParams)
+
+	if err := s.track.WriteRTP(pkt); err != nil && !s.writeErrLogged {
+		log.Printf("rtp: write failed: %v", err)
+		s.writeErrLogged = true
+	}
+	if s.probes != nil {
+		s.probes.observe(frameStart, pkt.Marker, time.Now())
+	}
+	if s.feedback != nil && keyframe {
+		s.feedback.observeKeyframe()
 	}
 }
 
@@ -143,6 +195,16 @@ type rtpRewriter struct {
 	outTS       uint32
 	lastInTS    uint32
 	lastDelta   uint32
+	rebase      bool
+}
+
+// Rebase makes the next input timestamp continue the output timeline after one frame
+// interval, as if it followed the last forwarded frame.
+func (r *rtpRewriter) Rebase() {
+	if !r.initialized {
+		return
+	}
+	r.rebase = true
 }
 
 // Apply rewrites sequence/timestamp and overrides payload type/ssrc when available.
@@ -167,6 +229,18 @@ func (r *rtpRewriter) Apply(pkt *rtp.Packet, params rtpWriteParams) {
 	}
 	pkt.SequenceNumber = r.outSeq
 
+	if r.rebase {
+		r.rebase = false
+		delta := r.lastDelta
+		if delta == 0 {
+			delta = 3000
+		}
+		r.lastInTS = pkt.Timestamp
+		r.outTS += delta
+		pkt.Timestamp = r.outTS
+		return
+	}
+
 	// Retimestamp by translating input timestamps into a continuous timeline.
 	// Keep all packets for a single input timestamp on the same output timestamp (frame boundary).
 	if pkt.Timestamp == r.lastInTS {
@@ -81,3 +81,25 @@ func TestRTPRewriterOverridesHeader(t *testing.T) {
 		t.Fatalf("expected payload type override, got %d", p.PayloadType)
 	}
 }
+
+// TestRTPRewriterRebase verifies a layer switch continues the timeline by one frame interval.
+func TestRTPRewriterRebase(t *testing.T) {
+	var rw rtpRewriter
+	p1 := &rtp.Packet{Header: rtp.Header{SequenceNumber: 1, Timestamp: 1000}}
+	rw.Apply(p1, rtpWriteParams{})
+	p2 := &rtp.Packet{Header: rtp.Header{SequenceNumber: 2, Timestamp: 4000}}
+	rw.Apply(p2, rtpWriteParams{})
+
+	// The other layer's muxer uses an unrelated (but nearby) timestamp base.
+	rw.Rebase()
+	p3 := &rtp.Packet{Header: rtp.Header{SequenceNumber: 900, Timestamp: 50000}}
+	rw.Apply(p3, rtpWriteParams{})
+	if p3.Timestamp != p2.Timestamp+3000 || p3.SequenceNumber != p2.SequenceNumber+1 {
+		t.Fatalf("expected ts=%d seq=%d, got ts=%d seq=%d", p2.Timestamp+3000, p2.SequenceNumber+1, p3.Timestamp, p3.SequenceNumber)
+	}
+	p4 := &rtp.Packet{Header: rtp.Header{SequenceNumber: 901, Timestamp: 53000}}
+	rw.Apply(p4, rtpWriteParams{})
+	if p4.Timestamp != p3.Timestamp+3000 {
+		t.Fatalf("expected timeline to follow the new base, got %d", p4.Timestamp)
+	}
+}
@@ -0,0 +1,163 @@
+// Package webrtc provides the WebRTC publisher pipeline.
+package webrtc
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pion/rtcp"
+)
+
+// Layer names for the dual-quality pipeline.
+const (
+	// LayerAuto switches layers from receiver loss reports.
+	LayerAuto = "auto"
+	// LayerHigh is the full-resolution, full-bitrate encoding.
+	LayerHigh = "high"
+	// LayerLow is the half-resolution, low-bitrate encoding.
+	LayerLow = "low"
+)
+
+const (
+	// lossDowngrade is the receiver-reported loss fraction (x/256, ~10%) that drops to low.
+	lossDowngrade = 26
+	// lossUpgrade is the loss fraction (~2%) under which the network counts as clean.
+	lossUpgrade = 5
+	// upgradeHold is how long loss must stay clean before returning to the high layer.
+	upgradeHold = 10 * time.Second
+)
+
+// layerSelector decides which layer is forwarded. Switches are requested by setting a
+// target and applied on the target layer's next keyframe so the decoder never sees a
+// delta frame it has no reference for.
+type layerSelector struct {
+	mu           sync.Mutex
+	mode         string
+	active       string
+	target       string
+	lowAvailable bool
+	cleanSince   time.Time
+}
+
+// newLayerSelector starts in auto mode forwarding the high layer.
+func newLayerSelector() layerSelector {
+	return layerSelector{mode: LayerAuto, active: LayerHigh, target: LayerHigh}
+}
+
+// setMode selects auto, high or low forwarding.
+func (s *layerSelector) setMode(mode string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch mode {
+	case LayerAuto:
+		s.cleanSince = time.Time{}
+	case LayerHigh, LayerLow:
+		s.setTarget(mode)
+	default:
+		return fmt.Errorf("unknown layer %q", mode)
+	}
+	s.mode = mode
+	return nil
+}
+
+// setLowAvailable records whether the encoder currently produces the low layer.
+func (s *layerSelector) setLowAvailable(ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lowAvailable = ok
+	if !ok {
+		s.active, s.target = LayerHigh, LayerHigh
+	} else if s.mode == LayerLow {
+		s.target = LayerLow
+	}
+}
+
+// setTarget requests a switch, falling back to high when there is no low layer.
+func (s *layerSelector) setTarget(layer string) {
+	if layer == LayerLow && !s.lowAvailable {
+		layer = LayerHigh
+	}
+	s.target = layer
+}
+
+// observeLoss applies the auto policy to a receiver-reported loss fraction.
+func (s *layerSelector) observeLoss(fraction uint8, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.mode != LayerAuto {
+		return
+	}
+	switch {
+	case fraction >= lossDowngrade:
+		s.cleanSince = time.Time{}
+		s.setTarget(LayerLow)
+	case fraction <= lossUpgrade:
+		if s.cleanSince.IsZero() {
+			s.cleanSince = now
+		}
+		if now.Sub(s.cleanSince) >= upgradeHold {
+			s.setTarget(LayerHigh)
+		}
+	default:
+		s.cleanSince = time.Time{}
+	}
+}
+
+// handleRTCP feeds receiver report loss into the auto policy.
+func (s *layerSelector) handleRTCP(pkts []rtcp.Packet) {
+	now := time.Now()
+	for _, pkt := range pkts {
+		if rr, ok := pkt.(*rtcp.ReceiverReport); ok {
+			for _, report := range rr.Reports {
+				s.observeLoss(report.FractionLost, now)
+			}
+		}
+	}
+}
+
+// accept reports whether a packet of the layer should be forwarded and whether it
+// completed a pending switch.
+func (s *layerSelector) accept(layer string, keyframe bool) (bool, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if keyframe && layer == s.target && s.target != s.active {
+		s.active = s.target
+		return true, true
+	}
+	return layer == s.active, false
+}
+
+// current returns the forwarded layer and the selection mode.
+func (s *layerSelector) current() (string, string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.active, s.mode
+}
+
+// LowRTPPort returns the local port for the low layer, creating its listener if needed.
+func (p *Publisher) LowRTPPort() (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.lowListener == nil {
+		listener, err := newRTPListener(0)
+		if err != nil {
+			return 0, err
+		}
+		p.lowListener = listener
+	}
+	return p.lowListener.port(), nil
+}
+
+// SetLayer selects auto, high or low forwarding.
+func (p *Publisher) SetLayer(mode string) error {
+	return p.layers.setMode(mode)
+}
+
+// Layer returns the forwarded layer and the selection mode ("" for a nil publisher).
+func (p *Publisher) Layer() (string, string) {
+	if p == nil {
+		return "", ""
+	}
+	return p.layers.current()
+}
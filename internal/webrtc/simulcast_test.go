@@ -0,0 +1,91 @@
+package webrtc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pion/rtcp"
+)
+
+// TestLayerSelector_AutoSwitchesOnKeyframes verifies loss requests the low layer and the switch waits for its keyframe.
+func TestLayerSelector_AutoSwitchesOnKeyframes(t *testing.T) {
+	s := newLayerSelector()
+	s.setLowAvailable(true)
+	now := time.Now()
+
+	s.observeLoss(60, now)
+	if fwd, _ := s.accept(LayerLow, false); fwd {
+		t.Fatalf("low delta frame forwarded before its keyframe")
+	}
+	if fwd, _ := s.accept(LayerHigh, false); !fwd {
+		t.Fatalf("high layer should keep flowing until the switch")
+	}
+	if fwd, switched := s.accept(LayerLow, true); !fwd || !switched {
+		t.Fatalf("expected switch on low keyframe")
+	}
+	if fwd, _ := s.accept(LayerHigh, true); fwd {
+		t.Fatalf("high layer forwarded after switching to low")
+	}
+
+	// Clean reports only upgrade after the hold period.
+	s.observeLoss(0, now)
+	s.observeLoss(0, now.Add(upgradeHold/2))
+	if _, switched := s.accept(LayerHigh, true); switched {
+		t.Fatalf("upgraded before the hold period")
+	}
+	s.observeLoss(0, now.Add(upgradeHold))
+	if _, switched := s.accept(LayerHigh, true); !switched {
+		t.Fatalf("expected upgrade after the hold period")
+	}
+}
+
+// TestLayerSelector_ManualModeAndMissingLowLayer verifies manual choices ignore loss and low needs an encoder.
+func TestLayerSelector_ManualModeAndMissingLowLayer(t *testing.T) {
+	s := newLayerSelector()
+	if err := s.setMode(LayerLow); err != nil {
+		t.Fatalf("set mode: %v", err)
+	}
+	if _, switched := s.accept(LayerLow, true); switched {
+		t.Fatalf("switched to a low layer that is not encoded")
+	}
+
+	s.setLowAvailable(true)
+	if _, switched := s.accept(LayerLow, true); !switched {
+		t.Fatalf("expected switch once the low layer exists")
+	}
+	s.observeLoss(0, time.Now().Add(-2*upgradeHold))
+	s.observeLoss(0, time.Now())
+	if active, mode := s.current(); active != LayerLow || mode != LayerLow {
+		t.Fatalf("manual low should ignore clean reports, got active=%s mode=%s", active, mode)
+	}
+	if err := s.setMode("ultra"); err == nil {
+		t.Fatalf("expected error for unknown layer")
+	}
+}
+
+// TestHandlePeerRTCP_OnlyUIPeerAdaptsLayers verifies a view-only player's loss reports
+// leave the layer alone while its keyframe requests still count.
+func TestHandlePeerRTCP_OnlyUIPeerAdaptsLayers(t *testing.T) {
+	pub, err := NewPublisher(ICEConfig{}, nil)
+	if err != nil {
+		t.Fatalf("new publisher: %v", err)
+	}
+	pub.layers.setLowAvailable(true)
+	lossy := []rtcp.Packet{
+		&rtcp.ReceiverReport{Reports: []rtcp.ReceptionReport{{FractionLost: 200}}},
+		&rtcp.PictureLossIndication{},
+	}
+
+	pub.handlePeerRTCP(lossy, false)
+	if _, switched := pub.layers.accept(LayerLow, true); switched {
+		t.Fatalf("player loss switched the shared layer")
+	}
+	if got := pub.feedback.snapshot().PLIs; got != 1 {
+		t.Fatalf("expected the player's PLI to count, got %d", got)
+	}
+
+	pub.handlePeerRTCP(lossy, true)
+	if _, switched := pub.layers.accept(LayerLow, true); !switched {
+		t.Fatalf("expected UI viewer loss to switch to low")
+	}
+}